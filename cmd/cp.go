@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/andreclaro/ssm/internal/service"
+	"github.com/andreclaro/ssm/internal/storage"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	cpRecursive bool
+	cpSelector  string
+)
+
+// cpCmd represents the cp command
+var cpCmd = &cobra.Command{
+	Use:   "cp <source> <destination>",
+	Short: "Copy files to and from instances",
+	Long: `Copy files between the local machine and an instance over an SSM session.
+
+Remote paths are written as instance-name:/path. Exactly one of source and
+destination must be remote. Data is transferred base64-encoded over an
+interactive command session and verified with a SHA-256 checksum, so no S3
+bucket or SSH access is needed. File transfer requires Linux instances.
+
+With -t the instance matching a tag selector is used and the remote path is
+written as :/path.
+
+Examples:
+  ssm cp app.conf web-1:/tmp/app.conf          # Upload a file
+  ssm cp web-1:/var/log/syslog .                # Download a file
+  ssm cp -r ./config web-1:/opt/app             # Upload a directory to /opt/app/config
+  ssm cp -r web-1:/etc/nginx ./nginx-backup     # Download a directory
+  ssm cp -t role=web app.conf :/tmp/app.conf    # Upload to the instance tagged role=web`,
+	Args: cobra.ExactArgs(2),
+	Run:  runCp,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if strings.Contains(toComplete, ":") {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		names, _ := CompleteInstanceNames(toComplete)
+		for i, name := range names {
+			names[i] = name + ":"
+		}
+		return names, cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveDefault
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cpCmd)
+
+	cpCmd.Flags().BoolVarP(&cpRecursive, "recursive", "r", false, "Copy directories recursively")
+	cpCmd.Flags().StringVarP(&cpSelector, "tags", "t", "", "Target the instance matching a tag selector; remote paths are written as :/path")
+}
+
+func runCp(cmd *cobra.Command, args []string) {
+	srcInstance, srcPath, srcRemote := parseRemotePath(args[0])
+	dstInstance, dstPath, dstRemote := parseRemotePath(args[1])
+	if cpSelector != "" {
+		if srcInstance != "" || dstInstance != "" {
+			fmt.Fprintln(os.Stderr, "With -t, write the remote path as :/path without an instance name")
+			os.Exit(1)
+		}
+		srcPath, srcRemote = parseSelectorPath(args[0])
+		dstPath, dstRemote = parseSelectorPath(args[1])
+	}
+
+	if srcRemote == dstRemote {
+		fmt.Fprintln(os.Stderr, "Exactly one of source and destination must be remote (instance-name:/path)")
+		os.Exit(1)
+	}
+
+	// Create service
+	svc, err := service.NewService()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create service: %v\n", err)
+		os.Exit(1)
+	}
+
+	instanceName := srcInstance
+	if dstRemote {
+		instanceName = dstInstance
+	}
+	instance, err := svc.ResolveTarget(instanceName, cpSelector)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	if dstRemote {
+		progress := newProgressPrinter(fmt.Sprintf("%s -> %s:%s", srcPath, instance.DisplayName(), dstPath))
+		err = svc.CopyToInstance(ctx, srcPath, instance, dstPath, cpRecursive, progress.update)
+		progress.finish()
+	} else {
+		progress := newProgressPrinter(fmt.Sprintf("%s:%s -> %s", instance.DisplayName(), srcPath, dstPath))
+		err = svc.CopyFromInstance(ctx, instance, srcPath, dstPath, cpRecursive, progress.update)
+		progress.finish()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to copy: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// parseRemotePath splits instance-name:/path arguments at the colon before
+// the remote path, so targets may contain colons themselves (account:name,
+// profile:account/region/name). Single letter prefixes are treated as
+// Windows drive letters, not instance names.
+func parseRemotePath(arg string) (instance, path string, remote bool) {
+	idx := remotePathSeparator(arg)
+	if idx <= 1 || strings.Contains(arg[:idx], `\`) {
+		return "", arg, false
+	}
+	if prefix := arg[:idx]; strings.Contains(prefix, "/") {
+		if _, ok := storage.ParseInstanceQuery(prefix); !ok || strings.HasPrefix(prefix, ".") {
+			return "", arg, false
+		}
+	}
+	path = arg[idx+1:]
+	if path == "" {
+		path = "."
+	}
+	return arg[:idx], path, true
+}
+
+// remotePathSeparator returns the index of the colon that ends the target:
+// the first one followed by an absolute or home relative path, else the last
+// one for relative remote paths. It returns -1 when arg has no colon.
+func remotePathSeparator(arg string) int {
+	for i := 0; i < len(arg)-1; i++ {
+		if arg[i] == ':' && (arg[i+1] == '/' || arg[i+1] == '~') {
+			return i
+		}
+	}
+	return strings.LastIndex(arg, ":")
+}
+
+// parseSelectorPath splits the :/path arguments used with a tag selector,
+// which names the instance
+func parseSelectorPath(arg string) (path string, remote bool) {
+	path, remote = strings.CutPrefix(arg, ":")
+	if remote && path == "" {
+		path = "."
+	}
+	return path, remote
+}
+
+// progressPrinter renders transfer progress on stderr when it is a terminal
+type progressPrinter struct {
+	label   string
+	enabled bool
+	printed bool
+	lastPct int
+}
+
+// newProgressPrinter creates a progress printer for a transfer
+func newProgressPrinter(label string) *progressPrinter {
+	return &progressPrinter{
+		label:   label,
+		enabled: term.IsTerminal(int(os.Stderr.Fd())),
+		lastPct: -1,
+	}
+}
+
+// update implements aws.ProgressFunc
+func (p *progressPrinter) update(done, total int64) {
+	if !p.enabled {
+		return
+	}
+	pct := 100
+	if total > 0 {
+		pct = int(done * 100 / total)
+	}
+	if pct == p.lastPct {
+		return
+	}
+	p.lastPct = pct
+	p.printed = true
+	fmt.Fprintf(os.Stderr, "\r%s  %3d%%  %s / %s", p.label, pct, formatBytes(done), formatBytes(total))
+}
+
+// finish ends the progress line
+func (p *progressPrinter) finish() {
+	if p.printed {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// formatBytes formats a byte count for humans
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseRemotePath tests splitting targets from remote paths
+func TestParseRemotePath(t *testing.T) {
+	tests := []struct {
+		arg      string
+		instance string
+		path     string
+		remote   bool
+	}{
+		{"web-1:/etc/app.conf", "web-1", "/etc/app.conf", true},
+		{"web-1:", "web-1", ".", true},
+		{"web-1:logs/app.log", "web-1", "logs/app.log", true},
+		{"web-1:~/notes.txt", "web-1", "~/notes.txt", true},
+		{"i-0123456789abcdef0:/tmp/x", "i-0123456789abcdef0", "/tmp/x", true},
+		{"prod/eu-west-1/web:/etc/app.conf", "prod/eu-west-1/web", "/etc/app.conf", true},
+		{"123456789012:web:/etc/app.conf", "123456789012:web", "/etc/app.conf", true},
+		{"mgmt:123456789012/eu-west-1/web:/etc/app.conf", "mgmt:123456789012/eu-west-1/web", "/etc/app.conf", true},
+		{"mgmt:123456789012/eu-west-1/web:app.conf", "mgmt:123456789012/eu-west-1/web", "app.conf", true},
+		{"app.conf", "", "app.conf", false},
+		{"./dir/a:b", "", "./dir/a:b", false},
+		{"/tmp/a:/b", "", "/tmp/a:/b", false},
+		{`C:\Users\app.conf`, "", `C:\Users\app.conf`, false},
+		{"C:/Users/app.conf", "", "C:/Users/app.conf", false},
+	}
+	for _, tt := range tests {
+		instance, path, remote := parseRemotePath(tt.arg)
+		assert.Equal(t, tt.instance, instance, tt.arg)
+		assert.Equal(t, tt.path, path, tt.arg)
+		assert.Equal(t, tt.remote, remote, tt.arg)
+	}
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/andreclaro/ssm/internal/service"
+	"github.com/spf13/cobra"
+)
+
+var doctorSelector string
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor <instance|-t selector>",
+	Short: "Diagnose why an instance cannot be reached",
+	Long: `Check each layer a Session Manager connection depends on and explain
+what to fix when one fails:
+
+  - the aws CLI and session-manager-plugin on this machine
+  - the credentials of the instance's profile
+  - the EC2 state and IAM instance profile
+  - SSM registration, last ping and agent version
+  - the account's Session Manager preferences (SSM-SessionManagerRunShell),
+    which fail when they require KMS encryption without session.client: cli
+
+Exits 1 when a check fails.
+
+Examples:
+  ssm doctor web-1
+  ssm doctor -t role=bastion`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if doctorSelector != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	Run: runDoctor,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return CompleteInstanceNames(toComplete)
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().StringVarP(&doctorSelector, "tags", "t", "", "Target the instance matching a tag selector instead of a name")
+}
+
+func runDoctor(cmd *cobra.Command, args []string) {
+	var instanceName string
+	if len(args) > 0 {
+		instanceName = args[0]
+	}
+
+	// Create service
+	svc, err := service.NewService()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create service: %v\n", err)
+		os.Exit(1)
+	}
+
+	instance, err := svc.ResolveTarget(instanceName, doctorSelector)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Diagnosing %s (%s, %s/%s)\n\n", instance.DisplayName(), instance.InstanceID, instance.Profile, instance.Region)
+
+	ctx := context.Background()
+	checks := svc.Diagnose(ctx, instance)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	failed := false
+	for _, check := range checks {
+		fmt.Fprintf(w, "[%s]\t%s\t%s\n", strings.ToUpper(check.Status), check.Name, check.Detail)
+		if check.Hint != "" {
+			fmt.Fprintf(w, "\t\t-> %s\n", check.Hint)
+		}
+		if check.Status == service.CheckFail {
+			failed = true
+		}
+	}
+	w.Flush()
+
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/andreclaro/ssm/internal/config"
+	"github.com/andreclaro/ssm/internal/service"
+	"github.com/spf13/cobra"
+)
+
+// downCmd represents the down command
+var downCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Tear down the tunnels started by 'ssm up'",
+	Long: `Stop the 'ssm up' process serving the project's .ssm.yaml, found in the
+current directory or the nearest parent, and wait for its tunnels to close.`,
+	Args: cobra.NoArgs,
+	Run:  runDown,
+}
+
+func init() {
+	rootCmd.AddCommand(downCmd)
+}
+
+func runDown(cmd *cobra.Command, args []string) {
+	path, err := config.FindManifest(".")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	// Create service
+	svc, err := service.NewService()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create service: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := svc.TunnelsDown(path); err != nil {
+		if errors.Is(err, service.ErrTunnelsNotUp) {
+			fmt.Printf("No tunnels are up for %s\n", path)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Failed to tear down tunnels: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Tunnels for %s are down\n", path)
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/andreclaro/ssm/internal/service"
+	"github.com/andreclaro/ssm/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ecsContainer string
+	ecsCommand   string
+)
+
+// ecsCmd represents the ecs command
+var ecsCmd = &cobra.Command{
+	Use:   "ecs [service|task] [--container name]",
+	Short: "Open a shell in an ECS container with ECS Exec",
+	Long: `Open an interactive ECS Exec session in a running task's container.
+
+The target is a service name (any running task of it is used), a task ID or
+a unique prefix of one, or a task ARN. Qualify services and task IDs that
+exist in several clusters as cluster/name, or in several accounts or regions
+as profile/region/cluster/name. Without arguments, list the tasks found by
+'ssm sync' that have ECS Exec enabled.
+
+Examples:
+  ssm ecs                              # List exec-enabled tasks
+  ssm ecs api                          # Shell into a task of the api service
+  ssm ecs prod/api --container app     # Choose the cluster and container
+  ssm ecs prod/eu-west-1/app/api       # Choose the profile and region too
+  ssm ecs 0a1b2c3d --command "rails c" # Run a different command`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runECS,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completeECSTargets(toComplete)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(ecsCmd)
+
+	ecsCmd.Flags().StringVarP(&ecsContainer, "container", "c", "", "Container to open the session in (required when the task has several)")
+	ecsCmd.Flags().StringVar(&ecsCommand, "command", service.DefaultECSCommand, "Command to run in the container")
+}
+
+func runECS(cmd *cobra.Command, args []string) {
+	// Create service
+	svc, err := service.NewService()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create service: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(args) == 0 {
+		listECSTasks(svc)
+		return
+	}
+
+	task, container, err := svc.ResolveECSTarget(args[0], ecsContainer)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	if err := svc.ExecECS(ctx, task, container, ecsCommand); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start ECS Exec session: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// listECSTasks prints the discovered exec-enabled tasks
+func listECSTasks(svc *service.Service) {
+	tasks, err := svc.ListECSTasks()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to list ECS tasks: %v\n", err)
+		os.Exit(1)
+	}
+	if len(tasks) == 0 {
+		fmt.Println("No ECS tasks with ECS Exec enabled found (run 'ssm sync' to discover them)")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "CLUSTER\tSERVICE\tTASK ID\tCONTAINERS\tLAUNCH TYPE\tREGION\tPROFILE")
+	for _, task := range tasks {
+		service := task.Service
+		if service == "" {
+			service = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			task.Cluster, service, task.TaskID, task.Containers, task.LaunchType, task.Region, task.Profile)
+	}
+}
+
+// completeECSTargets completes service names and task IDs
+func completeECSTargets(toComplete string) ([]string, cobra.ShellCompDirective) {
+	if !initCompletionDB() {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	tasks, err := storage.NewECSTaskRepository().List()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	seen := make(map[string]bool)
+	var targets []string
+	for _, task := range tasks {
+		for _, target := range []string{task.Service, task.TaskID} {
+			if target != "" && !seen[target] && strings.HasPrefix(target, toComplete) {
+				seen[target] = true
+				targets = append(targets, target)
+			}
+		}
+	}
+	return targets, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/andreclaro/ssm/internal/service"
+	"github.com/spf13/cobra"
+)
+
+var (
+	execTimeout  time.Duration
+	execSelector string
+)
+
+// execCmd represents the exec command
+var execCmd = &cobra.Command{
+	Use:   "exec [instance-name...] [-t selector] -- <command>",
+	Short: "Run a command on one or more instances",
+	Long: `Run a shell command on one or more instances using SSM Run Command.
+
+Linux instances run the command with AWS-RunShellScript and Windows instances
+with AWS-RunPowerShellScript. Output is printed with the instance name as a
+prefix. The exit code is the highest exit code across all instances (255 when
+the command could not be run on an instance). Each argument reaches the
+command as one word, so shell syntax has to be passed explicitly, e.g.
+ssm exec web-1 -- sh -c 'cd /srv && make'.
+
+With -t the command also runs on every reachable instance whose tags match
+the selector (see 'ssm --help' for the selector syntax).
+
+Examples:
+  ssm exec web-1 -- uptime                     # Run uptime on web-1
+  ssm exec web-1 web-2 web-3 -- df -h /        # Run on several instances
+  ssm exec --timeout 30m db-1 -- ./backup.sh   # Allow a longer execution time
+  ssm exec -t team=payments -- uptime          # Run on every instance tagged team=payments`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		dash := cmd.ArgsLenAtDash()
+		if dash < 0 {
+			return fmt.Errorf("separate instances from the command with --")
+		}
+		if dash == 0 && execSelector == "" {
+			return fmt.Errorf("at least one instance or a tag selector (-t) is required")
+		}
+		if dash == len(args) {
+			return fmt.Errorf("a command is required after --")
+		}
+		return nil
+	},
+	Run: runExec,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if cmd.ArgsLenAtDash() >= 0 {
+			return nil, cobra.ShellCompDirectiveDefault
+		}
+		return CompleteInstanceNames(toComplete)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(execCmd)
+
+	execCmd.Flags().StringVarP(&execSelector, "tags", "t", "", "Also target reachable instances matching a tag selector, e.g. env=prod,role=web")
+	execCmd.Flags().DurationVar(&execTimeout, "timeout", 10*time.Minute, "Maximum execution time of the command on each instance")
+}
+
+func runExec(cmd *cobra.Command, args []string) {
+	dash := cmd.ArgsLenAtDash()
+	instanceNames := args[:dash]
+	command := shellJoin(args[dash:])
+
+	// Create service
+	svc, err := service.NewService()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create service: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	exitCode, err := svc.ExecOnInstances(ctx, instanceNames, execSelector, command, execTimeout, os.Stdout, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to run command: %v\n", err)
+		os.Exit(1)
+	}
+
+	os.Exit(exitCode)
+}
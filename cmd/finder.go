@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andreclaro/ssm/internal/aws"
+	"github.com/andreclaro/ssm/internal/finder"
+	"github.com/andreclaro/ssm/internal/service"
+	"github.com/andreclaro/ssm/internal/storage"
+)
+
+// runFinder lets the user pick an instance from the local cache and acts on it
+func runFinder(svc *service.Service) error {
+	instances, err := storage.NewInstanceRepository().ListByFrecency()
+	if err != nil {
+		return err
+	}
+	if len(instances) == 0 {
+		return fmt.Errorf("no instances cached; run 'ssm sync' first")
+	}
+
+	result, err := finder.Run(os.Stdin, os.Stderr, finderItems(instances))
+	if errors.Is(err, finder.ErrAborted) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	instance := &instances[result.Index]
+	ctx := context.Background()
+	switch result.Action {
+	case finder.ActionForward:
+		mappings, err := promptPortMappings(instance)
+		if err != nil {
+			return err
+		}
+		return svc.RunTunnelTo(ctx, instance, mappings, "")
+	case finder.ActionCopyID:
+		// Print the ID as well so $(ssm) can be used in scripts
+		fmt.Println(instance.InstanceID)
+		if err := copyToClipboard(instance.InstanceID); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Copied %s to clipboard\n", instance.InstanceID)
+		return nil
+	default:
+		return svc.Connect(ctx, instance, aws.SessionOptions{})
+	}
+}
+
+// finderItems builds finder entries for instances
+func finderItems(instances []storage.Instance) []finder.Item {
+	nameWidth, idWidth, stateWidth := 4, 11, 5
+	for _, instance := range instances {
+		nameWidth = max(nameWidth, len(instance.DisplayName()))
+		idWidth = max(idWidth, len(instance.InstanceID))
+		stateWidth = max(stateWidth, len(instance.State))
+	}
+	nameWidth = min(nameWidth, 40)
+
+	items := make([]finder.Item, len(instances))
+	for i, instance := range instances {
+		tags := make([]string, 0, len(instance.Tags))
+		for _, tag := range instance.Tags {
+			tags = append(tags, tag.Key+"="+tag.Value)
+		}
+		sort.Strings(tags)
+
+		items[i] = finder.Item{
+			Label: fmt.Sprintf("%-*s  %-*s  %-*s  %s/%s",
+				nameWidth, instance.DisplayName(),
+				idWidth, instance.InstanceID,
+				stateWidth, instance.State,
+				instance.Profile, instance.Region),
+			Search: strings.Join(append([]string{
+				instance.Name, instance.InstanceID, instance.Profile, instance.Region, instance.AccountID,
+				instance.PrivateIP, instance.PrivateDNS, instance.ComputerName,
+			}, tags...), " "),
+			Preview: instancePreview(instance, tags),
+		}
+	}
+	return items
+}
+
+// instancePreview renders the detail pane for an instance
+func instancePreview(instance storage.Instance, tags []string) []string {
+	lines := []string{
+		"Name:        " + instance.Name,
+		"Instance ID: " + instance.InstanceID,
+		"State:       " + instance.State,
+		"Platform:    " + instance.Platform,
+		"Type:        " + instance.InstanceType,
+		"Lifecycle:   " + instance.Lifecycle,
+		"Private IP:  " + instance.PrivateIP,
+		"Public IP:   " + instance.PublicIP,
+		"Private DNS: " + instance.PrivateDNS,
+		"Computer:    " + instance.ComputerName,
+		"SSM agent:   " + agentSummary(instance),
+		"Last ping:   " + formatTime(instance.LastPingAt),
+		"VPC:         " + instance.VpcID,
+		"Subnet:      " + instance.SubnetID,
+		"Zone:        " + instance.AvailabilityZone,
+		"AMI:         " + instance.ImageID,
+		"Key pair:    " + instance.KeyName,
+		"IAM profile: " + instance.IAMInstanceProfile,
+		"Profile:     " + instance.Profile,
+		"Region:      " + instance.Region,
+		"Account:     " + instance.AccountID,
+		"Launched:    " + formatTime(instance.LaunchTime),
+		"Last seen:   " + formatTime(instance.LastSeen),
+	}
+	if len(tags) > 0 {
+		lines = append(lines, "", "Tags:")
+		for _, tag := range tags {
+			lines = append(lines, "  "+tag)
+		}
+	}
+	return lines
+}
+
+// agentSummary describes the SSM agent status and version of an instance
+func agentSummary(instance storage.Instance) string {
+	if instance.PingStatus == "" {
+		return "not registered"
+	}
+	summary := instance.PingStatus
+	if instance.AgentVersion != "" {
+		summary += ", version " + instance.AgentVersion
+		if !instance.IsLatestVersion {
+			summary += " (update available)"
+		}
+	}
+	if platform := strings.TrimSpace(instance.PlatformName + " " + instance.PlatformVersion); platform != "" {
+		summary += ", OS " + platform
+	}
+	return summary
+}
+
+// formatTime renders a timestamp in local time, or nothing when it is unset
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format(time.DateTime)
+}
+
+// promptPortMappings asks for the port forwards to open through instance
+func promptPortMappings(instance *storage.Instance) ([]service.PortMapping, error) {
+	fmt.Fprintf(os.Stderr, "Forward through %s (LOCAL:REMOTE or LOCAL:HOST:REMOTE, space separated): ", instance.DisplayName())
+	input, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("no port mapping given")
+	}
+
+	var mappings []service.PortMapping
+	for _, spec := range strings.Fields(input) {
+		mapping, err := service.ParsePortMapping(spec)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, mapping)
+	}
+	if len(mappings) == 0 {
+		return nil, fmt.Errorf("no port mapping given")
+	}
+	return mappings, nil
+}
+
+// copyToClipboard copies text with the platform clipboard tool, falling
+// back to the OSC 52 terminal escape sequence
+func copyToClipboard(text string) error {
+	var candidates [][]string
+	switch runtime.GOOS {
+	case "darwin":
+		candidates = [][]string{{"pbcopy"}}
+	case "windows":
+		candidates = [][]string{{"clip"}}
+	default:
+		candidates = [][]string{{"wl-copy"}, {"xclip", "-selection", "clipboard"}, {"xsel", "--clipboard", "--input"}}
+	}
+
+	for _, candidate := range candidates {
+		if _, err := exec.LookPath(candidate[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(candidate[0], candidate[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if err := cmd.Run(); err == nil {
+			return nil
+		}
+	}
+
+	_, err := fmt.Fprintf(os.Stderr, "\x1b]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(text)))
+	return err
+}
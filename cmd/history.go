@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/andreclaro/ssm/internal/service"
+	"github.com/spf13/cobra"
+)
+
+var historyLimit int
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show recent connections",
+	Long: `Show recent shell sessions, SSH connections, port forwards, SOCKS
+proxies and file copies, newest first. The history also ranks instance name
+resolution and shell completion, so the hosts you use most come first.`,
+	Args: cobra.NoArgs,
+	Run:  runHistory,
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "Number of connections to show")
+}
+
+func runHistory(cmd *cobra.Command, args []string) {
+	// Create service
+	svc, err := service.NewService()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create service: %v\n", err)
+		os.Exit(1)
+	}
+
+	connections, err := svc.ConnectionHistory(historyLimit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load history: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(connections) == 0 {
+		fmt.Println("No connections yet")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "WHEN\tNAME\tINSTANCE ID\tPROFILE\tREGION\tMODE\tDURATION\tDETAIL")
+	for _, c := range connections {
+		duration := "-"
+		if c.Duration > 0 {
+			duration = c.Duration.String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			c.StartedAt.Local().Format(time.DateTime),
+			c.InstanceName,
+			c.InstanceID,
+			c.Profile,
+			c.Region,
+			c.Mode,
+			duration,
+			c.Detail,
+		)
+	}
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/andreclaro/ssm/internal/service"
+	"github.com/spf13/cobra"
+)
+
+// lastCmd represents the last command
+var lastCmd = &cobra.Command{
+	Use:   "last",
+	Short: "Reconnect to the most recently used instance",
+	Long: `Open a shell session to the instance from the most recent entry in
+'ssm history', whatever kind of connection that was.`,
+	Args: cobra.NoArgs,
+	Run:  runLast,
+}
+
+func init() {
+	rootCmd.AddCommand(lastCmd)
+}
+
+func runLast(cmd *cobra.Command, args []string) {
+	// Create service
+	svc, err := service.NewService()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create service: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	if err := svc.ConnectToLast(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to instance: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/andreclaro/ssm/internal/service"
+	"github.com/andreclaro/ssm/internal/storage"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	lifecycleYes      bool
+	lifecycleTimeout  time.Duration
+	lifecycleSelector string
+	unprotect         bool
+)
+
+// startCmd represents the start command
+var startCmd = &cobra.Command{
+	Use:   "start <instance-name...|-t selector>",
+	Short: "Start stopped instances",
+	Long: `Start EC2 instances with the profile and region they were discovered in,
+then wait for them to be running.
+
+Examples:
+  ssm start devbox                 # Start devbox after confirming
+  ssm start devbox build-1 --yes   # Start several instances without prompting
+  ssm start -t env=dev             # Start every instance tagged env=dev`,
+	Args:              lifecycleArgs,
+	ValidArgsFunction: completeLifecycleTargets,
+	Run: func(cmd *cobra.Command, args []string) {
+		runLifecycle(args, service.LifecycleStart, "Start")
+	},
+}
+
+// stopCmd represents the stop command
+var stopCmd = &cobra.Command{
+	Use:   "stop <instance-name...|-t selector>",
+	Short: "Stop running instances",
+	Long: `Stop EC2 instances with the profile and region they were discovered in,
+then wait for them to be stopped.
+
+Examples:
+  ssm stop devbox         # Stop devbox after confirming
+  ssm stop devbox --yes   # Stop without prompting
+  ssm stop -t env=dev     # Stop every instance tagged env=dev`,
+	Args:              lifecycleArgs,
+	ValidArgsFunction: completeLifecycleTargets,
+	Run: func(cmd *cobra.Command, args []string) {
+		runLifecycle(args, service.LifecycleStop, "Stop")
+	},
+}
+
+// rebootCmd represents the reboot command
+var rebootCmd = &cobra.Command{
+	Use:   "reboot <instance-name...|-t selector>",
+	Short: "Reboot running instances",
+	Long: `Request a reboot of EC2 instances with the profile and region they were
+discovered in. Use 'ssm wait <name>' to wait for the SSM agent to return.
+
+Examples:
+  ssm reboot web-1 web-2
+  ssm reboot -t role=web`,
+	Args:              lifecycleArgs,
+	ValidArgsFunction: completeLifecycleTargets,
+	Run: func(cmd *cobra.Command, args []string) {
+		runLifecycle(args, service.LifecycleReboot, "Reboot")
+	},
+}
+
+// terminateProtectCmd represents the terminate-protect command
+var terminateProtectCmd = &cobra.Command{
+	Use:   "terminate-protect <instance-name...|-t selector>",
+	Short: "Enable or disable termination protection",
+	Long: `Enable EC2 termination protection (DisableApiTermination) on instances, or
+disable it with --off.
+
+Examples:
+  ssm terminate-protect db-1          # Protect db-1 from termination
+  ssm terminate-protect db-1 --off    # Allow db-1 to be terminated again`,
+	Args:              lifecycleArgs,
+	ValidArgsFunction: completeLifecycleTargets,
+	Run: func(cmd *cobra.Command, args []string) {
+		if unprotect {
+			runLifecycle(args, service.LifecycleUnprotect, "Disable termination protection on")
+			return
+		}
+		runLifecycle(args, service.LifecycleProtect, "Enable termination protection on")
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{startCmd, stopCmd, rebootCmd, terminateProtectCmd} {
+		rootCmd.AddCommand(c)
+		c.Flags().BoolVarP(&lifecycleYes, "yes", "y", false, "Do not ask for confirmation")
+		c.Flags().StringVarP(&lifecycleSelector, "tags", "t", "", "Also target every instance matching a tag selector")
+	}
+	for _, c := range []*cobra.Command{startCmd, stopCmd} {
+		c.Flags().DurationVar(&lifecycleTimeout, "timeout", service.DefaultStartTimeout, "Maximum time to wait for the new state")
+	}
+	terminateProtectCmd.Flags().BoolVar(&unprotect, "off", false, "Disable termination protection instead")
+}
+
+// lifecycleArgs requires instance names unless a tag selector is given
+func lifecycleArgs(cmd *cobra.Command, args []string) error {
+	if lifecycleSelector != "" {
+		return nil
+	}
+	return cobra.MinimumNArgs(1)(cmd, args)
+}
+
+// completeLifecycleTargets completes every argument with instance names
+func completeLifecycleTargets(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return CompleteInstanceNames(toComplete)
+}
+
+// runLifecycle resolves the targets, confirms the action and applies it
+func runLifecycle(names []string, action, verb string) {
+	// Create service
+	svc, err := service.NewService()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create service: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Resolve every target before changing anything
+	instances, err := svc.ResolveTargets(names, lifecycleSelector)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if !lifecycleYes && !confirmLifecycle(instances, verb) {
+		fmt.Fprintln(os.Stderr, "Aborted")
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	if err := svc.ChangeInstanceStates(ctx, instances, action, lifecycleTimeout, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+// confirmLifecycle lists the instances and asks the user to confirm. Without
+// a terminal to ask on, it refuses and points to --yes.
+func confirmLifecycle(instances []*storage.Instance, verb string) bool {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintln(os.Stderr, "Refusing to continue without confirmation; pass --yes")
+		return false
+	}
+
+	fmt.Fprintf(os.Stderr, "%s %d instance(s):\n", verb, len(instances))
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	for _, instance := range instances {
+		fmt.Fprintf(w, "  %s\t%s\t%s/%s\t%s\n", instance.DisplayName(), instance.InstanceID, instance.Profile, instance.Region, instance.State)
+	}
+	w.Flush()
+
+	fmt.Fprint(os.Stderr, "Continue? [y/N]: ")
+	input, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer := strings.ToLower(strings.TrimSpace(input))
+	return answer == "y" || answer == "yes"
+}
@@ -3,7 +3,6 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"strings"
 	"text/tabwriter"
 
 	"github.com/andreclaro/ssm/internal/service"
@@ -14,6 +13,7 @@ var (
 	listProfile string
 	listRegion  string
 	listAll     bool
+	listTags    string
 )
 
 // listCmd represents the list command
@@ -26,7 +26,8 @@ Examples:
   ssm list                              # List all instances
   ssm list --profile myprofile          # List instances for myprofile
   ssm list --region us-east-1           # List instances in us-east-1
-  ssm list --profile dev --region us-west-2  # List instances for dev profile in us-west-2`,
+  ssm list --profile dev --region us-west-2  # List instances for dev profile in us-west-2
+  ssm list -t env=prod,role=web         # List instances matching a tag selector`,
 	Run: runList,
 }
 
@@ -35,6 +36,7 @@ func init() {
 
 	listCmd.Flags().StringVar(&listProfile, "profile", "", "Filter by AWS profile")
 	listCmd.Flags().StringVar(&listRegion, "region", "", "Filter by AWS region")
+	listCmd.Flags().StringVarP(&listTags, "tags", "t", "", "Filter by tag selector, e.g. env=prod,role!=db")
 	listCmd.Flags().BoolVar(&listAll, "all", false, "Show all columns")
 }
 
@@ -56,17 +58,17 @@ func runList(cmd *cobra.Command, args []string) {
 	}
 
 	// List instances
-	instances, err := svc.ListInstances(profile, region)
+	instances, err := svc.ListInstances(profile, region, listTags)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to list instances: %v\n", err)
 		os.Exit(1)
 	}
 
-	// When not --all, only show SSM managed instances that are Online
+	// When not --all, only show instances whose SSM agent is Online
 	if !listAll {
 		j := 0
 		for _, inst := range instances {
-			if strings.EqualFold(inst.State, "Online") || strings.EqualFold(inst.State, "running") {
+			if inst.Reachable() {
 				instances[j] = inst
 				j++
 			}
@@ -86,7 +88,7 @@ func runList(cmd *cobra.Command, args []string) {
 
 	// Print header
 	if listAll {
-		fmt.Fprintln(w, "NAME\tINSTANCE ID\tREGION\tPROFILE\tACCOUNT ID\tSTATE\tPLATFORM")
+		fmt.Fprintln(w, "NAME\tINSTANCE ID\tTYPE\tREGION\tZONE\tPROFILE\tACCOUNT ID\tSTATE\tPLATFORM\tSSM AGENT\tPRIVATE IP\tPUBLIC IP\tVPC")
 	} else {
 		fmt.Fprintln(w, "NAME\tREGION\tPROFILE")
 	}
@@ -99,14 +101,20 @@ func runList(cmd *cobra.Command, args []string) {
 		}
 
 		if listAll {
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
 				name,
 				instance.InstanceID,
+				orDash(instance.InstanceType),
 				instance.Region,
+				orDash(instance.AvailabilityZone),
 				instance.Profile,
 				instance.AccountID,
 				instance.State,
 				instance.Platform,
+				orDash(instance.PingStatus),
+				orDash(instance.PrivateIP),
+				orDash(instance.PublicIP),
+				orDash(instance.VpcID),
 			)
 		} else {
 			fmt.Fprintf(w, "%s\t%s\t%s\n",
@@ -117,3 +125,11 @@ func runList(cmd *cobra.Command, args []string) {
 		}
 	}
 }
+
+// orDash returns s, or "-" when it is empty so table cells stay aligned
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
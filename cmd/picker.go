@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/andreclaro/ssm/internal/storage"
+)
+
+// pickInstance asks the user to choose between instances sharing a name
+func pickInstance(name string, candidates []storage.Instance) (*storage.Instance, error) {
+	fmt.Fprintf(os.Stderr, "'%s' matches %d instances:\n", name, len(candidates))
+
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  #\tNAME\tPROFILE\tREGION\tACCOUNT ID\tSTATE\tINSTANCE ID")
+	for i, c := range candidates {
+		fmt.Fprintf(w, "  %d\t%s\t%s\t%s\t%s\t%s\t%s\n", i+1, c.DisplayName(), c.Profile, c.Region, c.AccountID, c.State, c.InstanceID)
+	}
+	w.Flush()
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Fprintf(os.Stderr, "Select instance [1-%d]: ", len(candidates))
+		input, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("no instance selected")
+		}
+
+		choice, err := strconv.Atoi(strings.TrimSpace(input))
+		if err == nil && choice >= 1 && choice <= len(candidates) {
+			return &candidates[choice-1], nil
+		}
+		fmt.Fprintln(os.Stderr, "Invalid selection")
+	}
+}
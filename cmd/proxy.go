@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/andreclaro/ssm/internal/service"
+	"github.com/spf13/cobra"
+)
+
+var (
+	proxyPushKey  string
+	proxyIdentity string
+	proxySelector string
+)
+
+// proxyCmd represents the proxy command
+var proxyCmd = &cobra.Command{
+	Use:   "proxy <host|-t selector> <port> [--push-key user]",
+	Short: "Relay an SSH connection over SSM (for use as ProxyCommand)",
+	Long: `Resolve host through the local instance database and relay stdin/stdout
+to the given port on the instance over an AWS-StartSSHSession stream.
+
+This is meant to be used as an SSH ProxyCommand, so ssh, scp, rsync and IDE
+remote extensions can reach SSM-managed hosts by name. See 'ssm ssh-config'
+to generate the matching ~/.ssh/config entries.
+
+With --push-key the public key of --identity (by default the key generated by
+'ssm ssh') is first authorized for the user with EC2 Instance Connect, so no
+long-lived key has to be installed on the instance.
+
+With -t the instance matching a tag selector is used and only the port is
+given.
+
+Examples:
+  # ~/.ssh/config
+  Host web-1
+    ProxyCommand ssm proxy %h %p
+
+  ssh -o ProxyCommand='ssm proxy %h %p' ec2-user@web-1
+  ssh -o ProxyCommand='ssm proxy -t role=bastion %p' ec2-user@bastion
+  scp -o ProxyCommand='ssm proxy --push-key %r %h %p' -i ~/.ssm/ssh/id_ed25519 app.tar.gz ec2-user@web-1:`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if proxySelector != "" {
+			return cobra.ExactArgs(1)(cmd, args)
+		}
+		return cobra.ExactArgs(2)(cmd, args)
+	},
+	Run: runProxy,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 && proxySelector == "" {
+			return CompleteInstanceNames(toComplete)
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(proxyCmd)
+
+	proxyCmd.Flags().StringVar(&proxyPushKey, "push-key", "", "Push the SSH public key for this user with EC2 Instance Connect")
+	proxyCmd.Flags().StringVarP(&proxyIdentity, "identity", "i", "", "Private key whose public key is pushed (default: the ssm key)")
+	proxyCmd.Flags().StringVarP(&proxySelector, "tags", "t", "", "Target the instance matching a tag selector instead of a host")
+}
+
+func runProxy(cmd *cobra.Command, args []string) {
+	host, port, err := parseProxyArgs(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	// Create service
+	svc, err := service.NewService()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create service: %v\n", err)
+		os.Exit(1)
+	}
+
+	// stdout carries the SSH stream, so all diagnostics go to stderr
+	instance, err := svc.ResolveTarget(host, proxySelector)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to proxy to instance: %v\n", err)
+		os.Exit(1)
+	}
+
+	var key *service.SSHKey
+	if proxyPushKey != "" {
+		key = &service.SSHKey{User: proxyPushKey, Identity: proxyIdentity}
+	}
+
+	ctx := context.Background()
+	if err := svc.ProxyToInstance(ctx, instance, port, key, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to proxy to instance: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// parseProxyArgs splits the [host] port arguments of proxy; the host is
+// omitted when a tag selector names the instance
+func parseProxyArgs(args []string) (string, int, error) {
+	var host string
+	if len(args) > 1 {
+		host = args[0]
+	}
+	portArg := args[len(args)-1]
+	port, err := strconv.Atoi(portArg)
+	if err != nil || port <= 0 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid port '%s'", portArg)
+	}
+	return host, port, nil
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"golang.org/x/term"
 )
 
 var cfgFile string
@@ -22,6 +23,10 @@ var quickRemoveRegion string
 var quickAddProfile string
 var quickRemoveProfile string
 var portMaps []string
+var tagSelector string
+var sessionDocument string
+var sessionParameters []string
+var startStopped bool
 
 // completionCmd represents the completion command
 var completionCmd = &cobra.Command{
@@ -85,26 +90,57 @@ PowerShell:
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-	Use:   "ssm [instance-name]",
+	Use:   "ssm [instance-name] [-- command]",
 	Short: "AWS SSM CLI tool for managing EC2 instances across accounts and regions",
 	Long: `A CLI tool for discovering and connecting to EC2 instances using AWS Systems Manager (SSM)
 across multiple AWS accounts and regions.
 
 When called with an instance name, it will connect to that instance via Session Manager.
-When called without arguments, it shows help.
+When called without arguments in a terminal, it opens a fuzzy finder over the
+cached instances (search by name, ID, tags, profile or region); press enter to
+connect, ctrl-f to forward ports or ctrl-y to copy the instance ID. Otherwise
+it shows help.
+
+A command after -- runs in an interactive session (AWS-StartInteractiveCommand)
+instead of a shell. --document starts the session with another Session Manager
+document, such as one that runs as a named user; pass its parameters with
+--parameters KEY=VALUE (repeatable).
+
+Instead of a name, -t selects the instance by tags. A selector is a
+comma-separated list of terms that must all hold: key=value, key!=value
+(also matches instances without the tag), key (tag exists) and !key (tag
+absent). Keys and values may use the glob wildcards * and ?.
 
 Examples:
-  ssm                                # Show help
+  ssm                                # Pick an instance with the fuzzy finder
   ssm my-instance-name               # Connect to instance via Session Manager
+  ssm my-instance-name -L 8888:80    # Forward local port 8888 to port 80 on the instance
+  ssm bastion -L 5432:db.internal:5432  # Forward local port 5432 to a host behind the instance
+  ssm -t env=prod,role=web           # Connect to the instance tagged env=prod and role=web
+  ssm db1 -- sudo journalctl -f      # Run an interactive command instead of a shell
+  ssm devbox --start                 # Start the instance first if it is stopped
+  ssm db1 --document Org-RunAsDeploy --parameters user=deploy  # Use a custom session document
   ssm list                           # List all instances
   ssm list --region us-east-1        # List instances in us-east-1
   ssm list --profile myprofile       # List instances for myprofile
   ssm sync                           # Sync instances from AWS`,
-	Args: cobra.MaximumNArgs(1),
-	Run:  runConnect,
+	Args: func(cmd *cobra.Command, args []string) error {
+		dash := cmd.ArgsLenAtDash()
+		if dash < 0 {
+			return cobra.MaximumNArgs(1)(cmd, args)
+		}
+		if dash > 1 {
+			return fmt.Errorf("accepts at most 1 instance before --, received %d", dash)
+		}
+		if dash == len(args) {
+			return fmt.Errorf("a command is required after --")
+		}
+		return nil
+	},
+	Run: runConnect,
 	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		// Provide completion for instance names
-		if len(args) == 0 {
+		if len(args) == 0 && cmd.ArgsLenAtDash() < 0 {
 			return CompleteInstanceNames(toComplete)
 		}
 		return nil, cobra.ShellCompDirectiveNoFileComp
@@ -124,6 +160,11 @@ Examples:
 			}
 		}
 
+		// Let the user disambiguate instance names when running interactively
+		if term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stderr.Fd())) {
+			service.InstancePicker = pickInstance
+		}
+
 		// Handle quick add/remove flags for profiles and regions, then exit immediately
 		if quickAddRegion != "" || quickRemoveRegion != "" || quickAddProfile != "" || quickRemoveProfile != "" {
 			var hadError bool
@@ -420,32 +461,31 @@ func setupRegions() error {
 
 // CompleteInstanceNames provides shell completion for instance names
 func CompleteInstanceNames(toComplete string) ([]string, cobra.ShellCompDirective) {
-	// Initialize database if not already done
-	if storage.DB == nil {
-		if err := config.InitConfig(""); err != nil {
-			return nil, cobra.ShellCompDirectiveNoFileComp
-		}
-		if err := storage.InitDB(); err != nil {
-			return nil, cobra.ShellCompDirectiveNoFileComp
-		}
-	}
-
-	// Query database for instance names
-	var instances []storage.Instance
-	query := storage.DB.Select("name").Where("name LIKE ?", toComplete+"%")
-	if err := query.Find(&instances).Error; err != nil {
+	if !initCompletionDB() {
 		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
 
-	// Extract names
-	var names []string
-	for _, instance := range instances {
-		names = append(names, instance.Name)
+	// Query database for instance names, most used first
+	names, err := storage.NewInstanceRepository().CompleteNames(toComplete)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
 
 	return names, cobra.ShellCompDirectiveNoFileComp
 }
 
+// initCompletionDB initializes the database for shell completion, which runs
+// without the root command's PersistentPreRun
+func initCompletionDB() bool {
+	if storage.DB != nil {
+		return true
+	}
+	if err := config.InitConfig(""); err != nil {
+		return false
+	}
+	return storage.InitDB() == nil
+}
+
 // parseCommaSeparatedInts parses comma-separated integers from a string
 func parseCommaSeparatedInts(input string) []int {
 	var result []int
@@ -465,13 +505,51 @@ func parseCommaSeparatedInts(input string) []int {
 
 // runConnect handles connecting to an instance when an instance name is provided
 func runConnect(cmd *cobra.Command, args []string) {
-	if len(args) == 0 {
-		// No arguments provided, show help
-		cmd.Help()
+	if len(args) == 0 && tagSelector == "" {
+		// No arguments provided: pick an instance interactively in a
+		// terminal, otherwise show help
+		if len(portMaps) > 0 || !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stderr.Fd())) {
+			cmd.Help()
+			return
+		}
+		svc, err := service.NewService()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create service: %v\n", err)
+			os.Exit(1)
+		}
+		if err := runFinder(svc); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
 		return
 	}
 
-	instanceName := args[0]
+	var instanceName, command string
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		command = shellJoin(args[dash:])
+		args = args[:dash]
+	}
+	if len(args) > 0 {
+		instanceName = args[0]
+	}
+	if instanceName == "" && tagSelector == "" {
+		fmt.Fprintln(os.Stderr, "An instance name or a tag selector (-t) is required")
+		os.Exit(1)
+	}
+	if instanceName != "" && tagSelector != "" {
+		fmt.Fprintln(os.Stderr, "Specify either an instance name or a tag selector (-t), not both")
+		os.Exit(1)
+	}
+
+	opts, err := sessionOptions(command)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if len(portMaps) > 0 && (opts.Document != "" || len(opts.Parameters) > 0) {
+		fmt.Fprintln(os.Stderr, "Port forwarding (-L) cannot be combined with a command, --document or --parameters")
+		os.Exit(1)
+	}
 
 	// Create service
 	svc, err := service.NewService()
@@ -480,40 +558,95 @@ func runConnect(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	// Connect to instance or start port forwarding if requested
+	instance, err := svc.ResolveTarget(instanceName, tagSelector)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
 	ctx := context.Background()
+	if startStopped {
+		if err := svc.EnsureOnline(ctx, instance, service.DefaultStartTimeout, os.Stderr); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to start instance: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Connect to instance or start port forwarding if requested
 	if len(portMaps) > 0 {
 		var mappings []service.PortMapping
 		for _, m := range portMaps {
-			parts := strings.SplitN(m, ":", 2)
-			if len(parts) != 2 {
-				fmt.Fprintf(os.Stderr, "Invalid port mapping '%s'. Use LOCAL:REMOTE (e.g., 8888:80)\n", m)
-				os.Exit(1)
-			}
-			var localPort, remotePort int
-			if _, err := fmt.Sscanf(parts[0], "%d", &localPort); err != nil || localPort <= 0 || localPort > 65535 {
-				fmt.Fprintf(os.Stderr, "Invalid local port in mapping '%s'\n", m)
-				os.Exit(1)
-			}
-			if _, err := fmt.Sscanf(parts[1], "%d", &remotePort); err != nil || remotePort <= 0 || remotePort > 65535 {
-				fmt.Fprintf(os.Stderr, "Invalid remote port in mapping '%s'\n", m)
+			mapping, err := service.ParsePortMapping(m)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
 				os.Exit(1)
 			}
-			mappings = append(mappings, service.PortMapping{LocalPort: localPort, RemotePort: remotePort})
+			mappings = append(mappings, mapping)
 		}
-		if err := svc.PortForwardToInstanceMultiple(ctx, instanceName, mappings); err != nil {
+		if err := svc.RunTunnelTo(ctx, instance, mappings, ""); err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to start port forwarding: %v\n", err)
 			os.Exit(1)
 		}
 		return
 	}
 
-	if err := svc.ConnectToInstance(ctx, instanceName); err != nil {
+	if err := svc.Connect(ctx, instance, opts); err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to connect to instance: %v\n", err)
+		if strings.EqualFold(instance.State, "stopped") && !startStopped {
+			fmt.Fprintln(os.Stderr, "The instance is stopped; rerun with --start to start it and connect once it is online")
+		}
 		os.Exit(1)
 	}
 }
 
+// shellJoin joins command arguments into a shell command line, quoting the
+// words that need it so each argument reaches the command intact
+func shellJoin(args []string) string {
+	words := make([]string, len(args))
+	for i, arg := range args {
+		words[i] = arg
+		if arg == "" || strings.ContainsFunc(arg, needsQuoting) {
+			words[i] = aws.ShellQuote(arg)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// needsQuoting reports whether r is special to a POSIX shell
+func needsQuoting(r rune) bool {
+	return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./=:,+@%", r))
+}
+
+// sessionOptions builds the session document options from --document,
+// --parameters and a command given after --. Without --document a command
+// runs through AWS-StartInteractiveCommand; with it, the command is passed
+// as the document's "command" parameter.
+func sessionOptions(command string) (aws.SessionOptions, error) {
+	params, err := service.ParseSessionParameters(sessionParameters)
+	if err != nil {
+		return aws.SessionOptions{}, err
+	}
+	if len(params) > 0 && sessionDocument == "" && command == "" {
+		return aws.SessionOptions{}, fmt.Errorf("--parameters requires --document or a command")
+	}
+
+	if command == "" {
+		return aws.SessionOptions{Document: sessionDocument, Parameters: params}, nil
+	}
+
+	opts := aws.InteractiveCommand(command)
+	if sessionDocument != "" {
+		opts.Document = sessionDocument
+	}
+	for key, values := range params {
+		if key == "command" {
+			return aws.SessionOptions{}, fmt.Errorf("pass the command after -- rather than as a parameter")
+		}
+		opts.Parameters[key] = values
+	}
+	return opts, nil
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
 
@@ -529,8 +662,14 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&quickRemoveRegion, "remove-region", "", "Disable a region for discovery and exit")
 	rootCmd.PersistentFlags().StringVar(&quickAddProfile, "add-profile", "", "Enable a profile for discovery and exit")
 	rootCmd.PersistentFlags().StringVar(&quickRemoveProfile, "remove-profile", "", "Disable a profile for discovery and exit")
+	// Tag selector targeting, instead of an instance name
+	rootCmd.Flags().StringVarP(&tagSelector, "tags", "t", "", "Target the instance matching a tag selector instead of a name, e.g. env=prod,role=web")
+	// Session flags
+	rootCmd.Flags().BoolVar(&startStopped, "start", false, "Start the instance if it is stopped and wait for it to come online")
+	rootCmd.Flags().StringVar(&sessionDocument, "document", "", "Session Manager document to start the session with (default: standard shell)")
+	rootCmd.Flags().StringArrayVar(&sessionParameters, "parameters", nil, "Session document parameter KEY=VALUE (repeatable)")
 	// Port forwarding flags (repeatable). Use --forward/-L
-	rootCmd.Flags().StringArrayVarP(&portMaps, "forward", "L", nil, "Local:remote port forward (repeatable), e.g., -L 8888:80")
+	rootCmd.Flags().StringArrayVarP(&portMaps, "forward", "L", nil, "Port forward LOCAL:REMOTE or LOCAL:HOST:REMOTE (repeatable), e.g., -L 8888:80 -L 5432:db.internal:5432")
 
 	// Bind flags to viper
 	viper.BindPFlag("aws.profile", rootCmd.PersistentFlags().Lookup("profile"))
@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestShellJoin tests that command arguments keep their word boundaries
+func TestShellJoin(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"sudo", "journalctl", "-f"}, "sudo journalctl -f"},
+		{[]string{"grep", "-r", "two words", "/etc"}, "grep -r 'two words' /etc"},
+		{[]string{"echo", "it's"}, `echo 'it'\''s'`},
+		{[]string{"echo", "$HOME", "a;b", "*"}, `echo '$HOME' 'a;b' '*'`},
+		{[]string{"printf", ""}, "printf ''"},
+		{[]string{"env", "LANG=C.UTF-8", "date", "+%F"}, "env LANG=C.UTF-8 date +%F"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, shellJoin(tt.args), tt.args)
+	}
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/andreclaro/ssm/internal/aws"
+	"github.com/andreclaro/ssm/internal/service"
+	"github.com/andreclaro/ssm/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sessionsProfile string
+	sessionsRegion  string
+	sessionsRecent  time.Duration
+)
+
+// sessionsCmd represents the sessions command
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "List active Session Manager sessions",
+	Long: `List active Session Manager sessions across the enabled profiles and
+regions, including sessions opened by other tools and users. With --recent,
+list the sessions that ended within that period instead.
+
+Sessions orphaned by a crashed terminal or a dropped port forward can be
+terminated with 'ssm sessions kill <session-id>'.
+
+Examples:
+  ssm sessions                      # Active sessions
+  ssm sessions --recent 24h         # Sessions that ended in the last day
+  ssm sessions --profile prod       # Only sessions visible to the prod profile
+  ssm sessions kill jane-0a1b2c3d4e5f6a7b8`,
+	Args: cobra.NoArgs,
+	Run:  runSessions,
+}
+
+// sessionsKillCmd represents the sessions kill command
+var sessionsKillCmd = &cobra.Command{
+	Use:   "kill <session-id...>",
+	Short: "Terminate active sessions",
+	Args:  cobra.MinimumNArgs(1),
+	Run:   runSessionsKill,
+}
+
+func init() {
+	rootCmd.AddCommand(sessionsCmd)
+	sessionsCmd.AddCommand(sessionsKillCmd)
+
+	sessionsCmd.PersistentFlags().StringVar(&sessionsProfile, "profile", "", "Only query this AWS profile")
+	sessionsCmd.PersistentFlags().StringVar(&sessionsRegion, "region", "", "Only query this AWS region")
+	sessionsCmd.Flags().DurationVar(&sessionsRecent, "recent", 0, "List sessions that ended within this period, e.g. 24h")
+}
+
+// sessionsFilter returns the --profile and --region filters
+func sessionsFilter() (profile, region *string) {
+	if sessionsProfile != "" {
+		profile = &sessionsProfile
+	}
+	if sessionsRegion != "" {
+		region = &sessionsRegion
+	}
+	return profile, region
+}
+
+func runSessions(cmd *cobra.Command, args []string) {
+	// Create service
+	svc, err := service.NewService()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create service: %v\n", err)
+		os.Exit(1)
+	}
+
+	var since time.Time
+	if sessionsRecent > 0 {
+		since = time.Now().Add(-sessionsRecent)
+	}
+
+	profile, region := sessionsFilter()
+	sessions, err := svc.ListSessions(context.Background(), profile, region, since)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to list sessions: %v\n", err)
+		os.Exit(1)
+	}
+	if len(sessions) == 0 {
+		fmt.Println("No sessions found")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	if sessionsRecent > 0 {
+		fmt.Fprintln(w, "SESSION ID\tTARGET\tOWNER\tSTARTED\tENDED\tDOCUMENT\tSTATUS\tPROFILE/REGION")
+	} else {
+		fmt.Fprintln(w, "SESSION ID\tTARGET\tOWNER\tSTARTED\tDOCUMENT\tSTATUS\tPROFILE/REGION")
+	}
+
+	repo := storage.NewInstanceRepository()
+	for _, session := range sessions {
+		started := session.StartDate.Local().Format(time.DateTime)
+		columns := []string{session.SessionID, sessionTarget(repo, session), sessionOwner(session.Owner), started}
+		if sessionsRecent > 0 {
+			columns = append(columns, session.EndDate.Local().Format(time.DateTime))
+		}
+		columns = append(columns, sessionDocumentName(session), session.Status, session.Profile+"/"+session.Region)
+		fmt.Fprintln(w, strings.Join(columns, "\t"))
+	}
+}
+
+func runSessionsKill(cmd *cobra.Command, args []string) {
+	// Create service
+	svc, err := service.NewService()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create service: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	profile, region := sessionsFilter()
+	failed := false
+	for _, sessionID := range args {
+		session, err := svc.KillSession(ctx, sessionID, profile, region)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", sessionID, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("Terminated session %s on %s\n", session.SessionID, session.Target)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// sessionTarget labels a session target with the cached instance name
+func sessionTarget(repo *storage.InstanceRepository, session aws.SessionInfo) string {
+	instance, err := repo.FindByID(session.Target)
+	if err != nil || instance == nil || instance.Name == "" {
+		return session.Target
+	}
+	return fmt.Sprintf("%s (%s)", instance.Name, session.Target)
+}
+
+// sessionOwner shortens an owner ARN to its resource part, e.g.
+// assumed-role/Admin/jane
+func sessionOwner(owner string) string {
+	if idx := strings.LastIndex(owner, ":"); idx >= 0 {
+		return owner[idx+1:]
+	}
+	return owner
+}
+
+// sessionDocumentName names the session document; shell sessions have none
+func sessionDocumentName(session aws.SessionInfo) string {
+	if session.Document == "" {
+		return "(shell)"
+	}
+	return session.Document
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/andreclaro/ssm/internal/service"
+	"github.com/spf13/cobra"
+)
+
+var showSelector string
+
+// showCmd represents the show command
+var showCmd = &cobra.Command{
+	Use:   "show <instance|-t selector>",
+	Short: "Show the discovered details of an instance",
+	Long: `Show everything discovery recorded about an instance: its state and
+platform, network placement (IPs, VPC, subnet, availability zone), hardware
+(instance type, AMI, lifecycle), key pair, IAM instance profile and tags.
+
+The details are read from the local database; run 'ssm sync' to refresh them.
+
+Examples:
+  ssm show web-1
+  ssm show 10.0.1.12
+  ssm show -t role=bastion`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if showSelector != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	Run: runShow,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return CompleteInstanceNames(toComplete)
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(showCmd)
+
+	showCmd.Flags().StringVarP(&showSelector, "tags", "t", "", "Target the instance matching a tag selector instead of a name")
+}
+
+func runShow(cmd *cobra.Command, args []string) {
+	var instanceName string
+	if len(args) > 0 {
+		instanceName = args[0]
+	}
+
+	// Create service
+	svc, err := service.NewService()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create service: %v\n", err)
+		os.Exit(1)
+	}
+
+	instance, err := svc.ResolveTarget(instanceName, showSelector)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	tags := make([]string, 0, len(instance.Tags))
+	for _, tag := range instance.Tags {
+		tags = append(tags, tag.Key+"="+tag.Value)
+	}
+	sort.Strings(tags)
+
+	for _, line := range instancePreview(*instance, tags) {
+		fmt.Println(line)
+	}
+}
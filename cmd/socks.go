@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/andreclaro/ssm/internal/service"
+	"github.com/spf13/cobra"
+)
+
+var (
+	socksListen   string
+	socksSelector string
+)
+
+// socksCmd represents the socks command
+var socksCmd = &cobra.Command{
+	Use:   "socks <instance|-t selector>",
+	Short: "Run a SOCKS5 proxy through an instance",
+	Long: `Run a local SOCKS5 proxy that reaches hosts through an instance. Each
+CONNECT opens its own AWS-StartPortForwardingSessionToRemoteHost session, so
+any host:port reachable from the instance can be browsed without declaring
+forwards up front. Point a browser or curl --socks5-hostname at the proxy.
+
+--listen takes a port or host:port and binds to 127.0.0.1 unless a host is
+given. Requires the native session client.
+
+Examples:
+  ssm socks bastion                           # Listen on 127.0.0.1:1080
+  ssm socks bastion --listen 9050
+  ssm socks -t role=bastion
+  curl --socks5-hostname localhost:1080 http://grafana.internal:3000`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if socksSelector != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	Run: runSocks,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return CompleteInstanceNames(toComplete)
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(socksCmd)
+
+	socksCmd.Flags().StringVar(&socksListen, "listen", "1080", "Local port or host:port to listen on")
+	socksCmd.Flags().StringVarP(&socksSelector, "tags", "t", "", "Target the instance matching a tag selector instead of a name")
+}
+
+func runSocks(cmd *cobra.Command, args []string) {
+	var instanceName string
+	if len(args) > 0 {
+		instanceName = args[0]
+	}
+
+	listenAddr := socksListen
+	if _, err := strconv.Atoi(listenAddr); err == nil {
+		listenAddr = net.JoinHostPort("127.0.0.1", listenAddr)
+	}
+
+	// Create service
+	svc, err := service.NewService()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create service: %v\n", err)
+		os.Exit(1)
+	}
+
+	instance, err := svc.ResolveTarget(instanceName, socksSelector)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	if err := svc.SocksProxy(ctx, instance, listenAddr, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to run SOCKS proxy: %v\n", err)
+		os.Exit(1)
+	}
+}
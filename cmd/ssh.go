@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/andreclaro/ssm/internal/aws"
+	"github.com/andreclaro/ssm/internal/service"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	sshIdentity string
+	sshSelector string
+)
+
+// sshCmd represents the ssh command
+var sshCmd = &cobra.Command{
+	Use:   "ssh [user@]<instance> [-- ssh args...]",
+	Short: "SSH to an instance with a key pushed by EC2 Instance Connect",
+	Long: `Run ssh against an instance over an AWS-StartSSHSession stream. Right before
+connecting, a public key is authorized for the login user for 60 seconds with
+EC2 Instance Connect, so agent forwarding, X11, port forwards and remote
+commands work without distributing long-lived keys.
+
+The key is ~/.ssm/ssh/id_ed25519, generated on first use, unless --identity
+is given. The login user defaults to the value of the instance's ssh-user tag
+(see ssh.user_tag), then to the default user of its platform (ubuntu, admin,
+ec2-user, ...). Arguments after -- are passed to ssh after the destination.
+
+The instance needs an SSH server and the EC2 Instance Connect package, which
+is preinstalled on Amazon Linux and Ubuntu AMIs.
+
+Examples:
+  ssm ssh web-1
+  ssm ssh ubuntu@web-1 -- -A               # Forward the SSH agent
+  ssm ssh web-1 -- -X xclock               # X11 forwarding
+  ssm ssh web-1 -- -L 5432:db.internal:5432 -N
+  ssm ssh -t role=bastion -- uptime`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		targets := len(args)
+		if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+			targets = dash
+		}
+		if sshSelector != "" {
+			if targets > 1 || (targets == 1 && !strings.HasSuffix(args[0], "@")) {
+				return fmt.Errorf("give either an instance or --tags, not both (use user@ to set the user)")
+			}
+			return nil
+		}
+		if targets != 1 {
+			return fmt.Errorf("accepts 1 instance before --, received %d", targets)
+		}
+		return nil
+	},
+	Run: runSSH,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 || cmd.ArgsLenAtDash() >= 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		user, name, ok := strings.Cut(toComplete, "@")
+		if !ok {
+			return CompleteInstanceNames(toComplete)
+		}
+		names, directive := CompleteInstanceNames(name)
+		for i := range names {
+			names[i] = user + "@" + names[i]
+		}
+		return names, directive
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sshCmd)
+
+	sshCmd.Flags().StringVarP(&sshIdentity, "identity", "i", "", "Private key to authenticate with; its .pub file is pushed (default: the ssm key)")
+	sshCmd.Flags().StringVarP(&sshSelector, "tags", "t", "", "Target the instance matching a tag selector instead of a name")
+}
+
+func runSSH(cmd *cobra.Command, args []string) {
+	var sshArgs []string
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		sshArgs = args[dash:]
+		args = args[:dash]
+	}
+
+	var user, instanceName string
+	if len(args) > 0 {
+		instanceName = args[0]
+		if u, name, ok := strings.Cut(instanceName, "@"); ok {
+			user, instanceName = u, name
+		}
+	}
+
+	// Create service
+	svc, err := service.NewService()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create service: %v\n", err)
+		os.Exit(1)
+	}
+
+	instance, err := svc.ResolveTarget(instanceName, sshSelector)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if user == "" {
+		if user, err = service.SSHUser(instance); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// The qualified instance ID pins the proxy to the instance resolved here
+	proxyArgs := []string{"proxy", "--push-key", user}
+	if sshIdentity != "" {
+		proxyArgs = append(proxyArgs, "--identity", sshIdentity)
+	}
+	if cfgFile != "" {
+		proxyArgs = append(proxyArgs, "--config", cfgFile)
+	}
+	if viper.GetBool("verbose") {
+		proxyArgs = append(proxyArgs, "--verbose")
+	}
+	proxyArgs = append(proxyArgs, instance.Profile+"/"+instance.Region+"/"+instance.InstanceID)
+
+	ctx := context.Background()
+	err = svc.SSH(ctx, instance, service.SSHOptions{
+		User:         user,
+		Identity:     sshIdentity,
+		ProxyCommand: proxyCommand(proxyArgs),
+		Args:         sshArgs,
+	})
+
+	// ssh reports its own errors; pass its exit status through
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.ExitCode())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to run ssh: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// proxyCommand renders an ssh ProxyCommand running this binary with args and
+// the port as the final argument. Arguments are quoted for the shell ssh runs
+// the command with, and '%' is escaped from ssh's token expansion.
+func proxyCommand(args []string) string {
+	// Use the absolute path of this binary so the command works outside PATH
+	executable, err := os.Executable()
+	if err != nil {
+		executable = "ssm"
+	}
+
+	quoted := []string{aws.ShellQuote(executable)}
+	for _, arg := range args {
+		quoted = append(quoted, aws.ShellQuote(arg))
+	}
+	return strings.ReplaceAll(strings.Join(quoted, " "), "%", "%%") + " %p"
+}
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andreclaro/ssm/internal/aws"
+	"github.com/andreclaro/ssm/internal/service"
+	"github.com/andreclaro/ssm/internal/storage"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sshConfigProfile string
+	sshConfigRegion  string
+	sshConfigOutput  string
+	sshConfigAll     bool
+	sshConfigPushKey bool
+)
+
+// sshConfigCmd represents the ssh-config command
+var sshConfigCmd = &cobra.Command{
+	Use:   "ssh-config",
+	Short: "Generate ssh_config Host entries for discovered instances",
+	Long: `Generate an ssh_config Host block for every discovered instance, named as in
+'ssm list', that connects through 'ssm proxy'. Instances without a usable name,
+or whose name an earlier instance already took, are named by instance ID.
+
+Include the generated file from ~/.ssh/config to use plain ssh, scp, rsync
+and IDE remote extensions against SSM-managed hosts by name.
+
+With --push-key every connection first authorizes the ssm key (generated on
+first use) with EC2 Instance Connect, as 'ssm ssh' does, and each entry sets
+the login user chosen by 'ssm ssh'.
+
+Examples:
+  ssm ssh-config                                  # Print entries to stdout
+  ssm ssh-config --output ~/.ssh/config.d/ssm     # Write entries to a file
+  ssm ssh-config --profile prod                   # Only instances for prod
+  ssm ssh-config --push-key                       # Log in with pushed keys
+
+  # ~/.ssh/config
+  Include ~/.ssh/config.d/ssm`,
+	Args: cobra.NoArgs,
+	Run:  runSSHConfig,
+}
+
+func init() {
+	rootCmd.AddCommand(sshConfigCmd)
+
+	sshConfigCmd.Flags().StringVar(&sshConfigProfile, "profile", "", "Filter by AWS profile")
+	sshConfigCmd.Flags().StringVar(&sshConfigRegion, "region", "", "Filter by AWS region")
+	sshConfigCmd.Flags().StringVarP(&sshConfigOutput, "output", "o", "", "Write entries to this file instead of stdout")
+	sshConfigCmd.Flags().BoolVar(&sshConfigAll, "all", false, "Include instances whose SSM agent is not Online")
+	sshConfigCmd.Flags().BoolVar(&sshConfigPushKey, "push-key", false, "Push the ssm key with EC2 Instance Connect on every connection")
+}
+
+func runSSHConfig(cmd *cobra.Command, args []string) {
+	// Create service
+	svc, err := service.NewService()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create service: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Prepare filters
+	var profile, region *string
+	if sshConfigProfile != "" {
+		profile = &sshConfigProfile
+	}
+	if sshConfigRegion != "" {
+		region = &sshConfigRegion
+	}
+
+	instances, err := svc.ListInstances(profile, region, "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to list instances: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Use the absolute path of this binary so the entries work outside PATH
+	executable, err := os.Executable()
+	if err != nil {
+		executable = "ssm"
+	}
+
+	var keyPath string
+	if sshConfigPushKey {
+		if keyPath, err = service.EnsureSSHKey(""); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var out io.Writer = os.Stdout
+	if sshConfigOutput != "" {
+		if err := os.MkdirAll(filepath.Dir(sshConfigOutput), 0700); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create directory: %v\n", err)
+			os.Exit(1)
+		}
+		f, err := os.Create(sshConfigOutput)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create %s: %v\n", sshConfigOutput, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	count := writeSSHConfig(out, instances, executable, keyPath, sshConfigAll)
+
+	if sshConfigOutput != "" {
+		fmt.Printf("Wrote %d host(s) to %s\n", count, sshConfigOutput)
+	}
+}
+
+// writeSSHConfig writes one Host block per instance and returns the number of
+// blocks written. Hosts are named after the instance; when a name is shared by
+// several instances the first (in list order) keeps it and the others are
+// named by instance ID. When keyPath is set, the entries authenticate with it
+// and push it before connecting.
+func writeSSHConfig(w io.Writer, instances []storage.Instance, executable, keyPath string, all bool) int {
+	fmt.Fprintln(w, "# Generated by 'ssm ssh-config'. Changes will be overwritten.")
+
+	seen := make(map[string]bool)
+	count := 0
+	for _, instance := range instances {
+		if !all && !instance.Reachable() {
+			continue
+		}
+
+		host := instance.Name
+		// ssh_config host patterns cannot contain whitespace
+		if host == "" || strings.ContainsAny(host, " \t") {
+			host = instance.InstanceID
+		} else if seen[host] {
+			logrus.WithFields(logrus.Fields{
+				"name":        host,
+				"instance_id": instance.InstanceID,
+			}).Warn("Name is shared by several instances; using the instance ID as the host name")
+			host = instance.InstanceID
+		}
+		if seen[host] {
+			continue
+		}
+		seen[host] = true
+
+		// The qualified instance ID pins the proxy to this instance: ssh runs
+		// it without a terminal, so an ambiguous name could not be picked
+		target := strings.ReplaceAll(aws.ShellQuote(instance.Profile+"/"+instance.Region+"/"+instance.InstanceID), "%", "%%")
+
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "# %s (%s/%s)\n", instance.InstanceID, instance.Profile, instance.Region)
+		fmt.Fprintf(w, "Host %s\n", host)
+		if keyPath == "" {
+			fmt.Fprintf(w, "  ProxyCommand \"%s\" proxy %s %%p\n", executable, target)
+		} else {
+			if user, err := service.SSHUser(&instance); err == nil {
+				fmt.Fprintf(w, "  User %s\n", user)
+			}
+			fmt.Fprintf(w, "  IdentityFile \"%s\"\n", keyPath)
+			fmt.Fprintf(w, "  IdentitiesOnly yes\n")
+			fmt.Fprintf(w, "  ProxyCommand \"%s\" proxy --push-key %%r %s %%p\n", executable, target)
+		}
+		count++
+	}
+
+	return count
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/andreclaro/ssm/internal/config"
+	"github.com/andreclaro/ssm/internal/storage"
+)
+
+// sshConfigInstances are listed as 'ssm list' orders them
+var sshConfigInstances = []storage.Instance{
+	{InstanceID: "i-0000000000000001", Name: "web", Profile: "prod", Region: "eu-west-1", State: "running", PingStatus: "Online", PlatformName: "Ubuntu"},
+	{InstanceID: "i-0000000000000002", Name: "web", Profile: "prod", Region: "us-east-1", State: "running", PingStatus: "Online", PlatformName: "Amazon Linux"},
+	{InstanceID: "i-0000000000000003", Name: "build box", Profile: "dev%1", Region: "eu-west-1", State: "running", PingStatus: "Online"},
+	{InstanceID: "i-0000000000000004", Name: "db", Profile: "prod", Region: "eu-west-1", State: "stopped"},
+	{InstanceID: "mi-00000000000000005", Profile: "onprem", Region: "eu-west-1", State: "Online"},
+}
+
+// TestWriteSSHConfig tests the generated Host blocks, including instances
+// that share a name
+func TestWriteSSHConfig(t *testing.T) {
+	var buf bytes.Buffer
+	count := writeSSHConfig(&buf, sshConfigInstances, "/usr/local/bin/ssm", "", false)
+
+	assert.Equal(t, 4, count)
+	assert.Equal(t, `# Generated by 'ssm ssh-config'. Changes will be overwritten.
+
+# i-0000000000000001 (prod/eu-west-1)
+Host web
+  ProxyCommand "/usr/local/bin/ssm" proxy 'prod/eu-west-1/i-0000000000000001' %p
+
+# i-0000000000000002 (prod/us-east-1)
+Host i-0000000000000002
+  ProxyCommand "/usr/local/bin/ssm" proxy 'prod/us-east-1/i-0000000000000002' %p
+
+# i-0000000000000003 (dev%1/eu-west-1)
+Host i-0000000000000003
+  ProxyCommand "/usr/local/bin/ssm" proxy 'dev%%1/eu-west-1/i-0000000000000003' %p
+
+# mi-00000000000000005 (onprem/eu-west-1)
+Host mi-00000000000000005
+  ProxyCommand "/usr/local/bin/ssm" proxy 'onprem/eu-west-1/mi-00000000000000005' %p
+`, buf.String())
+}
+
+// TestWriteSSHConfig_PushKey tests entries that push the ssm key, including
+// stopped instances with --all
+func TestWriteSSHConfig_PushKey(t *testing.T) {
+	require.NoError(t, config.InitConfig(""))
+
+	var buf bytes.Buffer
+	instances := []storage.Instance{sshConfigInstances[0], sshConfigInstances[1], sshConfigInstances[3]}
+	count := writeSSHConfig(&buf, instances, "/usr/local/bin/ssm", "/home/me/.ssm/ssh/id_ed25519", true)
+
+	assert.Equal(t, 3, count)
+	assert.Equal(t, `# Generated by 'ssm ssh-config'. Changes will be overwritten.
+
+# i-0000000000000001 (prod/eu-west-1)
+Host web
+  User ubuntu
+  IdentityFile "/home/me/.ssm/ssh/id_ed25519"
+  IdentitiesOnly yes
+  ProxyCommand "/usr/local/bin/ssm" proxy --push-key %r 'prod/eu-west-1/i-0000000000000001' %p
+
+# i-0000000000000002 (prod/us-east-1)
+Host i-0000000000000002
+  User ec2-user
+  IdentityFile "/home/me/.ssm/ssh/id_ed25519"
+  IdentitiesOnly yes
+  ProxyCommand "/usr/local/bin/ssm" proxy --push-key %r 'prod/us-east-1/i-0000000000000002' %p
+
+# i-0000000000000004 (prod/eu-west-1)
+Host db
+  User ec2-user
+  IdentityFile "/home/me/.ssm/ssh/id_ed25519"
+  IdentitiesOnly yes
+  ProxyCommand "/usr/local/bin/ssm" proxy --push-key %r 'prod/eu-west-1/i-0000000000000004' %p
+`, buf.String())
+}
+
+// TestParseProxyArgs tests the host and port arguments of proxy
+func TestParseProxyArgs(t *testing.T) {
+	host, port, err := parseProxyArgs([]string{"web-1", "22"})
+	require.NoError(t, err)
+	assert.Equal(t, "web-1", host)
+	assert.Equal(t, 22, port)
+
+	// With -t only the port is given
+	host, port, err = parseProxyArgs([]string{"2222"})
+	require.NoError(t, err)
+	assert.Equal(t, "", host)
+	assert.Equal(t, 2222, port)
+
+	for _, arg := range []string{"ssh", "0", "65536", "-1"} {
+		_, _, err := parseProxyArgs([]string{"web-1", arg})
+		assert.EqualError(t, err, "invalid port '"+arg+"'")
+	}
+}
@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/andreclaro/ssm/internal/service"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	tunnelForwards  []string
+	tunnelSelector  string
+	tunnelDetach    bool
+	tunnelDaemonLog string
+)
+
+// tunnelCmd represents the tunnel command
+var tunnelCmd = &cobra.Command{
+	Use:   "tunnel",
+	Short: "Manage port forwarding tunnels",
+	Long: `Start, list and stop port forwarding tunnels.
+
+Every port forward, including 'ssm <instance> -L' and 'ssm up', is recorded
+while it runs, so tunnels started in another terminal or in the background can
+be listed and stopped from anywhere.`,
+}
+
+// tunnelStartCmd represents the tunnel start command
+var tunnelStartCmd = &cobra.Command{
+	Use:   "start <instance|-t selector> -L <mapping> [-L <mapping>...]",
+	Short: "Start a port forwarding tunnel",
+	Long: `Forward local ports through an instance. With --detach the tunnel runs in
+the background and its output is written to a log file under ~/.ssm/run.
+
+Examples:
+  ssm tunnel start bastion -L 5432:mydb.internal:5432 --detach
+  ssm tunnel start web-1 -L 8080:80 -L 8443:443
+  ssm tunnel start -t role=bastion -L 6379:cache.internal:6379 -d`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if tunnelSelector != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	Run: runTunnelStart,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return CompleteInstanceNames(toComplete)
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	},
+}
+
+// tunnelLsCmd represents the tunnel ls command
+var tunnelLsCmd = &cobra.Command{
+	Use:     "ls",
+	Aliases: []string{"list"},
+	Short:   "List running tunnels",
+	Long: `List running tunnels with their health. A tunnel is "up" when its process
+listens on all of its local ports and "connecting" while it (re)establishes its
+session. Health is read from the process's listening sockets, so listing never
+opens a connection through a tunnel; it is "unknown" when the sockets cannot
+be inspected.`,
+	Args: cobra.NoArgs,
+	Run:  runTunnelLs,
+}
+
+// tunnelStopCmd represents the tunnel stop command
+var tunnelStopCmd = &cobra.Command{
+	Use:   "stop <id|all>",
+	Short: "Stop a running tunnel",
+	Long: `Stop a running tunnel by its ID from 'ssm tunnel ls', or every tunnel with
+'all'. Tunnels started by 'ssm up' share one process, so stopping one of them
+stops all of them.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runTunnelStop,
+}
+
+func init() {
+	rootCmd.AddCommand(tunnelCmd)
+	tunnelCmd.AddCommand(tunnelStartCmd, tunnelLsCmd, tunnelStopCmd)
+
+	tunnelStartCmd.Flags().StringArrayVarP(&tunnelForwards, "forward", "L", nil, "Port forward LOCAL:REMOTE or LOCAL:HOST:REMOTE (repeatable)")
+	tunnelStartCmd.Flags().StringVarP(&tunnelSelector, "tags", "t", "", "Target the instance matching a tag selector instead of a name")
+	tunnelStartCmd.Flags().BoolVarP(&tunnelDetach, "detach", "d", false, "Run the tunnel in the background")
+	tunnelStartCmd.Flags().StringVar(&tunnelDaemonLog, "daemon-log", "", "Log file of a detached tunnel process")
+	tunnelStartCmd.Flags().MarkHidden("daemon-log")
+	tunnelStartCmd.MarkFlagRequired("forward")
+}
+
+func runTunnelStart(cmd *cobra.Command, args []string) {
+	var instanceName string
+	if len(args) > 0 {
+		instanceName = args[0]
+	}
+
+	var mappings []service.PortMapping
+	for _, m := range tunnelForwards {
+		mapping, err := service.ParsePortMapping(m)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		mappings = append(mappings, mapping)
+	}
+
+	// Create service
+	svc, err := service.NewService()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create service: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Resolve the target up front so ambiguity is settled before detaching
+	instance, err := svc.ResolveTarget(instanceName, tunnelSelector)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if tunnelDetach {
+		logPath := service.TunnelLogPath()
+		// The qualified instance ID pins the child to the instance resolved here
+		childArgs := []string{"tunnel", "start", instance.Profile + "/" + instance.Region + "/" + instance.InstanceID, "--daemon-log", logPath}
+		for _, m := range mappings {
+			childArgs = append(childArgs, "-L", m.String())
+		}
+		if cfgFile != "" {
+			childArgs = append(childArgs, "--config", cfgFile)
+		}
+		if viper.GetBool("verbose") {
+			childArgs = append(childArgs, "--verbose")
+		}
+
+		tunnel, err := svc.DetachTunnel(childArgs, logPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to start tunnel: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Started tunnel %d (pid %d) to %s: %s\n", tunnel.ID, tunnel.PID, tunnel.InstanceName, tunnel.Mappings)
+		fmt.Printf("Logs: %s\n", logPath)
+		return
+	}
+
+	ctx := context.Background()
+	if err := svc.RunTunnelTo(ctx, instance, mappings, tunnelDaemonLog); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start port forwarding: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runTunnelLs(cmd *cobra.Command, args []string) {
+	// Create service
+	svc, err := service.NewService()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create service: %v\n", err)
+		os.Exit(1)
+	}
+
+	tunnels, err := svc.ListTunnels()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to list tunnels: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(tunnels) == 0 {
+		fmt.Println("No tunnels running")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "ID\tINSTANCE\tMAPPINGS\tHEALTH\tPID\tUPTIME")
+	for _, tunnel := range tunnels {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%s\n",
+			tunnel.ID,
+			tunnel.InstanceName,
+			strings.ReplaceAll(tunnel.Mappings, ",", " "),
+			tunnel.Health,
+			tunnel.PID,
+			time.Since(tunnel.StartedAt).Round(time.Second),
+		)
+	}
+}
+
+func runTunnelStop(cmd *cobra.Command, args []string) {
+	// Create service
+	svc, err := service.NewService()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create service: %v\n", err)
+		os.Exit(1)
+	}
+
+	if args[0] == "all" {
+		stopped, err := svc.StopAllTunnels()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to stop tunnels: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Stopped %d tunnel(s)\n", stopped)
+		return
+	}
+
+	id, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid tunnel ID '%s' (expected a number or 'all')\n", args[0])
+		os.Exit(1)
+	}
+	if err := svc.StopTunnel(uint(id)); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to stop tunnel: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Stopped tunnel %d\n", id)
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/andreclaro/ssm/internal/config"
+	"github.com/andreclaro/ssm/internal/service"
+	"github.com/spf13/cobra"
+)
+
+// upCmd represents the up command
+var upCmd = &cobra.Command{
+	Use:   "up [tunnel...]",
+	Short: "Bring up the tunnels declared in .ssm.yaml",
+	Long: `Bring up the tunnels declared in the project's .ssm.yaml, found in the
+current directory or the nearest parent. Each tunnel names an instance, or
+selects one by tags, and forwards a local port to a port on the instance or
+on a host reachable from it. Pass tunnel names to bring up only those.
+
+Tunnels are supervised like 'ssm <instance> -L': dropped sessions are
+reconnected with backoff. Press Ctrl+C or run 'ssm down' to stop them.
+
+Example .ssm.yaml:
+  tunnels:
+    - name: db
+      instance: bastion
+      local_port: 5432
+      remote_host: mydb.cluster-abc.us-east-1.rds.amazonaws.com
+      remote_port: 5432
+    - name: api
+      tags:
+        env: dev
+        role: api
+      local_port: 8080
+      remote_port: 80
+
+Examples:
+  ssm up           # Bring up every tunnel
+  ssm up db        # Bring up only the db tunnel`,
+	Run: runUp,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		manifest, err := loadProjectManifest()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		names := make([]string, 0, len(manifest.Tunnels))
+		for _, tunnel := range manifest.Tunnels {
+			names = append(names, tunnel.Name)
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(upCmd)
+}
+
+func runUp(cmd *cobra.Command, args []string) {
+	manifest, err := loadProjectManifest()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	// Create service
+	svc, err := service.NewService()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create service: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	if err := svc.TunnelsUp(ctx, manifest, args); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to bring up tunnels: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// loadProjectManifest finds and loads the .ssm.yaml for the working directory
+func loadProjectManifest() (*config.Manifest, error) {
+	path, err := config.FindManifest(".")
+	if err != nil {
+		return nil, err
+	}
+	return config.LoadManifest(path)
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/andreclaro/ssm/internal/service"
+	"github.com/spf13/cobra"
+)
+
+var (
+	waitState    string
+	waitTimeout  time.Duration
+	waitSelector string
+)
+
+// waitCmd represents the wait command
+var waitCmd = &cobra.Command{
+	Use:   "wait <instance|-t selector>",
+	Short: "Wait for an instance to reach a state",
+	Long: `Block until an instance reaches a state, then exit 0. Exits 1 if the
+timeout elapses first.
+
+States:
+  online   the SSM agent reports Online (default)
+  running  the EC2 instance is running
+  stopped  the EC2 instance is stopped
+
+Examples:
+  ssm wait devbox                             # Wait for devbox to be reachable via SSM
+  ssm wait devbox --state stopped --timeout 5m  # Wait for devbox to shut down
+  ssm wait -t role=bastion                    # Wait for the bastion by tag`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if waitSelector != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	Run: runWait,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return CompleteInstanceNames(toComplete)
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(waitCmd)
+
+	waitCmd.Flags().StringVar(&waitState, "state", service.WaitStateOnline, "State to wait for: online, running or stopped")
+	waitCmd.Flags().DurationVar(&waitTimeout, "timeout", service.DefaultStartTimeout, "Maximum time to wait")
+	waitCmd.Flags().StringVarP(&waitSelector, "tags", "t", "", "Target the instance matching a tag selector instead of a name")
+	waitCmd.RegisterFlagCompletionFunc("state", cobra.FixedCompletions(
+		[]string{service.WaitStateOnline, service.WaitStateRunning, service.WaitStateStopped}, cobra.ShellCompDirectiveNoFileComp))
+}
+
+func runWait(cmd *cobra.Command, args []string) {
+	var instanceName string
+	if len(args) > 0 {
+		instanceName = args[0]
+	}
+
+	// Create service
+	svc, err := service.NewService()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create service: %v\n", err)
+		os.Exit(1)
+	}
+
+	instance, err := svc.ResolveTarget(instanceName, waitSelector)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	if err := svc.WaitForInstance(ctx, instance, waitState, waitTimeout); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s is %s\n", instance.DisplayName(), waitState)
+}
@@ -13,6 +13,9 @@ import (
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2instanceconnect"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/sirupsen/logrus"
@@ -27,9 +30,12 @@ type Client struct {
 	Config    aws.Config
 
 	// Service clients
-	EC2Client *ec2.Client
-	SSMClient *ssm.Client
-	STSClient *sts.Client
+	EC2Client             *ec2.Client
+	SSMClient             *ssm.Client
+	STSClient             *sts.Client
+	ECSClient             *ecs.Client
+	OrganizationsClient   *organizations.Client
+	InstanceConnectClient *ec2instanceconnect.Client
 }
 
 // ClientManager manages AWS clients for different profiles and regions
@@ -81,14 +87,25 @@ func (cm *ClientManager) GetClient(ctx context.Context, profile, region string)
 	return client, nil
 }
 
-// createClient creates a new AWS client for the specified profile and region
+// createClient creates a new AWS client for the specified profile and region.
+// Account profiles assume the organization role with their source profile.
 func (cm *ClientManager) createClient(ctx context.Context, profile, region string) (*Client, error) {
+	sourceProfile, memberAccountID, isAccount := ParseAccountProfile(profile)
+	if !isAccount {
+		sourceProfile = profile
+	}
+
 	cfg, err := awsconfig.LoadDefaultConfig(ctx,
 		awsconfig.WithRegion(region),
-		awsconfig.WithSharedConfigProfile(profile),
+		awsconfig.WithSharedConfigProfile(sourceProfile),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load AWS config for profile %s: %w", profile, err)
+		return nil, fmt.Errorf("failed to load AWS config for profile %s: %w", sourceProfile, err)
+	}
+	if isAccount {
+		if err := assumeOrganizationRole(&cfg, memberAccountID); err != nil {
+			return nil, err
+		}
 	}
 
 	// Create service clients
@@ -104,13 +121,16 @@ func (cm *ClientManager) createClient(ctx context.Context, profile, region strin
 	}
 
 	client := &Client{
-		Profile:   profile,
-		Region:    region,
-		AccountID: accountID,
-		Config:    cfg,
-		EC2Client: ec2Client,
-		SSMClient: ssmClient,
-		STSClient: stsClient,
+		Profile:               profile,
+		Region:                region,
+		AccountID:             accountID,
+		Config:                cfg,
+		EC2Client:             ec2Client,
+		SSMClient:             ssmClient,
+		STSClient:             stsClient,
+		ECSClient:             ecs.NewFromConfig(cfg),
+		OrganizationsClient:   organizations.NewFromConfig(cfg),
+		InstanceConnectClient: ec2instanceconnect.NewFromConfig(cfg),
 	}
 
 	return client, nil
@@ -130,6 +150,41 @@ func (cm *ClientManager) getAccountID(ctx context.Context, stsClient *sts.Client
 	return *result.Account, nil
 }
 
+// cliCredentials returns the arguments and environment that give an AWS CLI
+// subprocess the client's credentials. Account profiles are unknown to the
+// CLI, so their assumed role credentials are passed in the environment.
+func (c *Client) cliCredentials(ctx context.Context) ([]string, []string, error) {
+	if _, _, ok := ParseAccountProfile(c.Profile); !ok {
+		return []string{"--profile", c.Profile}, os.Environ(), nil
+	}
+
+	creds, err := c.Config.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to retrieve credentials: %w", err)
+	}
+	var env []string
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "AWS_PROFILE=") && !strings.HasPrefix(kv, "AWS_DEFAULT_PROFILE=") {
+			env = append(env, kv)
+		}
+	}
+	env = append(env,
+		"AWS_ACCESS_KEY_ID="+creds.AccessKeyID,
+		"AWS_SECRET_ACCESS_KEY="+creds.SecretAccessKey,
+		"AWS_SESSION_TOKEN="+creds.SessionToken,
+	)
+	return nil, env, nil
+}
+
+// CallerARN returns the ARN of the identity the client's credentials belong to
+func (c *Client) CallerARN(ctx context.Context) (string, error) {
+	result, err := c.STSClient.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get caller identity: %w", err)
+	}
+	return aws.ToString(result.Arn), nil
+}
+
 // GetAvailableProfiles returns a list of available AWS profiles
 func GetAvailableProfiles() ([]string, error) {
 	profileSet := make(map[string]bool)
@@ -0,0 +1,130 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Documents used to run commands, chosen by instance platform
+const (
+	ShellScriptDocument      = "AWS-RunShellScript"
+	PowerShellScriptDocument = "AWS-RunPowerShellScript"
+)
+
+const (
+	// commandPollInterval is how often GetCommandInvocation is polled
+	commandPollInterval = time.Second
+
+	// commandDeliveryGrace is added to the execution timeout to allow for
+	// the agent picking the command up and reporting back
+	commandDeliveryGrace = 2 * time.Minute
+)
+
+// CommandResult is the outcome of a command on a single instance
+type CommandResult struct {
+	CommandID string
+	Status    string
+	ExitCode  int
+	Stdout    string
+	Stderr    string
+}
+
+// CommandDocumentForPlatform returns the Run Command document for a platform
+func CommandDocumentForPlatform(platform string) string {
+	if strings.Contains(strings.ToLower(platform), "windows") {
+		return PowerShellScriptDocument
+	}
+	return ShellScriptDocument
+}
+
+// RunCommand runs a shell command on the instance via SendCommand and waits
+// for it to finish. The timeout bounds the command's execution on the instance.
+func (sm *SSMSessionManager) RunCommand(ctx context.Context, instanceID, document, command string, timeout time.Duration) (*CommandResult, error) {
+	logrus.WithFields(logrus.Fields{
+		"instance_id": instanceID,
+		"profile":     sm.client.Profile,
+		"region":      sm.client.Region,
+		"document":    document,
+	}).Debug("Sending command")
+
+	parameters := map[string][]string{
+		"commands": {command},
+	}
+	if timeout > 0 {
+		parameters["executionTimeout"] = []string{fmt.Sprintf("%d", int(timeout.Seconds()))}
+	}
+
+	result, err := sm.client.SSMClient.SendCommand(ctx, &ssm.SendCommandInput{
+		DocumentName: aws.String(document),
+		InstanceIds:  []string{instanceID},
+		Parameters:   parameters,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to send command: %w", err)
+	}
+	if result.Command == nil || result.Command.CommandId == nil {
+		return nil, fmt.Errorf("SendCommand returned no command ID")
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout+commandDeliveryGrace)
+		defer cancel()
+	}
+	return sm.WaitForCommand(ctx, *result.Command.CommandId, instanceID)
+}
+
+// WaitForCommand polls GetCommandInvocation until the command reaches a final status
+func (sm *SSMSessionManager) WaitForCommand(ctx context.Context, commandID, instanceID string) (*CommandResult, error) {
+	ticker := time.NewTicker(commandPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		invocation, err := sm.client.SSMClient.GetCommandInvocation(ctx, &ssm.GetCommandInvocationInput{
+			CommandId:  aws.String(commandID),
+			InstanceId: aws.String(instanceID),
+		})
+		if err != nil {
+			// The invocation is not visible until the agent picks the command up
+			var notFound *types.InvocationDoesNotExist
+			if errors.As(err, &notFound) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get command invocation: %w", err)
+		}
+
+		switch invocation.Status {
+		case types.CommandInvocationStatusSuccess,
+			types.CommandInvocationStatusFailed,
+			types.CommandInvocationStatusCancelled,
+			types.CommandInvocationStatusTimedOut:
+			return &CommandResult{
+				CommandID: commandID,
+				Status:    string(invocation.Status),
+				ExitCode:  int(invocation.ResponseCode),
+				Stdout:    aws.ToString(invocation.StandardOutputContent),
+				Stderr:    aws.ToString(invocation.StandardErrorContent),
+			}, nil
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"command_id":  commandID,
+			"instance_id": instanceID,
+			"status":      invocation.Status,
+		}).Debug("Waiting for command")
+	}
+}
@@ -0,0 +1,17 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCommandDocumentForPlatform tests picking the Run Command document
+func TestCommandDocumentForPlatform(t *testing.T) {
+	for _, platform := range []string{"", "Linux/UNIX", "Ubuntu", "Amazon Linux", "Red Hat Enterprise Linux"} {
+		assert.Equal(t, ShellScriptDocument, CommandDocumentForPlatform(platform), platform)
+	}
+	for _, platform := range []string{"Windows", "windows", "Microsoft Windows Server 2022 Datacenter"} {
+		assert.Equal(t, PowerShellScriptDocument, CommandDocumentForPlatform(platform), platform)
+	}
+}
@@ -0,0 +1,147 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/sirupsen/logrus"
+)
+
+// EC2 instance states used by lifecycle operations
+const (
+	InstanceStatePending  = "pending"
+	InstanceStateRunning  = "running"
+	InstanceStateStopping = "stopping"
+	InstanceStateStopped  = "stopped"
+)
+
+// waiterMinDelay is the shortest delay between EC2 waiter polls
+const waiterMinDelay = 5 * time.Second
+
+// EC2InstanceManager handles EC2 instance lifecycle operations
+type EC2InstanceManager struct {
+	client *Client
+}
+
+// NewEC2InstanceManager creates a new EC2 instance manager
+func NewEC2InstanceManager(client *Client) *EC2InstanceManager {
+	return &EC2InstanceManager{
+		client: client,
+	}
+}
+
+// IsEC2Instance reports whether instanceID is an EC2 instance rather than an
+// SSM hybrid managed instance (mi-), which has no EC2 lifecycle
+func IsEC2Instance(instanceID string) bool {
+	return strings.HasPrefix(instanceID, "i-")
+}
+
+// DescribeInstance returns the EC2 description of an instance
+func (em *EC2InstanceManager) DescribeInstance(ctx context.Context, instanceID string) (*ec2types.Instance, error) {
+	result, err := em.client.EC2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: []string{instanceID},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe instance: %w", err)
+	}
+
+	for _, reservation := range result.Reservations {
+		for _, instance := range reservation.Instances {
+			return &instance, nil
+		}
+	}
+	return nil, fmt.Errorf("instance %s not found", instanceID)
+}
+
+// InstanceState returns the current EC2 state of an instance
+func (em *EC2InstanceManager) InstanceState(ctx context.Context, instanceID string) (string, error) {
+	instance, err := em.DescribeInstance(ctx, instanceID)
+	if err != nil {
+		return "", err
+	}
+	if instance.State == nil {
+		return "", fmt.Errorf("instance %s has no state", instanceID)
+	}
+	return string(instance.State.Name), nil
+}
+
+// StartInstance starts a stopped instance
+func (em *EC2InstanceManager) StartInstance(ctx context.Context, instanceID string) error {
+	logrus.WithField("instance_id", instanceID).Info("Starting EC2 instance")
+
+	if _, err := em.client.EC2Client.StartInstances(ctx, &ec2.StartInstancesInput{
+		InstanceIds: []string{instanceID},
+	}); err != nil {
+		return fmt.Errorf("failed to start instance: %w", err)
+	}
+	return nil
+}
+
+// StopInstance stops a running instance
+func (em *EC2InstanceManager) StopInstance(ctx context.Context, instanceID string) error {
+	logrus.WithField("instance_id", instanceID).Info("Stopping EC2 instance")
+
+	if _, err := em.client.EC2Client.StopInstances(ctx, &ec2.StopInstancesInput{
+		InstanceIds: []string{instanceID},
+	}); err != nil {
+		return fmt.Errorf("failed to stop instance: %w", err)
+	}
+	return nil
+}
+
+// RebootInstance requests a reboot of a running instance
+func (em *EC2InstanceManager) RebootInstance(ctx context.Context, instanceID string) error {
+	logrus.WithField("instance_id", instanceID).Info("Rebooting EC2 instance")
+
+	if _, err := em.client.EC2Client.RebootInstances(ctx, &ec2.RebootInstancesInput{
+		InstanceIds: []string{instanceID},
+	}); err != nil {
+		return fmt.Errorf("failed to reboot instance: %w", err)
+	}
+	return nil
+}
+
+// SetTerminationProtection enables or disables API termination protection
+func (em *EC2InstanceManager) SetTerminationProtection(ctx context.Context, instanceID string, enabled bool) error {
+	logrus.WithFields(logrus.Fields{
+		"instance_id": instanceID,
+		"enabled":     enabled,
+	}).Info("Setting EC2 termination protection")
+
+	if _, err := em.client.EC2Client.ModifyInstanceAttribute(ctx, &ec2.ModifyInstanceAttributeInput{
+		InstanceId:            aws.String(instanceID),
+		DisableApiTermination: &ec2types.AttributeBooleanValue{Value: aws.Bool(enabled)},
+	}); err != nil {
+		return fmt.Errorf("failed to set termination protection: %w", err)
+	}
+	return nil
+}
+
+// WaitForState waits up to timeout for the instance to reach the running or
+// stopped state
+func (em *EC2InstanceManager) WaitForState(ctx context.Context, instanceID, state string, timeout time.Duration) error {
+	input := &ec2.DescribeInstancesInput{InstanceIds: []string{instanceID}}
+
+	var err error
+	switch state {
+	case InstanceStateRunning:
+		err = ec2.NewInstanceRunningWaiter(em.client.EC2Client, func(o *ec2.InstanceRunningWaiterOptions) {
+			o.MinDelay = waiterMinDelay
+		}).Wait(ctx, input, timeout)
+	case InstanceStateStopped:
+		err = ec2.NewInstanceStoppedWaiter(em.client.EC2Client, func(o *ec2.InstanceStoppedWaiterOptions) {
+			o.MinDelay = waiterMinDelay
+		}).Wait(ctx, input, timeout)
+	default:
+		return fmt.Errorf("cannot wait for EC2 state '%s'", state)
+	}
+	if err != nil {
+		return fmt.Errorf("instance did not reach state %s: %w", state, err)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/sirupsen/logrus"
+
+	"github.com/andreclaro/ssm/internal/session"
+)
+
+// describeTasksBatch is the maximum number of tasks DescribeTasks accepts
+const describeTasksBatch = 100
+
+// ECSTask is a running ECS task with ECS Exec enabled
+type ECSTask struct {
+	TaskArn    string
+	Cluster    string
+	Service    string
+	LaunchType string
+	LastStatus string
+	// Containers lists the containers whose exec agent is running
+	Containers []string
+}
+
+// TaskID returns the task ID, the last segment of the task ARN
+func (t ECSTask) TaskID() string {
+	return t.TaskArn[strings.LastIndex(t.TaskArn, "/")+1:]
+}
+
+// ECSManager handles ECS discovery and ECS Exec sessions
+type ECSManager struct {
+	client *Client
+}
+
+// NewECSManager creates a new ECS manager
+func NewECSManager(client *Client) *ECSManager {
+	return &ECSManager{
+		client: client,
+	}
+}
+
+// ListExecTasks returns the running tasks with ECS Exec enabled in every cluster
+func (em *ECSManager) ListExecTasks(ctx context.Context) ([]ECSTask, error) {
+	clusters, err := em.listClusters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []ECSTask
+	for _, cluster := range clusters {
+		found, err := em.listClusterExecTasks(ctx, cluster)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, found...)
+	}
+	return tasks, nil
+}
+
+// listClusters returns the ARNs of every cluster
+func (em *ECSManager) listClusters(ctx context.Context) ([]string, error) {
+	var clusters []string
+	paginator := ecs.NewListClustersPaginator(em.client.ECSClient, &ecs.ListClustersInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list ECS clusters: %w", err)
+		}
+		clusters = append(clusters, page.ClusterArns...)
+	}
+	return clusters, nil
+}
+
+// listClusterExecTasks returns the running tasks of a cluster that have ECS
+// Exec enabled
+func (em *ECSManager) listClusterExecTasks(ctx context.Context, cluster string) ([]ECSTask, error) {
+	var taskArns []string
+	paginator := ecs.NewListTasksPaginator(em.client.ECSClient, &ecs.ListTasksInput{
+		Cluster:       aws.String(cluster),
+		DesiredStatus: ecstypes.DesiredStatusRunning,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list ECS tasks: %w", err)
+		}
+		taskArns = append(taskArns, page.TaskArns...)
+	}
+
+	var tasks []ECSTask
+	for start := 0; start < len(taskArns); start += describeTasksBatch {
+		end := min(start+describeTasksBatch, len(taskArns))
+
+		output, err := em.client.ECSClient.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+			Cluster: aws.String(cluster),
+			Tasks:   taskArns[start:end],
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe ECS tasks: %w", err)
+		}
+
+		for _, t := range output.Tasks {
+			if !t.EnableExecuteCommand {
+				continue
+			}
+			task := ECSTask{
+				TaskArn:    aws.ToString(t.TaskArn),
+				Cluster:    cluster,
+				LaunchType: string(t.LaunchType),
+				LastStatus: aws.ToString(t.LastStatus),
+			}
+			// Tasks started by a service are grouped as "service:<name>"
+			if name, ok := strings.CutPrefix(aws.ToString(t.Group), "service:"); ok {
+				task.Service = name
+			}
+			for _, c := range t.Containers {
+				for _, agent := range c.ManagedAgents {
+					if agent.Name == ecstypes.ManagedAgentNameExecuteCommandAgent && aws.ToString(agent.LastStatus) == "RUNNING" {
+						task.Containers = append(task.Containers, aws.ToString(c.Name))
+					}
+				}
+			}
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, nil
+}
+
+// ExecuteCommand runs command interactively in a task's container over ECS
+// Exec, attached to the terminal
+func (em *ECSManager) ExecuteCommand(ctx context.Context, cluster, taskArn, container, command string) error {
+	logrus.WithFields(logrus.Fields{
+		"cluster":   cluster,
+		"task":      taskArn,
+		"container": container,
+		"profile":   em.client.Profile,
+		"region":    em.client.Region,
+	}).Info("Starting ECS Exec session")
+
+	if useCLI() {
+		return em.executeCommandWithCLI(ctx, cluster, taskArn, container, command)
+	}
+
+	output, err := em.client.ECSClient.ExecuteCommand(ctx, &ecs.ExecuteCommandInput{
+		Cluster:     aws.String(cluster),
+		Task:        aws.String(taskArn),
+		Container:   aws.String(container),
+		Command:     aws.String(command),
+		Interactive: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to execute command: %w", err)
+	}
+	if output.Session == nil {
+		return fmt.Errorf("failed to execute command: no session returned")
+	}
+
+	sessionID := aws.ToString(output.Session.SessionId)
+	sm := NewSSMSessionManager(em.client)
+	ch, err := session.Dial(ctx, aws.ToString(output.Session.StreamUrl), aws.ToString(output.Session.TokenValue), session.Options{})
+	if err != nil {
+		sm.terminateSession(sessionID)
+		return err
+	}
+	defer sm.closeDataChannel(ch, sessionID)
+
+	fmt.Printf("\nStarting session with SessionId: %s\n", sessionID)
+	if err := session.RunShell(ctx, ch, os.Stdin, os.Stdout); err != nil {
+		return fmt.Errorf("ECS Exec session failed: %w", err)
+	}
+	fmt.Printf("\n\nExiting session with sessionId: %s.\n\n", sessionID)
+	return nil
+}
+
+// executeCommandWithCLI runs the ECS Exec session through the AWS CLI
+func (em *ECSManager) executeCommandWithCLI(ctx context.Context, cluster, taskArn, container, command string) error {
+	credentialArgs, env, err := em.client.cliCredentials(ctx)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"ecs", "execute-command",
+		"--cluster", cluster,
+		"--task", taskArn,
+		"--container", container,
+		"--command", command,
+		"--interactive",
+		"--region", em.client.Region,
+	}
+	cmd := exec.Command("aws", append(args, credentialArgs...)...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to start ECS Exec session: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2instanceconnect"
+	"github.com/sirupsen/logrus"
+)
+
+// SendSSHPublicKey pushes a public key to the instance for osUser. The
+// instance accepts it for SSH authentication for 60 seconds.
+func (c *Client) SendSSHPublicKey(ctx context.Context, instanceID, osUser, publicKey string) error {
+	logrus.WithFields(logrus.Fields{
+		"instance_id": instanceID,
+		"os_user":     osUser,
+	}).Debug("Sending SSH public key with EC2 Instance Connect")
+
+	output, err := c.InstanceConnectClient.SendSSHPublicKey(ctx, &ec2instanceconnect.SendSSHPublicKeyInput{
+		InstanceId:     aws.String(instanceID),
+		InstanceOSUser: aws.String(osUser),
+		SSHPublicKey:   aws.String(publicKey),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send SSH public key: %w", err)
+	}
+	if !output.Success {
+		return fmt.Errorf("EC2 Instance Connect did not accept the SSH public key")
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"github.com/andreclaro/ssm/internal/config"
+)
+
+// roleSessionName names the sessions of roles assumed in member accounts
+const roleSessionName = "ssm-cli"
+
+// Account is an active member account of an organization
+type Account struct {
+	ID   string
+	Name string
+}
+
+// ListOrganizationAccounts returns the active accounts of the organization.
+// The client must belong to the management account or a delegated
+// administrator.
+func (c *Client) ListOrganizationAccounts(ctx context.Context) ([]Account, error) {
+	var accounts []Account
+	paginator := organizations.NewListAccountsPaginator(c.OrganizationsClient, &organizations.ListAccountsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list organization accounts: %w", err)
+		}
+
+		for _, a := range page.Accounts {
+			// State supersedes the deprecated Status
+			active := a.State == orgtypes.AccountStateActive
+			if a.State == "" {
+				active = a.Status == orgtypes.AccountStatusActive
+			}
+			if active {
+				accounts = append(accounts, Account{ID: aws.ToString(a.Id), Name: aws.ToString(a.Name)})
+			}
+		}
+	}
+	return accounts, nil
+}
+
+// AccountProfile names the member account reached by assuming the
+// organization role from sourceProfile. Account profiles are accepted
+// wherever a profile name is, but exist only in this tool.
+func AccountProfile(sourceProfile, accountID string) string {
+	return sourceProfile + ":" + accountID
+}
+
+// ParseAccountProfile splits an account profile into its source profile and
+// account ID. It reports false for a profile from the AWS config files.
+func ParseAccountProfile(profile string) (string, string, bool) {
+	i := strings.LastIndex(profile, ":")
+	if i <= 0 || !isAccountID(profile[i+1:]) {
+		return "", "", false
+	}
+	return profile[:i], profile[i+1:], true
+}
+
+// assumeOrganizationRole switches cfg to the credentials of the organization
+// role in accountID, assumed with cfg's credentials
+func assumeOrganizationRole(cfg *aws.Config, accountID string) error {
+	orgCfg := config.GetConfig()
+	if orgCfg == nil || orgCfg.Organization.Role == "" {
+		return fmt.Errorf("no organization role configured to reach account %s", accountID)
+	}
+
+	roleARN := fmt.Sprintf("arn:%s:iam::%s:role/%s", partition(cfg.Region), accountID, orgCfg.Organization.Role)
+	provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(*cfg), roleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = roleSessionName
+		if orgCfg.Organization.ExternalID != "" {
+			o.ExternalID = aws.String(orgCfg.Organization.ExternalID)
+		}
+	})
+	cfg.Credentials = aws.NewCredentialsCache(provider)
+	return nil
+}
+
+// partition returns the AWS partition of a region
+func partition(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		return "aws-us-gov"
+	default:
+		return "aws"
+	}
+}
+
+// isAccountID reports whether s looks like a 12 digit AWS account ID
+func isAccountID(s string) bool {
+	if len(s) != 12 {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
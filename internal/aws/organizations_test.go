@@ -0,0 +1,25 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseAccountProfile tests telling account profiles from config profiles
+func TestParseAccountProfile(t *testing.T) {
+	source, account, ok := ParseAccountProfile(AccountProfile("mgmt", "123456789012"))
+	assert.True(t, ok)
+	assert.Equal(t, "mgmt", source)
+	assert.Equal(t, "123456789012", account)
+
+	source, account, ok = ParseAccountProfile("sso:admin:210987654321")
+	assert.True(t, ok)
+	assert.Equal(t, "sso:admin", source)
+	assert.Equal(t, "210987654321", account)
+
+	for _, profile := range []string{"default", "prod:eu", ":123456789012", "mgmt:12345"} {
+		_, _, ok := ParseAccountProfile(profile)
+		assert.False(t, ok, profile)
+	}
+}
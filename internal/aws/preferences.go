@@ -0,0 +1,53 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// SessionPreferencesDocument holds the account's Session Manager preferences
+// for the region. It only exists once preferences were saved in the console
+// or created explicitly.
+const SessionPreferencesDocument = "SSM-SessionManagerRunShell"
+
+// SessionPreferences are the effective Session Manager preferences
+type SessionPreferences struct {
+	// Exists is false when the account uses the built-in defaults
+	Exists                 bool
+	S3BucketName           string `json:"s3BucketName"`
+	CloudWatchLogGroupName string `json:"cloudWatchLogGroupName"`
+	KMSKeyID               string `json:"kmsKeyId"`
+	RunAsEnabled           bool   `json:"runAsEnabled"`
+	RunAsDefaultUser       string `json:"runAsDefaultUser"`
+	IdleSessionTimeout     string `json:"idleSessionTimeout"`
+	MaxSessionDuration     string `json:"maxSessionDuration"`
+}
+
+// GetSessionPreferences reads the Session Manager preferences document
+func (sm *SSMSessionManager) GetSessionPreferences(ctx context.Context) (*SessionPreferences, error) {
+	result, err := sm.client.SSMClient.GetDocument(ctx, &ssm.GetDocumentInput{
+		Name: aws.String(SessionPreferencesDocument),
+	})
+	if err != nil {
+		var notFound *types.InvalidDocument
+		if errors.As(err, &notFound) {
+			return &SessionPreferences{}, nil
+		}
+		return nil, fmt.Errorf("failed to get session preferences: %w", err)
+	}
+
+	var content struct {
+		Inputs SessionPreferences `json:"inputs"`
+	}
+	if err := json.Unmarshal([]byte(aws.ToString(result.Content)), &content); err != nil {
+		return nil, fmt.Errorf("failed to parse session preferences: %w", err)
+	}
+	content.Inputs.Exists = true
+	return &content.Inputs, nil
+}
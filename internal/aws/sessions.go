@@ -0,0 +1,74 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// SessionInfo describes a Session Manager session
+type SessionInfo struct {
+	SessionID string
+	Target    string
+	Owner     string
+	Document  string
+	Status    string
+	Reason    string
+	StartDate time.Time
+	EndDate   time.Time
+	Profile   string
+	Region    string
+}
+
+// ListSessions lists the sessions of the client's account and region. Active
+// sessions are listed when since is zero; otherwise sessions that were
+// started after since and have ended.
+func (sm *SSMSessionManager) ListSessions(ctx context.Context, since time.Time) ([]SessionInfo, error) {
+	input := &ssm.DescribeSessionsInput{State: types.SessionStateActive}
+	if !since.IsZero() {
+		input.State = types.SessionStateHistory
+		input.Filters = []types.SessionFilter{{
+			Key:   types.SessionFilterKeyInvokedAfter,
+			Value: aws.String(since.UTC().Format(time.RFC3339)),
+		}}
+	}
+
+	var sessions []SessionInfo
+	paginator := ssm.NewDescribeSessionsPaginator(sm.client.SSMClient, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe sessions: %w", err)
+		}
+
+		for _, s := range page.Sessions {
+			sessions = append(sessions, SessionInfo{
+				SessionID: aws.ToString(s.SessionId),
+				Target:    aws.ToString(s.Target),
+				Owner:     aws.ToString(s.Owner),
+				Document:  aws.ToString(s.DocumentName),
+				Status:    string(s.Status),
+				Reason:    aws.ToString(s.Reason),
+				StartDate: aws.ToTime(s.StartDate),
+				EndDate:   aws.ToTime(s.EndDate),
+				Profile:   sm.client.Profile,
+				Region:    sm.client.Region,
+			})
+		}
+	}
+	return sessions, nil
+}
+
+// TerminateSession terminates a session and closes its connection to the instance
+func (sm *SSMSessionManager) TerminateSession(ctx context.Context, sessionID string) error {
+	if _, err := sm.client.SSMClient.TerminateSession(ctx, &ssm.TerminateSessionInput{
+		SessionId: aws.String(sessionID),
+	}); err != nil {
+		return fmt.Errorf("failed to terminate session: %w", err)
+	}
+	return nil
+}
@@ -1,16 +1,27 @@
 package aws
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"os/exec"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
 	"github.com/sirupsen/logrus"
+
+	"github.com/andreclaro/ssm/internal/config"
+	"github.com/andreclaro/ssm/internal/session"
 )
 
 // SSMSessionManager handles SSM Session Manager operations
@@ -25,12 +36,29 @@ func NewSSMSessionManager(client *Client) *SSMSessionManager {
 	}
 }
 
+// SessionOptions selects the Session Manager document used for a session
+type SessionOptions struct {
+	// Document is the session document name; empty uses the default shell
+	Document   string
+	Parameters map[string][]string
+}
+
+// InteractiveCommand returns options that run command in an interactive
+// session through AWS-StartInteractiveCommand
+func InteractiveCommand(command string) SessionOptions {
+	return SessionOptions{
+		Document:   InteractiveCommandDocument,
+		Parameters: map[string][]string{"command": {command}},
+	}
+}
+
 // StartSession starts an SSM session with the specified instance
-func (sm *SSMSessionManager) StartSession(ctx context.Context, instanceID string) error {
+func (sm *SSMSessionManager) StartSession(ctx context.Context, instanceID string, opts SessionOptions) error {
 	logrus.WithFields(logrus.Fields{
 		"instance_id": instanceID,
 		"profile":     sm.client.Profile,
 		"region":      sm.client.Region,
+		"document":    opts.Document,
 	}).Info("Starting SSM session")
 
 	// Check if instance is reachable via SSM
@@ -38,8 +66,82 @@ func (sm *SSMSessionManager) StartSession(ctx context.Context, instanceID string
 		return fmt.Errorf("instance not reachable via SSM: %w", err)
 	}
 
-	// Start SSM session using AWS CLI
-	return sm.startSessionWithCLI(instanceID)
+	if useCLI() {
+		return sm.startSessionWithCLI(ctx, instanceID, opts)
+	}
+	return sm.startSessionNative(ctx, instanceID, opts)
+}
+
+// useCLI reports whether sessions should be delegated to the AWS CLI and
+// session-manager-plugin instead of the native client
+func useCLI() bool {
+	cfg := config.GetConfig()
+	return cfg != nil && cfg.Session.Client == "cli"
+}
+
+// openDataChannel calls StartSession and connects to the returned stream.
+// The caller must release the session with closeDataChannel.
+func (sm *SSMSessionManager) openDataChannel(ctx context.Context, input *ssm.StartSessionInput, opts session.Options) (*session.Channel, string, error) {
+	result, err := sm.client.SSMClient.StartSession(ctx, input)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to start session: %w", err)
+	}
+	if result.SessionId == nil || result.StreamUrl == nil || result.TokenValue == nil {
+		return nil, "", fmt.Errorf("incomplete StartSession response")
+	}
+
+	sessionID := *result.SessionId
+	logrus.WithField("session_id", sessionID).Debug("Started SSM session")
+
+	ch, err := session.Dial(ctx, *result.StreamUrl, *result.TokenValue, opts)
+	if err != nil {
+		sm.terminateSession(sessionID)
+		return nil, "", err
+	}
+
+	return ch, sessionID, nil
+}
+
+// closeDataChannel closes the data channel and terminates the session
+func (sm *SSMSessionManager) closeDataChannel(ch *session.Channel, sessionID string) {
+	ch.Close()
+	sm.terminateSession(sessionID)
+}
+
+// terminateSession terminates a session, logging rather than returning failures
+func (sm *SSMSessionManager) terminateSession(sessionID string) {
+	// Use a fresh context so cleanup still runs after the caller's context is cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := sm.TerminateSession(ctx, sessionID); err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Debug("Failed to terminate SSM session")
+	}
+}
+
+// startSessionNative starts an interactive shell session in-process
+func (sm *SSMSessionManager) startSessionNative(ctx context.Context, instanceID string, opts SessionOptions) error {
+	input := &ssm.StartSessionInput{
+		Target:     aws.String(instanceID),
+		Parameters: opts.Parameters,
+	}
+	if opts.Document != "" {
+		input.DocumentName = aws.String(opts.Document)
+	}
+
+	ch, sessionID, err := sm.openDataChannel(ctx, input, session.Options{})
+	if err != nil {
+		return err
+	}
+	defer sm.closeDataChannel(ch, sessionID)
+
+	fmt.Printf("\nStarting session with SessionId: %s\n", sessionID)
+	if err := session.RunShell(ctx, ch, os.Stdin, os.Stdout); err != nil {
+		return fmt.Errorf("SSM session failed: %w", err)
+	}
+	fmt.Printf("\n\nExiting session with sessionId: %s.\n\n", sessionID)
+
+	return nil
 }
 
 // checkInstanceReachability checks if the instance is reachable via SSM
@@ -72,14 +174,29 @@ func (sm *SSMSessionManager) checkInstanceReachability(ctx context.Context, inst
 }
 
 // startSessionWithCLI starts an SSM session using the AWS CLI
-func (sm *SSMSessionManager) startSessionWithCLI(instanceID string) error {
+func (sm *SSMSessionManager) startSessionWithCLI(ctx context.Context, instanceID string, opts SessionOptions) error {
+	credentialArgs, env, err := sm.client.cliCredentials(ctx)
+	if err != nil {
+		return err
+	}
+
 	// Prepare AWS CLI command
 	args := []string{
 		"ssm", "start-session",
 		"--target", instanceID,
-		"--profile", sm.client.Profile,
 		"--region", sm.client.Region,
 	}
+	args = append(args, credentialArgs...)
+	if opts.Document != "" {
+		args = append(args, "--document-name", opts.Document)
+	}
+	if len(opts.Parameters) > 0 {
+		params, err := json.Marshal(opts.Parameters)
+		if err != nil {
+			return fmt.Errorf("failed to encode session parameters: %w", err)
+		}
+		args = append(args, "--parameters", string(params))
+	}
 
 	// Prefer replacing the current process so signals like Ctrl+C are handled by AWS CLI directly
 	if awsPath, lookErr := exec.LookPath("aws"); lookErr == nil {
@@ -87,7 +204,7 @@ func (sm *SSMSessionManager) startSessionWithCLI(instanceID string) error {
 			"command": "aws " + fmt.Sprintf("%v", args),
 		}).Debug("Exec'ing AWS CLI (replacing current process)")
 		// syscall.Exec only returns on error
-		if err := syscall.Exec(awsPath, append([]string{"aws"}, args...), os.Environ()); err == nil {
+		if err := syscall.Exec(awsPath, append([]string{"aws"}, args...), env); err == nil {
 			return nil // unreachable if Exec succeeds
 		} else {
 			logrus.WithError(err).Warn("Failed to exec aws; falling back to spawning subprocess")
@@ -98,6 +215,7 @@ func (sm *SSMSessionManager) startSessionWithCLI(instanceID string) error {
 
 	// Fallback: spawn a subprocess attached to our stdio
 	cmd := exec.Command("aws", args...)
+	cmd.Env = env
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
@@ -113,14 +231,20 @@ func (sm *SSMSessionManager) startSessionWithCLI(instanceID string) error {
 	return nil
 }
 
-// StartPortForwarding starts an SSM port forwarding session using the AWS CLI.
-// It forwards localPort on the user's machine to remotePort on the target instance.
-func (sm *SSMSessionManager) StartPortForwarding(ctx context.Context, instanceID string, localPort, remotePort int) error {
+// ErrLocalPortUnavailable is returned when the local end of a forward cannot be bound
+var ErrLocalPortUnavailable = errors.New("local port unavailable")
+
+// StartPortForwarding starts an SSM port forwarding session.
+// It forwards localPort on the user's machine to remotePort on the target
+// instance, or on remoteHost as seen from the instance when remoteHost is set.
+// Status output goes to out. It returns when the session ends or ctx is done.
+func (sm *SSMSessionManager) StartPortForwarding(ctx context.Context, instanceID, remoteHost string, localPort, remotePort int, out io.Writer) error {
 	logrus.WithFields(logrus.Fields{
 		"instance_id": instanceID,
 		"profile":     sm.client.Profile,
 		"region":      sm.client.Region,
 		"local_port":  localPort,
+		"remote_host": remoteHost,
 		"remote_port": remotePort,
 	}).Info("Starting SSM port forwarding session")
 
@@ -129,33 +253,241 @@ func (sm *SSMSessionManager) StartPortForwarding(ctx context.Context, instanceID
 		return fmt.Errorf("instance not reachable via SSM: %w", err)
 	}
 
-	// Use aws ssm start-session with AWS-StartPortForwardingSession document
+	doc, params := portForwardingDocument(remoteHost, localPort, remotePort)
+
+	if !useCLI() {
+		return sm.startPortForwardingNative(ctx, instanceID, doc, params, localPort, out)
+	}
+
+	// Use aws ssm start-session with the port forwarding document
 	// Example: aws ssm start-session --target i-123 --document-name AWS-StartPortForwardingSession \
 	//          --parameters 'localPortNumber=[8888],portNumber=[80]'
-	doc := "AWS-StartPortForwardingSession"
-	params := fmt.Sprintf("localPortNumber=[%d],portNumber=[%d]", localPort, remotePort)
+	var cliParams []string
+	for _, key := range []string{"host", "portNumber", "localPortNumber"} {
+		if values, ok := params[key]; ok {
+			cliParams = append(cliParams, fmt.Sprintf("%s=[%s]", key, values[0]))
+		}
+	}
 
+	credentialArgs, env, err := sm.client.cliCredentials(ctx)
+	if err != nil {
+		return err
+	}
 	args := []string{
 		"ssm", "start-session",
 		"--target", instanceID,
-		"--profile", sm.client.Profile,
 		"--region", sm.client.Region,
 		"--document-name", doc,
-		"--parameters", params,
+		"--parameters", strings.Join(cliParams, ","),
 	}
+	args = append(args, credentialArgs...)
+
+	// The plugin reports a local port conflict only in its output
+	output := &portConflictWriter{w: out}
 
 	cmd := exec.CommandContext(ctx, "aws", args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
+	cmd.Env = env
+	cmd.Stdout = output
+	cmd.Stderr = output
+	// Ask the CLI to end the session cleanly before killing it
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = 5 * time.Second
 
 	logrus.WithField("command", "aws "+fmt.Sprintf("%v", args)).Debug("Executing AWS CLI command for port forwarding")
-	if err := cmd.Run(); err != nil {
+	err = cmd.Run()
+	if output.conflict {
+		return fmt.Errorf("%w: %d: address already in use", ErrLocalPortUnavailable, localPort)
+	}
+	if err != nil && ctx.Err() == nil {
+		return fmt.Errorf("failed to start SSM port forwarding session: %w", err)
+	}
+	return nil
+}
+
+// portConflictWriter passes output through to w and notes whether the
+// session-manager-plugin failed to bind the local port
+type portConflictWriter struct {
+	w        io.Writer
+	conflict bool
+}
+
+// Write implements io.Writer
+func (p *portConflictWriter) Write(b []byte) (int, error) {
+	if bytes.Contains(b, []byte("address already in use")) {
+		p.conflict = true
+	}
+	return p.w.Write(b)
+}
+
+// portForwardingDocument returns the session document and parameters for a
+// forward to the instance itself or, when remoteHost is set, to a remote host
+func portForwardingDocument(remoteHost string, localPort, remotePort int) (string, map[string][]string) {
+	params := map[string][]string{
+		"portNumber":      {fmt.Sprintf("%d", remotePort)},
+		"localPortNumber": {fmt.Sprintf("%d", localPort)},
+	}
+	if remoteHost == "" {
+		return "AWS-StartPortForwardingSession", params
+	}
+	params["host"] = []string{remoteHost}
+	return "AWS-StartPortForwardingSessionToRemoteHost", params
+}
+
+// startPortForwardingNative runs a port forwarding document in-process until
+// the session ends or ctx is cancelled
+func (sm *SSMSessionManager) startPortForwardingNative(ctx context.Context, instanceID, doc string, params map[string][]string, localPort int, out io.Writer) error {
+	// Bind the local port first so conflicts are reported before a session is created
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", localPort))
+	if err != nil {
+		return fmt.Errorf("%w: %d: %v", ErrLocalPortUnavailable, localPort, err)
+	}
+	defer listener.Close()
+
+	ch, sessionID, err := sm.openDataChannel(ctx, &ssm.StartSessionInput{
+		Target:       aws.String(instanceID),
+		DocumentName: aws.String(doc),
+		Parameters:   params,
+	}, session.Options{})
+	if err != nil {
+		return err
+	}
+	defer sm.closeDataChannel(ch, sessionID)
+
+	fmt.Fprintf(out, "Starting session with SessionId: %s\n", sessionID)
+	fmt.Fprintf(out, "Port %d opened for sessionId %s.\n", localPort, sessionID)
+	fmt.Fprintln(out, "Waiting for connections...")
+
+	if err := session.ForwardPort(ctx, ch, listener); err != nil && ctx.Err() == nil {
 		return fmt.Errorf("failed to start SSM port forwarding session: %w", err)
 	}
 	return nil
 }
 
+// StartSSHSession opens an AWS-StartSSHSession stream to port on the instance
+// and relays it over stdin/stdout, for use as an SSH ProxyCommand
+func (sm *SSMSessionManager) StartSSHSession(ctx context.Context, instanceID string, port int, stdin io.Reader, stdout io.Writer) error {
+	logrus.WithFields(logrus.Fields{
+		"instance_id": instanceID,
+		"profile":     sm.client.Profile,
+		"region":      sm.client.Region,
+		"port":        port,
+	}).Debug("Starting SSM SSH session")
+
+	if err := sm.checkInstanceReachability(ctx, instanceID); err != nil {
+		return fmt.Errorf("instance not reachable via SSM: %w", err)
+	}
+
+	ch, sessionID, err := sm.openDataChannel(ctx, &ssm.StartSessionInput{
+		Target:       aws.String(instanceID),
+		DocumentName: aws.String("AWS-StartSSHSession"),
+		Parameters: map[string][]string{
+			"portNumber": {fmt.Sprintf("%d", port)},
+		},
+	}, session.Options{})
+	if err != nil {
+		return err
+	}
+	defer sm.closeDataChannel(ch, sessionID)
+
+	stream, err := session.OpenStream(ctx, ch)
+	if err != nil {
+		return fmt.Errorf("session handshake failed: %w", err)
+	}
+	defer stream.Close()
+
+	if err := session.Pipe(ctx, stream, stdin, stdout); err != nil && err != io.EOF {
+		return fmt.Errorf("SSH session failed: %w", err)
+	}
+	return nil
+}
+
+// remoteStream is a port forwarding session used as a single connection
+type remoteStream struct {
+	io.ReadWriteCloser
+	ch        *session.Channel
+	sm        *SSMSessionManager
+	sessionID string
+	closeOnce sync.Once
+}
+
+// Close closes the stream and the data channel and terminates the session
+func (s *remoteStream) Close() error {
+	s.closeOnce.Do(func() {
+		s.ReadWriteCloser.Close()
+		s.sm.closeDataChannel(s.ch, s.sessionID)
+	})
+	return nil
+}
+
+// DialRemoteHost opens a session forwarding to host:port as seen from the
+// instance and returns it as a stream. Every stream is a session of its own,
+// which the caller must Close. Requires the native session client.
+func (sm *SSMSessionManager) DialRemoteHost(ctx context.Context, instanceID, host string, port int) (io.ReadWriteCloser, error) {
+	if useCLI() {
+		return nil, fmt.Errorf("streams require the native session client (session.client: native)")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id": instanceID,
+		"remote_host": host,
+		"remote_port": port,
+	}).Debug("Opening SSM stream to remote host")
+
+	ch, sessionID, err := sm.openDataChannel(ctx, &ssm.StartSessionInput{
+		Target:       aws.String(instanceID),
+		DocumentName: aws.String("AWS-StartPortForwardingSessionToRemoteHost"),
+		Parameters: map[string][]string{
+			"host":       {host},
+			"portNumber": {fmt.Sprintf("%d", port)},
+		},
+	}, session.Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := session.OpenStream(ctx, ch)
+	if err != nil {
+		sm.closeDataChannel(ch, sessionID)
+		return nil, fmt.Errorf("session handshake failed: %w", err)
+	}
+	return &remoteStream{ReadWriteCloser: stream, ch: ch, sm: sm, sessionID: sessionID}, nil
+}
+
+// CheckReachability reports whether the instance is reachable via SSM
+func (sm *SSMSessionManager) CheckReachability(ctx context.Context, instanceID string) error {
+	return sm.checkInstanceReachability(ctx, instanceID)
+}
+
+// onlinePollInterval is the delay between SSM ping status checks
+const onlinePollInterval = 5 * time.Second
+
+// WaitForOnline polls the instance's SSM ping status until the agent reports
+// Online or timeout elapses
+func (sm *SSMSessionManager) WaitForOnline(ctx context.Context, instanceID string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		info, err := sm.GetInstanceInformation(ctx, instanceID)
+		if err == nil && info.PingStatus == types.PingStatusOnline {
+			return nil
+		}
+		if err != nil {
+			logrus.WithError(err).Debug("Instance not yet registered with SSM")
+		} else {
+			logrus.WithField("ping_status", info.PingStatus).Debug("Waiting for SSM agent")
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("instance did not come online in SSM within %s", timeout)
+		case <-time.After(onlinePollInterval):
+		}
+	}
+}
+
 // GetInstanceInformation gets detailed information about an instance from SSM
 func (sm *SSMSessionManager) GetInstanceInformation(ctx context.Context, instanceID string) (*types.InstanceInformation, error) {
 	input := &ssm.DescribeInstanceInformationInput{
@@ -197,3 +529,23 @@ func (sm *SSMSessionManager) ListManagedInstances(ctx context.Context) ([]types.
 
 	return instances, nil
 }
+
+// ListManagedInstanceTags returns the tags of a hybrid managed instance (mi-),
+// which DescribeInstanceInformation does not include
+func (sm *SSMSessionManager) ListManagedInstanceTags(ctx context.Context, instanceID string) (map[string]string, error) {
+	result, err := sm.client.SSMClient.ListTagsForResource(ctx, &ssm.ListTagsForResourceInput{
+		ResourceType: types.ResourceTypeForTaggingManagedInstance,
+		ResourceId:   aws.String(instanceID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags of managed instance %s: %w", instanceID, err)
+	}
+
+	tags := make(map[string]string, len(result.TagList))
+	for _, tag := range result.TagList {
+		if tag.Key != nil && tag.Value != nil {
+			tags[*tag.Key] = *tag.Value
+		}
+	}
+	return tags, nil
+}
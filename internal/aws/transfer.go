@@ -0,0 +1,258 @@
+package aws
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/sirupsen/logrus"
+
+	"github.com/andreclaro/ssm/internal/session"
+)
+
+// InteractiveCommandDocument runs a single command in a session
+const InteractiveCommandDocument = "AWS-StartInteractiveCommand"
+
+// Markers printed by the remote transfer scripts. Everything else the remote
+// side prints is ignored, so banners or stty warnings do not break transfers.
+const (
+	markerReady = "SSMCP-READY"
+	markerOK    = "SSMCP-OK"
+	markerFail  = "SSMCP-FAIL"
+	markerBegin = "SSMCP-BEGIN"
+	markerSum   = "SSMCP-SUM"
+	markerEnd   = "SSMCP-END"
+)
+
+// TransferKind describes what is being transferred
+type TransferKind string
+
+// Transfer kinds. Directories travel as gzipped tar archives.
+const (
+	TransferFile      TransferKind = "file"
+	TransferDirectory TransferKind = "dir"
+)
+
+// ProgressFunc is called as a transfer advances with bytes done and total
+type ProgressFunc func(done, total int64)
+
+// UploadRequest describes a local file or archive to write on an instance
+type UploadRequest struct {
+	Kind       TransferKind
+	Source     io.Reader
+	Size       int64
+	Checksum   string // hex SHA-256 of Source
+	RemotePath string
+	// Name is used when RemotePath is an existing directory
+	Name string
+	Mode os.FileMode
+}
+
+// Upload streams a file or directory archive to the instance over an
+// interactive command session. The remote side verifies the SHA-256 checksum
+// before moving the file into place or extracting the archive.
+func (sm *SSMSessionManager) Upload(ctx context.Context, instanceID string, req UploadRequest, progress ProgressFunc) error {
+	encodedSize := int64(base64.StdEncoding.EncodedLen(int(req.Size)))
+
+	var install string
+	if req.Kind == TransferDirectory {
+		install = fmt.Sprintf(`mkdir -p %s && tar xzf "$t" -C %s && rm -f "$t"`,
+			ShellQuote(req.RemotePath), ShellQuote(req.RemotePath))
+	} else {
+		install = fmt.Sprintf(`d=%s; [ -d "$d" ] && d="$d"/%s; chmod %o "$t" && mv -f "$t" "$d"`,
+			ShellQuote(req.RemotePath), ShellQuote(req.Name), req.Mode.Perm())
+	}
+
+	script := strings.Join([]string{
+		"stty raw -echo 2>/dev/null",
+		fmt.Sprintf(`t=$(mktemp) || { echo %s mktemp failed; exit 1; }`, markerFail),
+		"echo " + markerReady,
+		fmt.Sprintf(`head -c %d | base64 -d > "$t" && echo "%s  $t" | sha256sum -c --status && %s && echo %s || { rm -f "$t"; echo %s checksum verification or write failed; }`,
+			encodedSize, req.Checksum, install, markerOK, markerFail),
+	}, "; ")
+
+	ch, sessionID, err := sm.openCommandChannel(ctx, instanceID, script)
+	if err != nil {
+		return err
+	}
+	defer sm.closeDataChannel(ch, sessionID)
+
+	output := bufio.NewReader(ch)
+	if _, err := waitForMarker(output, markerReady); err != nil {
+		return err
+	}
+
+	// Count raw bytes for progress while the encoder writes to the session
+	encoder := base64.NewEncoder(base64.StdEncoding, ch)
+	src := &progressReader{r: req.Source, total: req.Size, progress: progress}
+	if _, err := io.Copy(encoder, src); err != nil {
+		return fmt.Errorf("failed to send data: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to send data: %w", err)
+	}
+
+	if _, err := waitForMarker(output, markerOK); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Download streams a remote file, or with recursive a directory as a gzipped
+// tar archive, into dst and verifies its SHA-256 checksum. It returns what
+// kind of path was transferred.
+func (sm *SSMSessionManager) Download(ctx context.Context, instanceID, remotePath string, recursive bool, dst io.Writer, progress ProgressFunc) (TransferKind, error) {
+	dirCase := fmt.Sprintf(`echo %s %s; exit 1`, markerFail, ShellQuote(remotePath+": is a directory (use -r)"))
+	if recursive {
+		dirCase = fmt.Sprintf(`t=$(mktemp) && tar czf "$t" -C "$(dirname "$f")" "$(basename "$f")" && k=%s`, TransferDirectory)
+	}
+
+	script := strings.Join([]string{
+		"stty raw -echo 2>/dev/null",
+		"f=" + ShellQuote(remotePath),
+		fmt.Sprintf(`if [ -d "$f" ]; then %s; elif [ -f "$f" ]; then t="$f"; k=%s; else echo %s %s; exit 1; fi`,
+			dirCase, TransferFile, markerFail, ShellQuote(remotePath+": no such file or directory")),
+		fmt.Sprintf(`echo "%s $k $(wc -c < "$t")"`, markerBegin),
+		`base64 "$t"`,
+		fmt.Sprintf(`echo "%s $(sha256sum "$t" | cut -d' ' -f1)"`, markerSum),
+		fmt.Sprintf(`[ "$k" = %s ] && rm -f "$t"`, TransferDirectory),
+		"echo " + markerEnd,
+	}, "; ")
+
+	ch, sessionID, err := sm.openCommandChannel(ctx, instanceID, script)
+	if err != nil {
+		return "", err
+	}
+	defer sm.closeDataChannel(ch, sessionID)
+
+	output := bufio.NewReader(ch)
+	begin, err := waitForMarker(output, markerBegin)
+	if err != nil {
+		return "", err
+	}
+
+	fields := strings.Fields(begin)
+	if len(fields) != 2 {
+		return "", fmt.Errorf("unexpected transfer header %q", begin)
+	}
+	kind := TransferKind(fields[0])
+	total, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("unexpected transfer size %q", fields[1])
+	}
+
+	hash := sha256.New()
+	out := io.MultiWriter(dst, hash)
+	var done int64
+	var remoteSum string
+	for remoteSum == "" {
+		line, err := readLine(output)
+		if err != nil {
+			return "", fmt.Errorf("transfer interrupted: %w", err)
+		}
+		if sum, ok := strings.CutPrefix(line, markerSum+" "); ok {
+			remoteSum = strings.TrimSpace(sum)
+			break
+		}
+
+		data, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			return "", fmt.Errorf("failed to decode transfer data: %w", err)
+		}
+		if _, err := out.Write(data); err != nil {
+			return "", fmt.Errorf("failed to write data: %w", err)
+		}
+		done += int64(len(data))
+		if progress != nil {
+			progress(done, total)
+		}
+	}
+
+	if localSum := hex.EncodeToString(hash.Sum(nil)); localSum != remoteSum {
+		return "", fmt.Errorf("checksum mismatch: local %s, remote %s", localSum, remoteSum)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id": instanceID,
+		"path":        remotePath,
+		"bytes":       done,
+		"sha256":      remoteSum,
+	}).Debug("Download verified")
+
+	return kind, nil
+}
+
+// openCommandChannel starts an interactive command session running command
+func (sm *SSMSessionManager) openCommandChannel(ctx context.Context, instanceID, command string) (*session.Channel, string, error) {
+	return sm.openDataChannel(ctx, &ssm.StartSessionInput{
+		Target:       aws.String(instanceID),
+		DocumentName: aws.String(InteractiveCommandDocument),
+		Parameters: map[string][]string{
+			"command": {command},
+		},
+	}, session.Options{})
+}
+
+// waitForMarker reads output lines until one starts with marker and returns
+// the rest of that line. A failure marker is turned into an error.
+func waitForMarker(r *bufio.Reader, marker string) (string, error) {
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return "", fmt.Errorf("session ended before %s: %w", marker, err)
+		}
+		if rest, ok := strings.CutPrefix(line, markerFail); ok {
+			return "", fmt.Errorf("remote: %s", strings.TrimSpace(rest))
+		}
+		if rest, ok := strings.CutPrefix(line, marker); ok {
+			return strings.TrimSpace(rest), nil
+		}
+	}
+}
+
+// readLine reads a line of session output without line endings
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// ShellQuote quotes s as a single POSIX shell word
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// progressReader reports the number of bytes read to a ProgressFunc
+type progressReader struct {
+	r        io.Reader
+	done     int64
+	total    int64
+	progress ProgressFunc
+}
+
+// Read implements io.Reader
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.done += int64(n)
+	if p.progress != nil && n > 0 {
+		p.progress(p.done, p.total)
+	}
+	return n, err
+}
+
+// RemoteBase returns the last element of a remote (slash separated) path
+func RemoteBase(remotePath string) string {
+	return path.Base(strings.TrimRight(remotePath, "/"))
+}
@@ -21,7 +21,34 @@ type Config struct {
 
 	Discovery struct {
 		TTL string `mapstructure:"ttl"`
+		// ECS enables discovery of ECS tasks with ECS Exec enabled
+		ECS bool `mapstructure:"ecs"`
 	} `mapstructure:"discovery"`
+
+	Session struct {
+		// Client selects how sessions are opened: "native" speaks the
+		// Session Manager protocol in-process, "cli" execs the aws CLI
+		Client string `mapstructure:"client"`
+	} `mapstructure:"session"`
+
+	SSH struct {
+		// UserTag names the instance tag that sets the login user of 'ssm ssh'
+		UserTag string `mapstructure:"user_tag"`
+		// DefaultUser is the login user when neither the tag nor the
+		// platform determines one
+		DefaultUser string `mapstructure:"default_user"`
+	} `mapstructure:"ssh"`
+
+	Organization struct {
+		// Profile is a management or delegated administrator profile whose
+		// organization's accounts are discovered; empty disables it
+		Profile string `mapstructure:"profile"`
+		// Role is the role assumed in every member account
+		Role       string `mapstructure:"role"`
+		ExternalID string `mapstructure:"external_id"`
+		// ExcludeAccounts lists account IDs that are not discovered
+		ExcludeAccounts []string `mapstructure:"exclude_accounts"`
+	} `mapstructure:"organization"`
 }
 
 var globalConfig *Config
@@ -64,4 +91,12 @@ func setDefaults() {
 	viper.SetDefault("aws.max_concurrent_sessions", 5)
 
 	viper.SetDefault("discovery.ttl", "24h")
+	viper.SetDefault("discovery.ecs", true)
+
+	viper.SetDefault("session.client", "native")
+
+	viper.SetDefault("ssh.user_tag", "ssh-user")
+	viper.SetDefault("ssh.default_user", "ec2-user")
+
+	viper.SetDefault("organization.role", "OrganizationAccountAccessRole")
 }
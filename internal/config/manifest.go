@@ -0,0 +1,137 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// ManifestFileName is the name of the project-local tunnel manifest
+const ManifestFileName = ".ssm.yaml"
+
+// ErrManifestNotFound is returned when no manifest exists in the directory tree
+var ErrManifestNotFound = errors.New("no " + ManifestFileName + " found")
+
+// Manifest declares the tunnels a project needs for local development
+type Manifest struct {
+	// Path is the file the manifest was loaded from
+	Path    string           `yaml:"-"`
+	Tunnels []ManifestTunnel `yaml:"tunnels"`
+}
+
+// ManifestTunnel is a named port forward through an instance chosen by name
+// or by tag selector
+type ManifestTunnel struct {
+	Name       string            `yaml:"name"`
+	Instance   string            `yaml:"instance"`
+	Tags       map[string]string `yaml:"tags"`
+	LocalPort  int               `yaml:"local_port"`
+	RemoteHost string            `yaml:"remote_host"`
+	RemotePort int               `yaml:"remote_port"`
+}
+
+// FindManifest searches dir and its parents for a manifest file
+func FindManifest(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+
+	for {
+		path := filepath.Join(dir, ManifestFileName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ErrManifestNotFound
+		}
+		dir = parent
+	}
+}
+
+// LoadManifest reads and validates a manifest file
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	manifest := &Manifest{}
+	if err := yaml.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	manifest.Path = path
+
+	if err := manifest.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return manifest, nil
+}
+
+// Select returns the named tunnels, or every tunnel when no names are given
+func (m *Manifest) Select(names []string) ([]ManifestTunnel, error) {
+	if len(names) == 0 {
+		return m.Tunnels, nil
+	}
+
+	selected := make([]ManifestTunnel, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, tunnel := range m.Tunnels {
+			if tunnel.Name == name {
+				selected = append(selected, tunnel)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("tunnel '%s' is not defined in %s", name, m.Path)
+		}
+	}
+	return selected, nil
+}
+
+// validate checks the manifest and fills in defaults
+func (m *Manifest) validate() error {
+	if len(m.Tunnels) == 0 {
+		return fmt.Errorf("no tunnels defined")
+	}
+
+	names := make(map[string]bool)
+	ports := make(map[int]string)
+	for i := range m.Tunnels {
+		tunnel := &m.Tunnels[i]
+		if tunnel.Name == "" {
+			return fmt.Errorf("tunnel %d has no name", i+1)
+		}
+		if names[tunnel.Name] {
+			return fmt.Errorf("tunnel '%s' is defined more than once", tunnel.Name)
+		}
+		names[tunnel.Name] = true
+
+		if (tunnel.Instance == "") == (len(tunnel.Tags) == 0) {
+			return fmt.Errorf("tunnel '%s' must set exactly one of instance or tags", tunnel.Name)
+		}
+
+		// The remote port defaults to the local port, like -L 5432
+		if tunnel.RemotePort == 0 {
+			tunnel.RemotePort = tunnel.LocalPort
+		}
+		if tunnel.LocalPort < 1 || tunnel.LocalPort > 65535 {
+			return fmt.Errorf("tunnel '%s' has invalid local_port %d", tunnel.Name, tunnel.LocalPort)
+		}
+		if tunnel.RemotePort < 1 || tunnel.RemotePort > 65535 {
+			return fmt.Errorf("tunnel '%s' has invalid remote_port %d", tunnel.Name, tunnel.RemotePort)
+		}
+
+		if other, ok := ports[tunnel.LocalPort]; ok {
+			return fmt.Errorf("tunnels '%s' and '%s' both use local port %d", other, tunnel.Name, tunnel.LocalPort)
+		}
+		ports[tunnel.LocalPort] = tunnel.Name
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestManifest_FindAndLoad tests locating a manifest in a parent directory and loading it
+func TestManifest_FindAndLoad(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "services", "api")
+	require.NoError(t, os.MkdirAll(nested, 0755))
+
+	_, err := FindManifest(nested)
+	assert.ErrorIs(t, err, ErrManifestNotFound)
+
+	content := `tunnels:
+  - name: db
+    instance: bastion
+    local_port: 5432
+    remote_host: mydb.internal
+  - name: api
+    tags:
+      Env: dev
+    local_port: 8080
+    remote_port: 80
+`
+	require.NoError(t, os.WriteFile(filepath.Join(root, ManifestFileName), []byte(content), 0644))
+
+	path, err := FindManifest(nested)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, ManifestFileName), path)
+
+	manifest, err := LoadManifest(path)
+	require.NoError(t, err)
+	require.Len(t, manifest.Tunnels, 2)
+	assert.Equal(t, 5432, manifest.Tunnels[0].RemotePort, "remote port defaults to local port")
+	assert.Equal(t, map[string]string{"Env": "dev"}, manifest.Tunnels[1].Tags)
+
+	selected, err := manifest.Select([]string{"api"})
+	require.NoError(t, err)
+	require.Len(t, selected, 1)
+	assert.Equal(t, "api", selected[0].Name)
+
+	_, err = manifest.Select([]string{"cache"})
+	assert.Error(t, err)
+}
+
+// TestManifest_Validate tests manifest validation errors
+func TestManifest_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest Manifest
+	}{
+		{"no tunnels", Manifest{}},
+		{"missing name", Manifest{Tunnels: []ManifestTunnel{{Instance: "a", LocalPort: 1}}}},
+		{"no target", Manifest{Tunnels: []ManifestTunnel{{Name: "a", LocalPort: 1}}}},
+		{"both targets", Manifest{Tunnels: []ManifestTunnel{{Name: "a", Instance: "a", Tags: map[string]string{"k": "v"}, LocalPort: 1}}}},
+		{"bad port", Manifest{Tunnels: []ManifestTunnel{{Name: "a", Instance: "a", LocalPort: 70000}}}},
+		{"duplicate name", Manifest{Tunnels: []ManifestTunnel{
+			{Name: "a", Instance: "a", LocalPort: 1},
+			{Name: "a", Instance: "a", LocalPort: 2},
+		}}},
+		{"duplicate port", Manifest{Tunnels: []ManifestTunnel{
+			{Name: "a", Instance: "a", LocalPort: 1},
+			{Name: "b", Instance: "a", LocalPort: 1},
+		}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, tt.manifest.validate())
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package finder
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// ErrAborted is returned when the user leaves the finder without a selection
+var ErrAborted = errors.New("aborted")
+
+// Action is what to do with the selected item
+type Action int
+
+// Actions offered on a selection
+const (
+	ActionConnect Action = iota
+	ActionForward
+	ActionCopyID
+)
+
+// Item is an entry in the finder
+type Item struct {
+	// Label is the line shown in the list
+	Label string
+	// Search is the text matched against the query
+	Search string
+	// Preview lines are shown for the highlighted item
+	Preview []string
+}
+
+// Result is the selected item and the action chosen for it
+type Result struct {
+	Index  int
+	Action Action
+}
+
+// previewMinWidth is the terminal width from which the preview is shown
+// beside the list instead of below it
+const previewMinWidth = 100
+
+// helpLine lists the key bindings
+const helpLine = "enter: connect  ctrl-f: forward  ctrl-y: copy ID  esc: quit"
+
+// finder holds the interactive state
+type finder struct {
+	items   []Item
+	query   []rune
+	matches []int
+	cursor  int
+	offset  int
+	out     io.Writer
+	outFd   int
+}
+
+// Run shows the finder on the terminal until an item is chosen or the user
+// quits. Input is read from in, which must be a terminal, and the interface
+// is drawn on out.
+func Run(in *os.File, out *os.File, items []Item) (*Result, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("nothing to choose from")
+	}
+
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to enter raw mode: %w", err)
+	}
+	defer term.Restore(int(in.Fd()), state)
+
+	// Use the alternate screen so the finder leaves no trace
+	fmt.Fprint(out, "\x1b[?1049h")
+	defer fmt.Fprint(out, "\x1b[?1049l")
+
+	f := &finder{items: items, out: out, outFd: int(out.Fd())}
+	f.filter()
+
+	buf := make([]byte, 64)
+	for {
+		f.render()
+
+		n, err := in.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		if result, done := f.handleKey(buf[:n]); done {
+			if result == nil {
+				return nil, ErrAborted
+			}
+			return result, nil
+		}
+	}
+}
+
+// handleKey applies a key press. It reports done with a nil result when the
+// user quits.
+func (f *finder) handleKey(key []byte) (*Result, bool) {
+	switch string(key) {
+	case "\x1b", "\x03", "\x04": // esc, ctrl-c, ctrl-d
+		return nil, true
+	case "\r", "\n":
+		return f.result(ActionConnect)
+	case "\x06": // ctrl-f
+		return f.result(ActionForward)
+	case "\x19": // ctrl-y
+		return f.result(ActionCopyID)
+	case "\x1b[A", "\x1bOA", "\x10": // up, ctrl-p
+		f.move(-1)
+	case "\x1b[B", "\x1bOB", "\x0e": // down, ctrl-n
+		f.move(1)
+	case "\x1b[5~": // page up
+		f.move(-f.listRows())
+	case "\x1b[6~": // page down
+		f.move(f.listRows())
+	case "\x7f", "\x08": // backspace
+		if len(f.query) > 0 {
+			f.query = f.query[:len(f.query)-1]
+			f.filter()
+		}
+	case "\x15": // ctrl-u
+		f.query = nil
+		f.filter()
+	default:
+		if key[0] == 0x1b {
+			// Ignore other escape sequences
+			return nil, false
+		}
+		for len(key) > 0 {
+			r, size := utf8.DecodeRune(key)
+			if r >= ' ' {
+				f.query = append(f.query, r)
+			}
+			key = key[size:]
+		}
+		f.filter()
+	}
+	return nil, false
+}
+
+// result returns the highlighted item with an action, if any item matches
+func (f *finder) result(action Action) (*Result, bool) {
+	if len(f.matches) == 0 {
+		return nil, false
+	}
+	return &Result{Index: f.matches[f.cursor], Action: action}, true
+}
+
+// filter recomputes the matches for the current query
+func (f *finder) filter() {
+	f.matches = Filter(f.items, string(f.query))
+	f.cursor = 0
+	f.offset = 0
+}
+
+// move moves the highlight by delta rows
+func (f *finder) move(delta int) {
+	f.cursor += delta
+	if f.cursor >= len(f.matches) {
+		f.cursor = len(f.matches) - 1
+	}
+	if f.cursor < 0 {
+		f.cursor = 0
+	}
+}
+
+// size returns the terminal size, with a fallback for odd terminals
+func (f *finder) size() (int, int) {
+	width, height, err := term.GetSize(f.outFd)
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// listRows returns how many list rows fit on screen
+func (f *finder) listRows() int {
+	width, height := f.size()
+	rows := height - 3 // prompt, status and help lines
+	if width < previewMinWidth {
+		rows -= rows / 3
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	return rows
+}
+
+// render draws the prompt, list and preview
+func (f *finder) render() {
+	width, height := f.size()
+	rows := f.listRows()
+
+	if f.cursor < f.offset {
+		f.offset = f.cursor
+	}
+	if f.cursor >= f.offset+rows {
+		f.offset = f.cursor - rows + 1
+	}
+
+	listWidth := width
+	side := width >= previewMinWidth
+	if side {
+		listWidth = width / 2
+	}
+
+	var preview []string
+	if len(f.matches) > 0 {
+		preview = f.items[f.matches[f.cursor]].Preview
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	fmt.Fprintf(&b, "\x1b[1m>\x1b[0m %s\r\n", string(f.query))
+	fmt.Fprintf(&b, "\x1b[2m  %d/%d\x1b[0m\r\n", len(f.matches), len(f.items))
+
+	for row := 0; row < rows; row++ {
+		line := ""
+		if idx := f.offset + row; idx < len(f.matches) {
+			line = f.listLine(idx, listWidth)
+		}
+		if side {
+			previewLine := ""
+			if row < len(preview) {
+				previewLine = truncate(preview[row], width-listWidth-3)
+			}
+			line += fmt.Sprintf("\x1b[%dG│ %s", listWidth+1, previewLine)
+		}
+		b.WriteString(line + "\r\n")
+	}
+
+	if !side {
+		b.WriteString(strings.Repeat("─", width) + "\r\n")
+		for row := 0; row < height-rows-4 && row < len(preview); row++ {
+			b.WriteString(truncate(preview[row], width) + "\r\n")
+		}
+	}
+
+	fmt.Fprintf(&b, "\x1b[%d;1H\x1b[2m%s\x1b[0m", height, truncate(helpLine, width))
+	fmt.Fprintf(&b, "\x1b[1;%dH", 3+len(f.query))
+	f.out.Write([]byte(b.String()))
+}
+
+// listLine renders a list row without padding
+func (f *finder) listLine(idx, width int) string {
+	label := truncate(f.items[f.matches[idx]].Label, width-2)
+	if idx == f.cursor {
+		return "\x1b[7m> " + pad(label, width-2) + "\x1b[0m"
+	}
+	return "  " + label
+}
+
+// truncate shortens s to at most width runes
+func truncate(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	if width == 1 {
+		return string(runes[:1])
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// pad right-pads s with spaces to width runes
+func pad(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
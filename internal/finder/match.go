@@ -0,0 +1,92 @@
+package finder
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Scoring bonuses for fuzzy matches
+const (
+	scoreMatch       = 1
+	scoreConsecutive = 8
+	scoreWordStart   = 4
+	scorePrefix      = 8
+)
+
+// Match reports whether every rune of pattern appears in text in order,
+// ignoring case, and scores the match. Consecutive runs, word starts and
+// matches at the start of text score higher.
+func Match(pattern, text string) (int, bool) {
+	if pattern == "" {
+		return 0, true
+	}
+
+	p := []rune(strings.ToLower(pattern))
+	t := []rune(text)
+	score, pi, prev := 0, 0, -2
+	for ti := 0; ti < len(t) && pi < len(p); ti++ {
+		if unicode.ToLower(t[ti]) != p[pi] {
+			continue
+		}
+
+		score += scoreMatch
+		if ti == prev+1 {
+			score += scoreConsecutive
+		}
+		if ti == 0 {
+			score += scorePrefix
+		} else if isBoundary(t[ti-1]) {
+			score += scoreWordStart
+		}
+		prev = ti
+		pi++
+	}
+
+	if pi < len(p) {
+		return 0, false
+	}
+	return score, true
+}
+
+// isBoundary reports whether r separates words in names and tags
+func isBoundary(r rune) bool {
+	return r == ' ' || r == '-' || r == '_' || r == '.' || r == '/' || r == '=' || r == ':'
+}
+
+// Filter returns the indexes of the items matching query, best first.
+// Whitespace separates terms that must all match.
+func Filter(items []Item, query string) []int {
+	terms := strings.Fields(query)
+
+	type scored struct {
+		index int
+		score int
+	}
+	var matches []scored
+	for i, item := range items {
+		total, ok := 0, true
+		for _, term := range terms {
+			score, matched := Match(term, item.Search)
+			if !matched {
+				ok = false
+				break
+			}
+			total += score
+		}
+		if ok {
+			matches = append(matches, scored{index: i, score: total})
+		}
+	}
+
+	// Stable sort keeps the caller's ordering among equal scores
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	indexes := make([]int, len(matches))
+	for i, m := range matches {
+		indexes[i] = m.index
+	}
+	return indexes
+}
@@ -0,0 +1,37 @@
+package finder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMatch tests fuzzy matching and scoring
+func TestMatch(t *testing.T) {
+	_, ok := Match("wb1", "web-1")
+	assert.True(t, ok)
+
+	_, ok = Match("1bw", "web-1")
+	assert.False(t, ok)
+
+	_, ok = Match("WEB", "web-1")
+	assert.True(t, ok, "matching ignores case")
+
+	prefix, _ := Match("web", "web-1")
+	scattered, _ := Match("web", "w-e-b")
+	assert.Greater(t, prefix, scattered)
+}
+
+// TestFilter tests that all terms must match and results are ranked
+func TestFilter(t *testing.T) {
+	items := []Item{
+		{Search: "api-worker i-0aaa prod us-east-1 env=prod"},
+		{Search: "web-1 i-0bbb prod eu-west-1 env=prod role=web"},
+		{Search: "web-1 i-0ccc dev eu-west-1 env=dev role=web"},
+	}
+
+	assert.Equal(t, []int{0, 1, 2}, Filter(items, ""))
+	assert.Equal(t, []int{1, 2}, Filter(items, "web"))
+	assert.Equal(t, []int{1}, Filter(items, "web env=prod"))
+	assert.Empty(t, Filter(items, "nomatch"))
+}
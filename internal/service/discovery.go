@@ -2,13 +2,18 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/smithy-go"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/sync/semaphore"
 
@@ -109,6 +114,42 @@ func (ds *DiscoveryService) DiscoverInstances(ctx context.Context, profiles []st
 	return nil
 }
 
+// OrganizationProfiles returns the profiles that reach the accounts of the
+// organization configured under organization.profile: the profile itself for
+// its own account and an account profile, which assumes organization.role,
+// for every other active account. It returns nil when no organization is
+// configured.
+func (ds *DiscoveryService) OrganizationProfiles(ctx context.Context) ([]string, error) {
+	orgCfg := config.GetConfig().Organization
+	if orgCfg.Profile == "" {
+		return nil, nil
+	}
+
+	// Organizations is a global service, so any region will do
+	client, err := ds.clientManager.GetClient(ctx, orgCfg.Profile, "us-east-1")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get AWS client: %w", err)
+	}
+	accounts, err := client.ListOrganizationAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := []string{orgCfg.Profile}
+	for _, account := range accounts {
+		if account.ID == client.AccountID || slices.Contains(orgCfg.ExcludeAccounts, account.ID) {
+			continue
+		}
+		profiles = append(profiles, aws.AccountProfile(orgCfg.Profile, account.ID))
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile":  orgCfg.Profile,
+		"accounts": len(accounts),
+	}).Info("Discovered organization accounts")
+	return profiles, nil
+}
+
 // discoverInstancesForProfileRegion discovers instances for a specific profile/region
 func (ds *DiscoveryService) discoverInstancesForProfileRegion(ctx context.Context, profile, region string) error {
 	logrus.WithFields(logrus.Fields{
@@ -152,7 +193,9 @@ func (ds *DiscoveryService) discoverInstancesForProfileRegion(ctx context.Contex
 		return fmt.Errorf("failed to list SSM managed instances: %w", err)
 	}
 
-	// Save SSM managed instances in a batch (mi-* only)
+	// Save SSM managed instances in a batch (mi-* only) and merge the agent
+	// status of EC2 instances onto their rows
+	registered := make(map[string]ssmtypes.InstanceInformation, len(managedInstances))
 	var ssmBatch []*storage.Instance
 	for _, mi := range managedInstances {
 		if mi.InstanceId == nil {
@@ -160,9 +203,12 @@ func (ds *DiscoveryService) discoverInstancesForProfileRegion(ctx context.Contex
 		}
 		if len(*mi.InstanceId) >= 3 && (*mi.InstanceId)[:3] == "mi-" {
 			ssmBatch = append(ssmBatch, storage.ConvertSSMManagedInstance(mi, region, profile, client.AccountID))
+		} else {
+			registered[*mi.InstanceId] = mi
 		}
 	}
 	if len(ssmBatch) > 0 {
+		ds.tagManagedInstances(ctx, aws.NewSSMSessionManager(client).ListManagedInstanceTags, ssmBatch)
 		if err := ds.repo.SaveOrUpdateBatch(ssmBatch); err != nil {
 			logrus.WithFields(logrus.Fields{
 				"profile": profile,
@@ -171,9 +217,135 @@ func (ds *DiscoveryService) discoverInstancesForProfileRegion(ctx context.Contex
 		}
 	}
 
+	for _, instance := range batch {
+		if info, ok := registered[instance.InstanceID]; ok {
+			storage.ApplySSMInformation(instance, info)
+		}
+	}
+	if err := ds.repo.UpdateSSMStatus(append(batch, ssmBatch...)); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile": profile,
+			"region":  region,
+		}).WithError(err).Warn("Failed to save SSM agent status")
+	}
+
+	if config.GetConfig().Discovery.ECS {
+		ds.discoverECSTasks(ctx, client, profile, region)
+	}
+
 	return nil
 }
 
+// Hybrid instance tags are listed one instance per call, so the calls run a
+// few at a time and spaced out to stay under the SSM API rate limit
+const (
+	managedInstanceTagConcurrency = 4
+	managedInstanceTagInterval    = 100 * time.Millisecond
+)
+
+// managedInstanceTagLister lists the tags of a hybrid managed instance, see
+// aws.SSMSessionManager.ListManagedInstanceTags
+type managedInstanceTagLister func(ctx context.Context, instanceID string) (map[string]string, error)
+
+// tagManagedInstances sets the tags of hybrid managed instances (mi-) of one
+// profile and region. Tags are optional, so failures are logged and leave an
+// instance untagged, which keeps its recorded tags; access denied stops the
+// listing for the whole batch.
+func (ds *DiscoveryService) tagManagedInstances(ctx context.Context, listTags managedInstanceTagLister, instances []*storage.Instance) {
+	if len(instances) == 0 {
+		return
+	}
+	log := logrus.WithFields(logrus.Fields{
+		"profile": instances[0].Profile,
+		"region":  instances[0].Region,
+	})
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	sem := semaphore.NewWeighted(managedInstanceTagConcurrency)
+	ticker := time.NewTicker(managedInstanceTagInterval)
+	defer ticker.Stop()
+
+	var wg sync.WaitGroup
+	for _, instance := range instances {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil || sem.Acquire(ctx, 1) != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(instance *storage.Instance) {
+			defer wg.Done()
+			defer sem.Release(1)
+
+			tags, err := listTags(ctx, instance.InstanceID)
+			if err != nil {
+				var apiErr smithy.APIError
+				if errors.As(err, &apiErr) && apiErr.ErrorCode() == "AccessDeniedException" {
+					log.WithError(err).Debug("Skipping managed instance tags")
+					cancel()
+				} else if ctx.Err() == nil {
+					log.WithError(err).Warn("Failed to list managed instance tags")
+				}
+				return
+			}
+
+			keys := make([]string, 0, len(tags))
+			for key := range tags {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				instance.Tags = append(instance.Tags, storage.Tag{Key: key, Value: tags[key]})
+			}
+		}(instance)
+	}
+	wg.Wait()
+}
+
+// discoverECSTasks records the running tasks with ECS Exec enabled. ECS is
+// optional, so failures are logged and do not fail discovery.
+func (ds *DiscoveryService) discoverECSTasks(ctx context.Context, client *aws.Client, profile, region string) {
+	log := logrus.WithFields(logrus.Fields{
+		"profile": profile,
+		"region":  region,
+	})
+
+	found, err := aws.NewECSManager(client).ListExecTasks(ctx)
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "AccessDeniedException" {
+			log.WithError(err).Debug("Skipping ECS discovery")
+		} else {
+			log.WithError(err).Warn("Failed to discover ECS tasks")
+		}
+		return
+	}
+
+	tasks := make([]storage.ECSTask, 0, len(found))
+	for _, task := range found {
+		tasks = append(tasks, storage.ECSTask{
+			TaskArn:    task.TaskArn,
+			TaskID:     task.TaskID(),
+			Cluster:    task.Cluster[strings.LastIndex(task.Cluster, "/")+1:],
+			ClusterArn: task.Cluster,
+			Service:    task.Service,
+			Containers: strings.Join(task.Containers, ","),
+			LaunchType: task.LaunchType,
+			LastStatus: task.LastStatus,
+			AccountID:  client.AccountID,
+		})
+	}
+	if err := storage.NewECSTaskRepository().ReplaceAll(profile, region, tasks); err != nil {
+		log.WithError(err).Warn("Failed to save ECS tasks")
+		return
+	}
+	log.WithField("tasks", len(tasks)).Debug("Found ECS tasks")
+}
+
 // describeInstances describes EC2 instances with pagination
 func (ds *DiscoveryService) describeInstances(ctx context.Context, client *aws.Client) ([]types.Instance, error) {
 	input := &ec2.DescribeInstancesInput{}
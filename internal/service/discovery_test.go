@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/andreclaro/ssm/internal/storage"
+)
+
+// managedTags returns the recorded tags of an instance as key=value
+func managedTags(t *testing.T, instanceID string) []string {
+	var tags []string
+	for _, tag := range cachedInstance(t, instanceID).Tags {
+		tags = append(tags, tag.Key+"="+tag.Value)
+	}
+	return tags
+}
+
+// TestTagManagedInstances tests that listed tags replace the recorded tags of
+// hybrid instances and that instances whose tags could not be listed keep
+// theirs
+func TestTagManagedInstances(t *testing.T) {
+	setupTestDB(t)
+	repo := storage.NewInstanceRepository()
+	managed := func(id string, tags ...storage.Tag) *storage.Instance {
+		return &storage.Instance{InstanceID: id, Profile: "dev", Region: "us-east-1", State: "Online", Tags: tags}
+	}
+	require.NoError(t, repo.SaveOrUpdateBatch([]*storage.Instance{
+		managed("mi-0000000000000000a", storage.Tag{Key: "env", Value: "prod"}),
+		managed("mi-0000000000000000b", storage.Tag{Key: "team", Value: "ops"}),
+	}))
+
+	listed := map[string]map[string]string{
+		"mi-0000000000000000a": {"role": "build", "env": "staging"},
+		"mi-0000000000000000c": {"env": "dev"},
+	}
+	listTags := func(ctx context.Context, instanceID string) (map[string]string, error) {
+		if tags, ok := listed[instanceID]; ok {
+			return tags, nil
+		}
+		return nil, fmt.Errorf("throttled")
+	}
+
+	batch := []*storage.Instance{managed("mi-0000000000000000a"), managed("mi-0000000000000000b"), managed("mi-0000000000000000c")}
+	(&DiscoveryService{}).tagManagedInstances(context.Background(), listTags, batch)
+	require.NoError(t, repo.SaveOrUpdateBatch(batch))
+
+	assert.ElementsMatch(t, []string{"env=staging", "role=build"}, managedTags(t, "mi-0000000000000000a"))
+	assert.Equal(t, []string{"team=ops"}, managedTags(t, "mi-0000000000000000b"))
+	assert.Equal(t, []string{"env=dev"}, managedTags(t, "mi-0000000000000000c"))
+
+	// Access denied leaves the whole batch untagged, keeping recorded tags
+	denied := func(ctx context.Context, instanceID string) (map[string]string, error) {
+		return nil, &smithy.GenericAPIError{Code: "AccessDeniedException"}
+	}
+	batch = []*storage.Instance{managed("mi-0000000000000000a"), managed("mi-0000000000000000c")}
+	(&DiscoveryService{}).tagManagedInstances(context.Background(), denied, batch)
+	require.NoError(t, repo.SaveOrUpdateBatch(batch))
+
+	assert.ElementsMatch(t, []string{"env=staging", "role=build"}, managedTags(t, "mi-0000000000000000a"))
+	assert.Equal(t, []string{"env=dev"}, managedTags(t, "mi-0000000000000000c"))
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+
+	"github.com/andreclaro/ssm/internal/aws"
+	"github.com/andreclaro/ssm/internal/config"
+	"github.com/andreclaro/ssm/internal/storage"
+)
+
+// Diagnostic check outcomes
+const (
+	CheckOK   = "ok"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+	CheckSkip = "skip"
+)
+
+// DiagnosticCheck is the outcome of one connectivity check
+type DiagnosticCheck struct {
+	Name   string
+	Status string
+	Detail string
+	// Hint explains how to fix a failed or degraded check
+	Hint string
+}
+
+// Diagnose checks every layer a Session Manager connection to the instance
+// depends on, from local tooling and credentials to the SSM agent. Checks
+// that depend on a failed one are skipped.
+func (s *Service) Diagnose(ctx context.Context, instance *storage.Instance) []DiagnosticCheck {
+	checks := localToolChecks()
+
+	client, err := aws.NewClientManager().GetClient(ctx, instance.Profile, instance.Region)
+	if err == nil {
+		_, err = client.CallerARN(ctx)
+	}
+	credentials := credentialsCheck(instance.Profile, client, err)
+	checks = append(checks, credentials)
+	if credentials.Status == CheckFail {
+		for _, name := range []string{"EC2 state", "IAM instance profile", "SSM registration", "SSM agent version", "Session preferences"} {
+			checks = append(checks, DiagnosticCheck{Name: name, Status: CheckSkip, Detail: "requires valid credentials"})
+		}
+		return checks
+	}
+
+	if aws.IsEC2Instance(instance.InstanceID) {
+		ec2Instance, err := aws.NewEC2InstanceManager(client).DescribeInstance(ctx, instance.InstanceID)
+		checks = append(checks, ec2StateCheck(ec2Instance, err), instanceProfileCheck(ec2Instance, err))
+	} else {
+		detail := "hybrid managed instance (not EC2)"
+		checks = append(checks,
+			DiagnosticCheck{Name: "EC2 state", Status: CheckSkip, Detail: detail},
+			DiagnosticCheck{Name: "IAM instance profile", Status: CheckSkip, Detail: detail})
+	}
+
+	ssmManager := aws.NewSSMSessionManager(client)
+	info, err := ssmManager.GetInstanceInformation(ctx, instance.InstanceID)
+	checks = append(checks, registrationCheck(info, err), agentVersionCheck(info, err))
+
+	prefs, err := ssmManager.GetSessionPreferences(ctx)
+	checks = append(checks, preferencesCheck(prefs, err))
+	return checks
+}
+
+// localToolChecks reports whether the AWS CLI and session-manager-plugin are
+// installed. They are only required with session.client: cli.
+func localToolChecks() []DiagnosticCheck {
+	cfg := config.GetConfig()
+	required := cfg != nil && cfg.Session.Client == "cli"
+
+	var checks []DiagnosticCheck
+	for _, tool := range []struct{ name, hint string }{
+		{"aws", "Install the AWS CLI v2: https://docs.aws.amazon.com/cli/latest/userguide/getting-started-install.html"},
+		{"session-manager-plugin", "Install the Session Manager plugin: https://docs.aws.amazon.com/systems-manager/latest/userguide/session-manager-working-with-install-plugin.html"},
+	} {
+		check := DiagnosticCheck{Name: "Local " + tool.name}
+		if path, err := exec.LookPath(tool.name); err == nil {
+			check.Status = CheckOK
+			check.Detail = path
+		} else if required {
+			check.Status = CheckFail
+			check.Detail = "not found in PATH (required by session.client: cli)"
+			check.Hint = tool.hint
+		} else {
+			check.Status = CheckOK
+			check.Detail = "not installed (not needed by the native session client)"
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// credentialsCheck reports whether the profile's credentials are valid
+func credentialsCheck(profile string, client *aws.Client, err error) DiagnosticCheck {
+	check := DiagnosticCheck{Name: "Credentials"}
+	if err != nil {
+		check.Status = CheckFail
+		check.Detail = err.Error()
+		check.Hint = fmt.Sprintf("Refresh the credentials of profile '%s', e.g. aws sso login --profile %s", profile, profile)
+		return check
+	}
+	check.Status = CheckOK
+	check.Detail = fmt.Sprintf("profile %s, account %s", profile, client.AccountID)
+	return check
+}
+
+// ec2StateCheck reports the live EC2 state of the instance
+func ec2StateCheck(instance *ec2types.Instance, err error) DiagnosticCheck {
+	check := DiagnosticCheck{Name: "EC2 state"}
+	if err != nil {
+		check.Status = CheckFail
+		check.Detail = err.Error()
+		check.Hint = "Check that the instance still exists and the profile may call ec2:DescribeInstances; run 'ssm sync' to refresh the cache"
+		return check
+	}
+
+	if instance.State == nil {
+		check.Status = CheckWarn
+		check.Detail = "unknown"
+		check.Hint = "EC2 did not report a state; check the instance in the EC2 console"
+		return check
+	}
+
+	state := string(instance.State.Name)
+	check.Detail = state
+	switch state {
+	case aws.InstanceStateRunning:
+		check.Status = CheckOK
+	case aws.InstanceStateStopped, aws.InstanceStateStopping:
+		check.Status = CheckFail
+		check.Hint = "Start it with 'ssm start <name>' or connect with --start"
+	case aws.InstanceStatePending:
+		check.Status = CheckWarn
+		check.Hint = "The instance is booting; wait with 'ssm wait <name>'"
+	default:
+		check.Status = CheckFail
+		check.Hint = "The instance cannot be connected to in this state"
+	}
+	return check
+}
+
+// instanceProfileCheck reports whether the instance has an IAM instance profile
+func instanceProfileCheck(instance *ec2types.Instance, err error) DiagnosticCheck {
+	check := DiagnosticCheck{Name: "IAM instance profile"}
+	if err != nil {
+		check.Status = CheckSkip
+		check.Detail = "EC2 instance not described"
+		return check
+	}
+	if instance.IamInstanceProfile == nil || instance.IamInstanceProfile.Arn == nil {
+		check.Status = CheckWarn
+		check.Detail = "none attached"
+		check.Hint = "Attach an instance profile with the AmazonSSMManagedInstanceCore policy, unless Default Host Management Configuration is enabled"
+		return check
+	}
+	check.Status = CheckOK
+	check.Detail = *instance.IamInstanceProfile.Arn
+	return check
+}
+
+// registrationCheck reports the instance's SSM registration and last ping
+func registrationCheck(info *ssmtypes.InstanceInformation, err error) DiagnosticCheck {
+	check := DiagnosticCheck{Name: "SSM registration"}
+	if err != nil {
+		check.Status = CheckFail
+		check.Detail = err.Error()
+		check.Hint = "Ensure the SSM agent is installed and running, the instance profile allows SSM, and the instance can reach the ssm, ssmmessages and ec2messages endpoints (internet, NAT or VPC endpoints)"
+		return check
+	}
+
+	check.Detail = string(info.PingStatus)
+	if info.LastPingDateTime != nil {
+		check.Detail += fmt.Sprintf(", last ping %s ago", time.Since(*info.LastPingDateTime).Round(time.Second))
+	}
+	if info.PingStatus == ssmtypes.PingStatusOnline {
+		check.Status = CheckOK
+		return check
+	}
+	check.Status = CheckFail
+	check.Hint = "The agent stopped reporting: check that the instance is running, the amazon-ssm-agent service is up, and its network path to the SSM endpoints"
+	return check
+}
+
+// agentVersionCheck reports the SSM agent version
+func agentVersionCheck(info *ssmtypes.InstanceInformation, err error) DiagnosticCheck {
+	check := DiagnosticCheck{Name: "SSM agent version"}
+	if err != nil || info.AgentVersion == nil {
+		check.Status = CheckSkip
+		check.Detail = "instance not registered with SSM"
+		return check
+	}
+
+	check.Detail = *info.AgentVersion
+	if info.IsLatestVersion != nil && !*info.IsLatestVersion {
+		check.Status = CheckWarn
+		check.Detail += " (update available)"
+		check.Hint = "Update the agent by running the AWS-UpdateSSMAgent document with Run Command"
+		return check
+	}
+	check.Status = CheckOK
+	return check
+}
+
+// preferencesCheck summarizes the effective Session Manager preferences
+func preferencesCheck(prefs *aws.SessionPreferences, err error) DiagnosticCheck {
+	check := DiagnosticCheck{Name: "Session preferences"}
+	if err != nil {
+		check.Status = CheckWarn
+		check.Detail = err.Error()
+		check.Hint = "Grant ssm:GetDocument on " + aws.SessionPreferencesDocument + " to inspect the preferences"
+		return check
+	}
+	check.Status = CheckOK
+	if !prefs.Exists {
+		check.Detail = "defaults (no " + aws.SessionPreferencesDocument + " document)"
+		return check
+	}
+
+	var details []string
+	if prefs.IdleSessionTimeout != "" {
+		details = append(details, "idle timeout "+prefs.IdleSessionTimeout+"m")
+	}
+	if prefs.MaxSessionDuration != "" {
+		details = append(details, "max duration "+prefs.MaxSessionDuration+"m")
+	}
+	if prefs.RunAsEnabled {
+		details = append(details, "run as "+prefs.RunAsDefaultUser)
+	}
+	if prefs.S3BucketName != "" {
+		details = append(details, "S3 logs to "+prefs.S3BucketName)
+	}
+	if prefs.CloudWatchLogGroupName != "" {
+		details = append(details, "CloudWatch logs to "+prefs.CloudWatchLogGroupName)
+	}
+	if prefs.KMSKeyID != "" {
+		details = append(details, "KMS key "+prefs.KMSKeyID)
+		check.Hint = "Sessions are KMS encrypted: your identity needs kms:GenerateDataKey and the instance profile kms:Decrypt on the key"
+		if cfg := config.GetConfig(); cfg == nil || cfg.Session.Client != "cli" {
+			// The native client refuses KMS encrypted sessions
+			check.Status = CheckFail
+			check.Hint = "Sessions are KMS encrypted, which the native session client does not support: set session.client: cli in the config. " +
+				"Your identity also needs kms:GenerateDataKey and the instance profile kms:Decrypt on the key"
+		}
+	}
+	if prefs.RunAsEnabled && prefs.RunAsDefaultUser != "" {
+		if check.Hint != "" {
+			check.Hint += ". "
+		}
+		check.Hint += "Run As requires the user " + prefs.RunAsDefaultUser + " (or your SSMSessionRunAs tag) to exist on the instance"
+	}
+	if len(details) == 0 {
+		details = append(details, "document present with default settings")
+	}
+	check.Detail = strings.Join(details, ", ")
+	return check
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ssmaws "github.com/andreclaro/ssm/internal/aws"
+	"github.com/andreclaro/ssm/internal/config"
+)
+
+// TestDiagnosticChecks tests the status and hints of individual checks
+func TestDiagnosticChecks(t *testing.T) {
+	check := registrationCheck(nil, errors.New("instance not found in SSM inventory"))
+	assert.Equal(t, CheckFail, check.Status)
+	assert.NotEmpty(t, check.Hint)
+
+	check = registrationCheck(&ssmtypes.InstanceInformation{
+		PingStatus:       ssmtypes.PingStatusConnectionLost,
+		LastPingDateTime: aws.Time(time.Now().Add(-time.Hour)),
+	}, nil)
+	assert.Equal(t, CheckFail, check.Status)
+	assert.Contains(t, check.Detail, "last ping 1h0m0s ago")
+
+	check = ec2StateCheck(&ec2types.Instance{State: &ec2types.InstanceState{Name: ec2types.InstanceStateNameStopped}}, nil)
+	assert.Equal(t, CheckFail, check.Status)
+	assert.Equal(t, "stopped", check.Detail)
+
+	check = ec2StateCheck(&ec2types.Instance{}, nil)
+	assert.Equal(t, CheckWarn, check.Status)
+	assert.Equal(t, "unknown", check.Detail)
+
+	check = agentVersionCheck(&ssmtypes.InstanceInformation{
+		AgentVersion:    aws.String("3.2.0.0"),
+		IsLatestVersion: aws.Bool(false),
+	}, nil)
+	assert.Equal(t, CheckWarn, check.Status)
+
+	check = preferencesCheck(&ssmaws.SessionPreferences{}, nil)
+	assert.Equal(t, CheckOK, check.Status)
+	assert.Contains(t, check.Detail, "defaults")
+
+}
+
+// TestPreferencesCheck_KMS tests that KMS encrypted sessions fail with the
+// native session client
+func TestPreferencesCheck_KMS(t *testing.T) {
+	require.NoError(t, config.InitConfig(""))
+	prefs := &ssmaws.SessionPreferences{Exists: true, IdleSessionTimeout: "20", KMSKeyID: "alias/ssm"}
+
+	check := preferencesCheck(prefs, nil)
+	assert.Equal(t, CheckFail, check.Status)
+	assert.Equal(t, "idle timeout 20m, KMS key alias/ssm", check.Detail)
+	assert.Contains(t, check.Hint, "session.client: cli")
+	assert.Contains(t, check.Hint, "kms:GenerateDataKey")
+
+	config.GetConfig().Session.Client = "cli"
+	defer func() { config.GetConfig().Session.Client = "native" }()
+
+	check = preferencesCheck(prefs, nil)
+	assert.Equal(t, CheckOK, check.Status)
+	assert.NotContains(t, check.Hint, "session.client")
+	assert.Contains(t, check.Hint, "kms:GenerateDataKey")
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/andreclaro/ssm/internal/aws"
+	"github.com/andreclaro/ssm/internal/storage"
+)
+
+// DefaultECSCommand is the command ECS Exec runs when none is given
+const DefaultECSCommand = "/bin/sh"
+
+// ListECSTasks returns the discovered tasks with ECS Exec enabled
+func (s *Service) ListECSTasks() ([]storage.ECSTask, error) {
+	return storage.NewECSTaskRepository().List()
+}
+
+// ResolveECSTarget resolves a service or task to a single task and container.
+// Any task of a service will do; a service name shared by several clusters,
+// regions or accounts must be qualified. When container is empty the task must have
+// exactly one exec-enabled container.
+func (s *Service) ResolveECSTarget(target, container string) (*storage.ECSTask, string, error) {
+	tasks, err := storage.NewECSTaskRepository().FindByTarget(target)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(tasks) == 0 {
+		return nil, "", fmt.Errorf("no ECS task or service '%s' with ECS Exec enabled; run 'ssm sync' to refresh", target)
+	}
+
+	// Tasks of one service in one cluster are interchangeable
+	groups := make(map[string]bool)
+	var qualified []string
+	for _, task := range tasks {
+		key := task.Profile + "/" + task.Region + "/" + task.Cluster + "/" + task.Service
+		if task.Service == "" {
+			key += "/" + task.TaskID
+		}
+		if !groups[key] {
+			groups[key] = true
+			qualified = append(qualified, fmt.Sprintf("  %s/%s/%s/%s  (account %s)", task.Profile, task.Region, task.Cluster, ecsTaskLabel(&task), task.AccountID))
+		}
+	}
+	if len(groups) > 1 {
+		return nil, "", fmt.Errorf("'%s' matches tasks in %d clusters or services; target one with profile/region/cluster/service or a task ID:\n%s",
+			target, len(groups), strings.Join(qualified, "\n"))
+	}
+
+	task := &tasks[0]
+	containers := task.ContainerNames()
+	switch {
+	case container != "":
+		if !slices.Contains(containers, container) {
+			return nil, "", fmt.Errorf("task %s has no exec-enabled container '%s' (available: %s)", task.TaskID, container, strings.Join(containers, ", "))
+		}
+	case len(containers) == 1:
+		container = containers[0]
+	case len(containers) == 0:
+		return nil, "", fmt.Errorf("task %s has no container with a running ECS Exec agent", task.TaskID)
+	default:
+		return nil, "", fmt.Errorf("task %s has several containers; choose one with --container: %s", task.TaskID, strings.Join(containers, ", "))
+	}
+	return task, container, nil
+}
+
+// ExecECS opens an interactive ECS Exec session running command in a task's
+// container
+func (s *Service) ExecECS(ctx context.Context, task *storage.ECSTask, container, command string) error {
+	client, err := aws.NewClientManager().GetClient(ctx, task.Profile, task.Region)
+	if err != nil {
+		return fmt.Errorf("failed to get AWS client: %w", err)
+	}
+
+	if err := aws.NewECSManager(client).ExecuteCommand(ctx, task.ClusterArn, task.TaskArn, container, command); err != nil {
+		return fmt.Errorf("%w (if the task has stopped, run 'ssm sync' to refresh)", err)
+	}
+	return nil
+}
+
+// ecsTaskLabel names a task by its service, or its task ID when it has none
+func ecsTaskLabel(task *storage.ECSTask) string {
+	if task.Service != "" {
+		return task.Service
+	}
+	return task.TaskID
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/semaphore"
+
+	"github.com/andreclaro/ssm/internal/aws"
+	"github.com/andreclaro/ssm/internal/config"
+	"github.com/andreclaro/ssm/internal/storage"
+)
+
+// ExecFailureExitCode is reported for instances where the command could not run
+const ExecFailureExitCode = 255
+
+// ExecOnInstances runs a command via Run Command on every named instance and
+// every reachable instance matching the tag selector, if one is given, and
+// writes each instance's output with a per-host prefix. It returns the highest
+// exit code across instances, so zero means the command succeeded everywhere.
+func (s *Service) ExecOnInstances(ctx context.Context, instanceNames []string, selector, command string, timeout time.Duration, stdout, stderr io.Writer) (int, error) {
+	if len(instanceNames) == 0 && selector == "" {
+		return 0, fmt.Errorf("no instances provided")
+	}
+
+	// Resolve every target before running anything
+	instances, err := resolveTargets(instanceNames, selector, true)
+	if err != nil {
+		return 0, err
+	}
+
+	clientManager := aws.NewClientManager()
+	run := func(ctx context.Context, instance *storage.Instance, command string, timeout time.Duration) (*aws.CommandResult, error) {
+		client, err := clientManager.GetClient(ctx, instance.Profile, instance.Region)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get AWS client: %w", err)
+		}
+		document := aws.CommandDocumentForPlatform(instance.Platform)
+		return aws.NewSSMSessionManager(client).RunCommand(ctx, instance.InstanceID, document, command, timeout)
+	}
+	return execOnInstances(ctx, run, instances, command, timeout, stdout, stderr), nil
+}
+
+// commandRunner runs a command on one instance through Run Command
+type commandRunner func(ctx context.Context, instance *storage.Instance, command string, timeout time.Duration) (*aws.CommandResult, error)
+
+// execOnInstances runs the command concurrently on every instance and returns
+// the highest exit code
+func execOnInstances(ctx context.Context, run commandRunner, instances []*storage.Instance, command string, timeout time.Duration, stdout, stderr io.Writer) int {
+	// Align prefixes so output from different hosts lines up
+	width := 0
+	for _, instance := range instances {
+		if l := len(instance.DisplayName()); l > width {
+			width = l
+		}
+	}
+
+	sem := semaphore.NewWeighted(maxConcurrentSessions())
+
+	var (
+		wg       sync.WaitGroup
+		outMu    sync.Mutex
+		exitCode int
+	)
+	for _, instance := range instances {
+		wg.Add(1)
+		go func(instance *storage.Instance) {
+			defer wg.Done()
+
+			prefix := fmt.Sprintf("%-*s | ", width, instance.DisplayName())
+			code, result, err := execOnInstance(ctx, sem, run, instance, command, timeout)
+
+			outMu.Lock()
+			defer outMu.Unlock()
+			if code > exitCode {
+				exitCode = code
+			}
+			if err != nil {
+				fmt.Fprintf(stderr, "%serror: %v\n", prefix, err)
+				return
+			}
+			writePrefixed(stdout, prefix, result.Stdout)
+			writePrefixed(stderr, prefix, result.Stderr)
+			if result.Status != "Success" {
+				fmt.Fprintf(stderr, "%s%s (exit code %d)\n", prefix, result.Status, result.ExitCode)
+			}
+		}(instance)
+	}
+	wg.Wait()
+
+	return exitCode
+}
+
+// execOnInstance runs the command on a single instance and maps the outcome to an exit code
+func execOnInstance(ctx context.Context, sem *semaphore.Weighted, run commandRunner, instance *storage.Instance, command string, timeout time.Duration) (int, *aws.CommandResult, error) {
+	if err := sem.Acquire(ctx, 1); err != nil {
+		return ExecFailureExitCode, nil, err
+	}
+	defer sem.Release(1)
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id": instance.InstanceID,
+		"name":        instance.Name,
+		"profile":     instance.Profile,
+		"region":      instance.Region,
+	}).Debug("Running command on instance")
+
+	result, err := run(ctx, instance, command, timeout)
+	if err != nil {
+		return ExecFailureExitCode, nil, err
+	}
+
+	code := result.ExitCode
+	if result.Status != "Success" && code <= 0 {
+		// Timed out or cancelled commands have no meaningful exit code
+		code = ExecFailureExitCode
+	}
+	return code, result, nil
+}
+
+// maxConcurrentSessions returns aws.max_concurrent_sessions, at least 1 so a
+// zero or negative setting cannot block every worker
+func maxConcurrentSessions() int64 {
+	return max(int64(config.GetConfig().AWS.MaxConcurrentSessions), 1)
+}
+
+// writePrefixed writes each line of output with the given prefix
+func writePrefixed(w io.Writer, prefix, output string) {
+	if output == "" {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		fmt.Fprintf(w, "%s%s\n", prefix, line)
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/andreclaro/ssm/internal/aws"
+	"github.com/andreclaro/ssm/internal/config"
+	"github.com/andreclaro/ssm/internal/storage"
+)
+
+// TestExecOnInstances tests that commands fan out to every instance and the
+// highest exit code is returned
+func TestExecOnInstances(t *testing.T) {
+	require.NoError(t, config.InitConfig(""))
+
+	instances := []*storage.Instance{
+		{InstanceID: "i-0000000000000001", Name: "web-1"},
+		{InstanceID: "i-0000000000000002", Name: "web-2"},
+		{InstanceID: "i-0000000000000003"},
+		{InstanceID: "i-0000000000000004", Name: "db"},
+	}
+	results := map[string]*aws.CommandResult{
+		"i-0000000000000001": {Status: "Success", Stdout: "up 3 days\n"},
+		"i-0000000000000002": {Status: "Failed", ExitCode: 2, Stderr: "no such file\n"},
+		"i-0000000000000003": {Status: "TimedOut", ExitCode: -1},
+	}
+
+	var (
+		mu  sync.Mutex
+		ran []string
+	)
+	run := func(ctx context.Context, instance *storage.Instance, command string, timeout time.Duration) (*aws.CommandResult, error) {
+		mu.Lock()
+		ran = append(ran, instance.InstanceID)
+		mu.Unlock()
+		assert.Equal(t, "uptime", command)
+		assert.Equal(t, time.Minute, timeout)
+		if result, ok := results[instance.InstanceID]; ok {
+			return result, nil
+		}
+		return nil, fmt.Errorf("instance not connected")
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := execOnInstances(context.Background(), run, instances, "uptime", time.Minute, &stdout, &stderr)
+
+	assert.Equal(t, ExecFailureExitCode, code)
+	assert.ElementsMatch(t, []string{"i-0000000000000001", "i-0000000000000002", "i-0000000000000003", "i-0000000000000004"}, ran)
+	assert.Equal(t, "web-1              | up 3 days\n", stdout.String())
+	assert.Contains(t, stderr.String(), "web-2              | no such file\n")
+	assert.Contains(t, stderr.String(), "web-2              | Failed (exit code 2)\n")
+	assert.Contains(t, stderr.String(), "i-0000000000000003 | TimedOut (exit code -1)\n")
+	assert.Contains(t, stderr.String(), "db                 | error: instance not connected\n")
+}
+
+// TestExecOnInstances_ExitCode tests that a failed command's exit code is
+// returned when every instance ran it
+func TestExecOnInstances_ExitCode(t *testing.T) {
+	require.NoError(t, config.InitConfig(""))
+
+	instances := []*storage.Instance{
+		{InstanceID: "i-0000000000000001", Name: "web-1"},
+		{InstanceID: "i-0000000000000002", Name: "web-2"},
+	}
+	run := func(ctx context.Context, instance *storage.Instance, command string, timeout time.Duration) (*aws.CommandResult, error) {
+		if instance.Name == "web-2" {
+			return &aws.CommandResult{Status: "Failed", ExitCode: 3}, nil
+		}
+		return &aws.CommandResult{Status: "Success"}, nil
+	}
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 3, execOnInstances(context.Background(), run, instances, "true", time.Minute, &stdout, &stderr))
+
+	run = func(ctx context.Context, instance *storage.Instance, command string, timeout time.Duration) (*aws.CommandResult, error) {
+		return &aws.CommandResult{Status: "Success"}, nil
+	}
+	assert.Equal(t, 0, execOnInstances(context.Background(), run, instances, "true", time.Minute, &stdout, &stderr))
+}
+
+// TestWritePrefixed tests that every output line gets the host prefix
+func TestWritePrefixed(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"", ""},
+		{"one", "web | one\n"},
+		{"one\ntwo\n", "web | one\nweb | two\n"},
+		{"one\n\nthree\n\n", "web | one\nweb | \nweb | three\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		writePrefixed(&buf, "web | ", tt.output)
+		assert.Equal(t, tt.want, buf.String(), tt.output)
+	}
+}
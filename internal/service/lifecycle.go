@@ -0,0 +1,283 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/andreclaro/ssm/internal/aws"
+	"github.com/andreclaro/ssm/internal/storage"
+)
+
+// States accepted by WaitForInstance
+const (
+	WaitStateOnline  = "online"
+	WaitStateRunning = "running"
+	WaitStateStopped = "stopped"
+)
+
+// Instance lifecycle actions
+const (
+	LifecycleStart   = "start"
+	LifecycleStop    = "stop"
+	LifecycleReboot  = "reboot"
+	LifecycleProtect = "protect"
+	// LifecycleUnprotect disables termination protection
+	LifecycleUnprotect = "unprotect"
+)
+
+// DefaultStartTimeout bounds how long --start waits for an instance to boot
+// and register with SSM
+const DefaultStartTimeout = 10 * time.Minute
+
+// WaitForInstance waits up to timeout for an instance to reach state: SSM
+// Online, or the EC2 running or stopped state. The cached state is updated
+// once it is reached.
+func (s *Service) WaitForInstance(ctx context.Context, instance *storage.Instance, state string, timeout time.Duration) error {
+	if state != WaitStateOnline && state != WaitStateRunning && state != WaitStateStopped {
+		return fmt.Errorf("invalid state '%s': use online, running or stopped", state)
+	}
+
+	client, err := aws.NewClientManager().GetClient(ctx, instance.Profile, instance.Region)
+	if err != nil {
+		return fmt.Errorf("failed to get AWS client: %w", err)
+	}
+	return waitForInstance(ctx, aws.NewEC2InstanceManager(client), aws.NewSSMSessionManager(client), instance, state, timeout)
+}
+
+// waitForInstance waits for an instance to reach state like WaitForInstance
+func waitForInstance(ctx context.Context, ec2Manager instanceController, agent agentWaiter, instance *storage.Instance, state string, timeout time.Duration) error {
+	switch state {
+	case WaitStateOnline:
+		if err := agent.WaitForOnline(ctx, instance.InstanceID, timeout); err != nil {
+			return err
+		}
+		updateCachedPingStatus(instance, "Online")
+	default:
+		if !aws.IsEC2Instance(instance.InstanceID) {
+			return fmt.Errorf("%s is not an EC2 instance; only the online state can be awaited", instance.InstanceID)
+		}
+		if err := ec2Manager.WaitForState(ctx, instance.InstanceID, state, timeout); err != nil {
+			refreshCachedState(ctx, ec2Manager, instance)
+			return err
+		}
+		updateCachedState(instance, state)
+	}
+	return nil
+}
+
+// EnsureOnline starts a stopped EC2 instance and waits up to timeout for it
+// to run and for its SSM agent to come online. Progress is written to out.
+func (s *Service) EnsureOnline(ctx context.Context, instance *storage.Instance, timeout time.Duration, out io.Writer) error {
+	if !aws.IsEC2Instance(instance.InstanceID) {
+		return nil
+	}
+
+	client, err := aws.NewClientManager().GetClient(ctx, instance.Profile, instance.Region)
+	if err != nil {
+		return fmt.Errorf("failed to get AWS client: %w", err)
+	}
+	return ensureOnline(ctx, aws.NewEC2InstanceManager(client), aws.NewSSMSessionManager(client), instance, timeout, out)
+}
+
+// ensureOnline starts an EC2 instance and waits for it like EnsureOnline
+func ensureOnline(ctx context.Context, ec2Manager instanceController, agent agentWaiter, instance *storage.Instance, timeout time.Duration, out io.Writer) error {
+	deadline := time.Now().Add(timeout)
+	state, err := ec2Manager.InstanceState(ctx, instance.InstanceID)
+	if err != nil {
+		return err
+	}
+	logrus.WithFields(logrus.Fields{
+		"instance_id": instance.InstanceID,
+		"state":       state,
+	}).Debug("Checked EC2 instance state")
+
+	switch state {
+	case aws.InstanceStateRunning:
+		if instance.State != aws.InstanceStateRunning {
+			updateCachedState(instance, aws.InstanceStateRunning)
+		}
+	case aws.InstanceStateStopping:
+		fmt.Fprintf(out, "Waiting for %s to stop before starting it...\n", instance.DisplayName())
+		if err := ec2Manager.WaitForState(ctx, instance.InstanceID, aws.InstanceStateStopped, time.Until(deadline)); err != nil {
+			refreshCachedState(ctx, ec2Manager, instance)
+			return err
+		}
+		fallthrough
+	case aws.InstanceStateStopped:
+		fmt.Fprintf(out, "Starting %s...\n", instance.DisplayName())
+		if err := ec2Manager.StartInstance(ctx, instance.InstanceID); err != nil {
+			return err
+		}
+		fallthrough
+	case aws.InstanceStatePending:
+		fmt.Fprintf(out, "Waiting for %s to be running...\n", instance.DisplayName())
+		if err := ec2Manager.WaitForState(ctx, instance.InstanceID, aws.InstanceStateRunning, time.Until(deadline)); err != nil {
+			refreshCachedState(ctx, ec2Manager, instance)
+			return err
+		}
+		updateCachedState(instance, aws.InstanceStateRunning)
+	default:
+		updateCachedState(instance, state)
+		return fmt.Errorf("instance is %s and cannot be started", state)
+	}
+
+	fmt.Fprintf(out, "Waiting for the SSM agent on %s to come online...\n", instance.DisplayName())
+	if err := agent.WaitForOnline(ctx, instance.InstanceID, time.Until(deadline)); err != nil {
+		return err
+	}
+	updateCachedPingStatus(instance, "Online")
+	return nil
+}
+
+// ChangeInstanceStates applies a lifecycle action to every instance in
+// parallel and reports each outcome on out. Start and stop wait up to timeout
+// for the instance to reach its new state, which is then cached.
+func (s *Service) ChangeInstanceStates(ctx context.Context, instances []*storage.Instance, action string, timeout time.Duration, out io.Writer) error {
+	clientManager := aws.NewClientManager()
+
+	var (
+		wg     sync.WaitGroup
+		outMu  sync.Mutex
+		failed int
+	)
+	for _, instance := range instances {
+		wg.Add(1)
+		go func(instance *storage.Instance) {
+			defer wg.Done()
+
+			result, err := s.changeInstanceState(ctx, clientManager, instance, action, timeout)
+
+			outMu.Lock()
+			defer outMu.Unlock()
+			if err != nil {
+				fmt.Fprintf(out, "%s: %v\n", instance.DisplayName(), err)
+				failed++
+				return
+			}
+			fmt.Fprintf(out, "%s: %s\n", instance.DisplayName(), result)
+		}(instance)
+	}
+	wg.Wait()
+
+	if failed > 0 {
+		return fmt.Errorf("%s failed on %d of %d instances", action, failed, len(instances))
+	}
+	return nil
+}
+
+// changeInstanceState applies a lifecycle action to a single instance and
+// returns a short description of the outcome
+func (s *Service) changeInstanceState(ctx context.Context, clientManager *aws.ClientManager, instance *storage.Instance, action string, timeout time.Duration) (string, error) {
+	if !aws.IsEC2Instance(instance.InstanceID) {
+		return "", fmt.Errorf("%s is not an EC2 instance", instance.InstanceID)
+	}
+
+	client, err := clientManager.GetClient(ctx, instance.Profile, instance.Region)
+	if err != nil {
+		return "", fmt.Errorf("failed to get AWS client: %w", err)
+	}
+	return applyLifecycleAction(ctx, aws.NewEC2InstanceManager(client), instance, action, timeout)
+}
+
+// instanceController is the part of aws.EC2InstanceManager that lifecycle
+// actions use
+type instanceController interface {
+	StartInstance(ctx context.Context, instanceID string) error
+	StopInstance(ctx context.Context, instanceID string) error
+	RebootInstance(ctx context.Context, instanceID string) error
+	SetTerminationProtection(ctx context.Context, instanceID string, enabled bool) error
+	WaitForState(ctx context.Context, instanceID, state string, timeout time.Duration) error
+	InstanceState(ctx context.Context, instanceID string) (string, error)
+}
+
+// agentWaiter is the part of aws.SSMSessionManager that waits for the SSM
+// agent to come online
+type agentWaiter interface {
+	WaitForOnline(ctx context.Context, instanceID string, timeout time.Duration) error
+}
+
+// applyLifecycleAction applies action to an EC2 instance through ec2Manager
+// and caches the state it leaves the instance in
+func applyLifecycleAction(ctx context.Context, ec2Manager instanceController, instance *storage.Instance, action string, timeout time.Duration) (string, error) {
+	switch action {
+	case LifecycleStart:
+		if err := ec2Manager.StartInstance(ctx, instance.InstanceID); err != nil {
+			return "", err
+		}
+		if err := ec2Manager.WaitForState(ctx, instance.InstanceID, aws.InstanceStateRunning, timeout); err != nil {
+			refreshCachedState(ctx, ec2Manager, instance)
+			return "", err
+		}
+		updateCachedState(instance, aws.InstanceStateRunning)
+		return aws.InstanceStateRunning, nil
+	case LifecycleStop:
+		if err := ec2Manager.StopInstance(ctx, instance.InstanceID); err != nil {
+			return "", err
+		}
+		if err := ec2Manager.WaitForState(ctx, instance.InstanceID, aws.InstanceStateStopped, timeout); err != nil {
+			refreshCachedState(ctx, ec2Manager, instance)
+			return "", err
+		}
+		updateCachedState(instance, aws.InstanceStateStopped)
+		return aws.InstanceStateStopped, nil
+	case LifecycleReboot:
+		if err := ec2Manager.RebootInstance(ctx, instance.InstanceID); err != nil {
+			return "", err
+		}
+		updateCachedState(instance, aws.InstanceStateRunning)
+		return "reboot requested", nil
+	case LifecycleProtect, LifecycleUnprotect:
+		enabled := action == LifecycleProtect
+		if err := ec2Manager.SetTerminationProtection(ctx, instance.InstanceID, enabled); err != nil {
+			return "", err
+		}
+		if enabled {
+			return "termination protection enabled", nil
+		}
+		return "termination protection disabled", nil
+	default:
+		return "", fmt.Errorf("unknown lifecycle action '%s'", action)
+	}
+}
+
+// updateCachedState records a new instance state in the database. The cache
+// is refreshed by the next sync anyway, so failures are only logged.
+func updateCachedState(instance *storage.Instance, state string) {
+	instance.State = state
+	if err := storage.NewInstanceRepository().UpdateState(instance.InstanceID, state); err != nil {
+		logrus.WithError(err).Warn("Failed to update cached instance state")
+	}
+}
+
+// refreshCachedState caches the current EC2 state of an instance after a
+// failed wait, so the cache does not keep a state it has since left
+func refreshCachedState(ctx context.Context, ec2Manager instanceController, instance *storage.Instance) {
+	state, err := ec2Manager.InstanceState(ctx, instance.InstanceID)
+	if err != nil {
+		logrus.WithError(err).Debug("Failed to refresh instance state after a failed wait")
+		return
+	}
+	if state != instance.State {
+		updateCachedState(instance, state)
+	}
+}
+
+// updateCachedPingStatus records a new SSM agent status in the database.
+// Hybrid instances have no EC2 state, so their state mirrors the status; an
+// EC2 instance with an Online agent is running.
+func updateCachedPingStatus(instance *storage.Instance, status string) {
+	if !aws.IsEC2Instance(instance.InstanceID) {
+		updateCachedState(instance, status)
+	} else if status == "Online" && instance.State != aws.InstanceStateRunning {
+		updateCachedState(instance, aws.InstanceStateRunning)
+	}
+	instance.PingStatus = status
+	if err := storage.NewInstanceRepository().UpdatePingStatus(instance.InstanceID, status); err != nil {
+		logrus.WithError(err).Warn("Failed to update cached SSM agent status")
+	}
+}
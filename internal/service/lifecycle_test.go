@@ -0,0 +1,365 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/andreclaro/ssm/internal/storage"
+)
+
+// setupTestDB points the repositories at an in-memory database
+func setupTestDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&storage.Instance{}, &storage.Tag{}))
+	storage.DB = db
+}
+
+// cachedInstance returns the persisted record of an instance
+func cachedInstance(t *testing.T, instanceID string) *storage.Instance {
+	instance, err := storage.NewInstanceRepository().FindByID(instanceID)
+	require.NoError(t, err)
+	require.NotNil(t, instance)
+	return instance
+}
+
+// TestUpdateCachedPingStatus tests that the SSM agent status never replaces
+// the EC2 state of an EC2 instance
+func TestUpdateCachedPingStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		instance   storage.Instance
+		status     string
+		wantState  string
+		wantStatus string
+	}{
+		{
+			name:       "online EC2 instance is running",
+			instance:   storage.Instance{InstanceID: "i-0123456789abcdef0", State: "pending"},
+			status:     "Online",
+			wantState:  "running",
+			wantStatus: "Online",
+		},
+		{
+			name:       "lost EC2 instance keeps its state",
+			instance:   storage.Instance{InstanceID: "i-0123456789abcdef1", State: "running", PingStatus: "Online"},
+			status:     "ConnectionLost",
+			wantState:  "running",
+			wantStatus: "ConnectionLost",
+		},
+		{
+			name:       "hybrid instance state mirrors the status",
+			instance:   storage.Instance{InstanceID: "mi-0123456789abcdef0", State: "ConnectionLost"},
+			status:     "Online",
+			wantState:  "Online",
+			wantStatus: "Online",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t)
+			instance := tt.instance
+			instance.Profile, instance.Region = "dev", "us-east-1"
+			require.NoError(t, storage.NewInstanceRepository().SaveOrUpdate(&instance))
+
+			updateCachedPingStatus(&instance, tt.status)
+
+			assert.Equal(t, tt.wantState, instance.State)
+			assert.Equal(t, tt.wantStatus, instance.PingStatus)
+			cached := cachedInstance(t, instance.InstanceID)
+			assert.Equal(t, tt.wantState, cached.State)
+			assert.Equal(t, tt.wantStatus, cached.PingStatus)
+		})
+	}
+}
+
+// fakeController records the EC2 calls of lifecycle actions. err fails
+// every action, waitErr only waits; states are reported in turn by
+// InstanceState.
+type fakeController struct {
+	calls   []string
+	err     error
+	waitErr error
+	states  []string
+}
+
+func (f *fakeController) record(call string) error {
+	f.calls = append(f.calls, call)
+	return f.err
+}
+
+func (f *fakeController) StartInstance(ctx context.Context, instanceID string) error {
+	return f.record("start")
+}
+
+func (f *fakeController) StopInstance(ctx context.Context, instanceID string) error {
+	return f.record("stop")
+}
+
+func (f *fakeController) RebootInstance(ctx context.Context, instanceID string) error {
+	return f.record("reboot")
+}
+
+func (f *fakeController) SetTerminationProtection(ctx context.Context, instanceID string, enabled bool) error {
+	return f.record(fmt.Sprintf("protect=%t", enabled))
+}
+
+func (f *fakeController) WaitForState(ctx context.Context, instanceID, state string, timeout time.Duration) error {
+	if err := f.record("wait " + state); err != nil {
+		return err
+	}
+	return f.waitErr
+}
+
+func (f *fakeController) InstanceState(ctx context.Context, instanceID string) (string, error) {
+	f.calls = append(f.calls, "state")
+	if len(f.states) == 0 {
+		return "", fmt.Errorf("no state reported")
+	}
+	state := f.states[0]
+	f.states = f.states[1:]
+	return state, nil
+}
+
+// fakeAgent waits for the SSM agent, failing with err
+type fakeAgent struct {
+	waited bool
+	err    error
+}
+
+func (f *fakeAgent) WaitForOnline(ctx context.Context, instanceID string, timeout time.Duration) error {
+	f.waited = true
+	return f.err
+}
+
+// TestApplyLifecycleAction tests that each action makes its EC2 calls and
+// caches the resulting state only when it succeeds
+func TestApplyLifecycleAction(t *testing.T) {
+	tests := []struct {
+		action     string
+		err        error
+		wantCalls  []string
+		wantResult string
+		wantState  string
+	}{
+		{action: LifecycleStart, wantCalls: []string{"start", "wait running"}, wantResult: "running", wantState: "running"},
+		{action: LifecycleStop, wantCalls: []string{"stop", "wait stopped"}, wantResult: "stopped", wantState: "stopped"},
+		{action: LifecycleReboot, wantCalls: []string{"reboot"}, wantResult: "reboot requested", wantState: "running"},
+		{action: LifecycleProtect, wantCalls: []string{"protect=true"}, wantResult: "termination protection enabled", wantState: "pending"},
+		{action: LifecycleUnprotect, wantCalls: []string{"protect=false"}, wantResult: "termination protection disabled", wantState: "pending"},
+		{action: LifecycleStart, err: fmt.Errorf("UnauthorizedOperation"), wantCalls: []string{"start"}, wantState: "pending"},
+		{action: LifecycleStop, err: fmt.Errorf("UnauthorizedOperation"), wantCalls: []string{"stop"}, wantState: "pending"},
+		{action: "hibernate", wantState: "pending"},
+	}
+
+	for _, tt := range tests {
+		name := tt.action
+		if tt.err != nil {
+			name += " fails"
+		}
+		t.Run(name, func(t *testing.T) {
+			setupTestDB(t)
+			instance := &storage.Instance{InstanceID: "i-0123456789abcdef0", Profile: "dev", Region: "us-east-1", State: "pending"}
+			require.NoError(t, storage.NewInstanceRepository().SaveOrUpdate(instance))
+
+			controller := &fakeController{err: tt.err}
+			result, err := applyLifecycleAction(context.Background(), controller, instance, tt.action, time.Minute)
+			if tt.wantResult == "" {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantResult, result)
+			assert.Equal(t, tt.wantCalls, controller.calls)
+			assert.Equal(t, tt.wantState, instance.State)
+			assert.Equal(t, tt.wantState, cachedInstance(t, instance.InstanceID).State)
+		})
+	}
+}
+
+// TestChangeInstanceState_HybridInstance tests that lifecycle actions refuse
+// instances that are not EC2 instances
+func TestChangeInstanceState_HybridInstance(t *testing.T) {
+	instance := &storage.Instance{InstanceID: "mi-0123456789abcdef0", State: "Online"}
+	_, err := (&Service{}).changeInstanceState(context.Background(), nil, instance, LifecycleStop, time.Minute)
+	assert.EqualError(t, err, "mi-0123456789abcdef0 is not an EC2 instance")
+}
+
+// TestApplyLifecycleAction_WaitFails tests that a failed wait caches the
+// state the instance was left in rather than keeping a stale one
+func TestApplyLifecycleAction_WaitFails(t *testing.T) {
+	setupTestDB(t)
+	instance := &storage.Instance{InstanceID: "i-0123456789abcdef0", Profile: "dev", Region: "us-east-1", State: "pending"}
+	require.NoError(t, storage.NewInstanceRepository().SaveOrUpdate(instance))
+
+	// The instance fell back to stopped, e.g. for lack of capacity
+	controller := &fakeController{waitErr: errors.New("instance did not reach state running"), states: []string{"stopped"}}
+	_, err := applyLifecycleAction(context.Background(), controller, instance, LifecycleStart, time.Minute)
+	require.Error(t, err)
+	assert.Equal(t, []string{"start", "wait running", "state"}, controller.calls)
+	assert.Equal(t, "stopped", cachedInstance(t, instance.InstanceID).State)
+}
+
+// TestWaitForInstance tests waiting for SSM and EC2 states and the cache
+// after a success or a timeout
+func TestWaitForInstance(t *testing.T) {
+	timeout := errors.New("instance did not come online in SSM within 1m0s")
+	tests := []struct {
+		name       string
+		instanceID string
+		state      string
+		controller *fakeController
+		agent      *fakeAgent
+		wantErr    bool
+		wantState  string
+		wantPing   string
+	}{
+		{name: "online", instanceID: "i-0123456789abcdef0", state: WaitStateOnline, controller: &fakeController{}, agent: &fakeAgent{},
+			wantState: "running", wantPing: "Online"},
+		{name: "online times out", instanceID: "i-0123456789abcdef0", state: WaitStateOnline, controller: &fakeController{}, agent: &fakeAgent{err: timeout},
+			wantErr: true, wantState: "pending", wantPing: "ConnectionLost"},
+		{name: "running", instanceID: "i-0123456789abcdef0", state: WaitStateRunning, controller: &fakeController{}, agent: &fakeAgent{},
+			wantState: "running", wantPing: "ConnectionLost"},
+		{name: "stopped times out", instanceID: "i-0123456789abcdef0", state: WaitStateStopped,
+			controller: &fakeController{waitErr: errors.New("exceeded max wait time"), states: []string{"stopping"}}, agent: &fakeAgent{},
+			wantErr: true, wantState: "stopping", wantPing: "ConnectionLost"},
+		{name: "hybrid instance has no EC2 state", instanceID: "mi-0123456789abcdef0", state: WaitStateRunning, controller: &fakeController{}, agent: &fakeAgent{},
+			wantErr: true, wantState: "pending", wantPing: "ConnectionLost"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t)
+			instance := &storage.Instance{InstanceID: tt.instanceID, Profile: "dev", Region: "us-east-1", State: "pending", PingStatus: "ConnectionLost"}
+			require.NoError(t, storage.NewInstanceRepository().SaveOrUpdate(instance))
+
+			err := waitForInstance(context.Background(), tt.controller, tt.agent, instance, tt.state, time.Minute)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			cached := cachedInstance(t, instance.InstanceID)
+			assert.Equal(t, tt.wantState, cached.State)
+			assert.Equal(t, tt.wantPing, cached.PingStatus)
+		})
+	}
+}
+
+// TestEnsureOnline tests starting instances before connecting, depending on
+// their EC2 state
+func TestEnsureOnline(t *testing.T) {
+	tests := []struct {
+		name       string
+		cached     string
+		controller *fakeController
+		agent      *fakeAgent
+		wantErr    bool
+		wantCalls  []string
+		wantWaited bool
+		wantState  string
+	}{
+		{
+			name:       "already running",
+			cached:     "running",
+			controller: &fakeController{states: []string{"running"}},
+			agent:      &fakeAgent{},
+			wantCalls:  []string{"state"},
+			wantWaited: true,
+			wantState:  "running",
+		},
+		{
+			name:       "stale cache of a running instance",
+			cached:     "stopped",
+			controller: &fakeController{states: []string{"running"}},
+			agent:      &fakeAgent{},
+			wantCalls:  []string{"state"},
+			wantWaited: true,
+			wantState:  "running",
+		},
+		{
+			name:       "stopped",
+			cached:     "stopped",
+			controller: &fakeController{states: []string{"stopped"}},
+			agent:      &fakeAgent{},
+			wantCalls:  []string{"state", "start", "wait running"},
+			wantWaited: true,
+			wantState:  "running",
+		},
+		{
+			name:       "stopping",
+			cached:     "running",
+			controller: &fakeController{states: []string{"stopping"}},
+			agent:      &fakeAgent{},
+			wantCalls:  []string{"state", "wait stopped", "start", "wait running"},
+			wantWaited: true,
+			wantState:  "running",
+		},
+		{
+			name:       "start times out",
+			cached:     "stopped",
+			controller: &fakeController{waitErr: errors.New("exceeded max wait time"), states: []string{"stopped", "pending"}},
+			agent:      &fakeAgent{},
+			wantErr:    true,
+			wantCalls:  []string{"state", "start", "wait running", "state"},
+			wantState:  "pending",
+		},
+		{
+			name:       "start fails back to stopped",
+			cached:     "pending",
+			controller: &fakeController{waitErr: errors.New("waiter state transitioned to Failure"), states: []string{"pending", "stopped"}},
+			agent:      &fakeAgent{},
+			wantErr:    true,
+			wantCalls:  []string{"state", "wait running", "state"},
+			wantState:  "stopped",
+		},
+		{
+			name:       "agent times out",
+			cached:     "stopped",
+			controller: &fakeController{states: []string{"stopped"}},
+			agent:      &fakeAgent{err: errors.New("instance did not come online in SSM within 10m0s")},
+			wantErr:    true,
+			wantCalls:  []string{"state", "start", "wait running"},
+			wantWaited: true,
+			wantState:  "running",
+		},
+		{
+			name:       "terminated",
+			cached:     "stopped",
+			controller: &fakeController{states: []string{"terminated"}},
+			agent:      &fakeAgent{},
+			wantErr:    true,
+			wantCalls:  []string{"state"},
+			wantState:  "terminated",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t)
+			instance := &storage.Instance{InstanceID: "i-0123456789abcdef0", Profile: "dev", Region: "us-east-1", State: tt.cached}
+			require.NoError(t, storage.NewInstanceRepository().SaveOrUpdate(instance))
+
+			var out bytes.Buffer
+			err := ensureOnline(context.Background(), tt.controller, tt.agent, instance, time.Minute, &out)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantCalls, tt.controller.calls)
+			assert.Equal(t, tt.wantWaited, tt.agent.waited)
+			assert.Equal(t, tt.wantState, cachedInstance(t, instance.InstanceID).State)
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/andreclaro/ssm/internal/config"
+	"github.com/andreclaro/ssm/internal/storage"
+)
+
+// ErrTunnelsNotUp is returned by TunnelsDown when no ssm up process is running
+var ErrTunnelsNotUp = errors.New("tunnels are not up")
+
+// TunnelsUp brings up the manifest's tunnels, or only the named ones, and
+// supervises them until interrupted or stopped with TunnelsDown
+func (s *Service) TunnelsUp(ctx context.Context, manifest *config.Manifest, names []string) error {
+	selected, err := manifest.Select(names)
+	if err != nil {
+		return err
+	}
+
+	tunnels, err := resolveManifestTunnels(selected)
+	if err != nil {
+		return err
+	}
+
+	pidFile := manifestPIDFile(manifest.Path)
+	if pid, ok := readPIDFile(pidFile); ok && ssmProcessAlive(pid) {
+		return fmt.Errorf("tunnels for %s are already up (pid %d); run 'ssm down' first", manifest.Path, pid)
+	}
+	if err := writePIDFile(pidFile); err != nil {
+		return err
+	}
+	defer os.Remove(pidFile)
+
+	logrus.WithFields(logrus.Fields{
+		"manifest": manifest.Path,
+		"tunnels":  len(tunnels),
+	}).Info("Bringing up manifest tunnels")
+
+	done, err := recordManifestTunnels(tunnels)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return NewTunnelSupervisor(os.Stdout).Run(ctx, tunnels)
+}
+
+// recordManifestTunnels records the tunnels in the tunnels table and the
+// connection history, once per instance, and returns a function that removes
+// the tunnel records and finishes the history entries
+func recordManifestTunnels(tunnels []Tunnel) (func(), error) {
+	var instanceIDs []string
+	mappings := make(map[string][]PortMapping)
+	instances := make(map[string]*storage.Instance)
+	for _, tunnel := range tunnels {
+		id := tunnel.Instance.InstanceID
+		if _, ok := instances[id]; !ok {
+			instanceIDs = append(instanceIDs, id)
+			instances[id] = tunnel.Instance
+		}
+		mappings[id] = append(mappings[id], tunnel.Mapping)
+	}
+
+	repo := storage.NewTunnelRepository()
+	var finishers []func()
+	done := func() {
+		for i := len(finishers) - 1; i >= 0; i-- {
+			finishers[i]()
+		}
+	}
+	for _, id := range instanceIDs {
+		instance := instances[id]
+		record := &storage.Tunnel{
+			PID:          os.Getpid(),
+			InstanceID:   instance.InstanceID,
+			InstanceName: instance.DisplayName(),
+			Profile:      instance.Profile,
+			Region:       instance.Region,
+			Mappings:     joinMappings(mappings[id]),
+			StartedAt:    time.Now(),
+		}
+		if err := repo.Create(record); err != nil {
+			done()
+			return nil, err
+		}
+		finishers = append(finishers,
+			func() { repo.Delete(record.ID) },
+			recordConnection(instance, storage.ConnectionModeForward, record.Mappings))
+	}
+	return done, nil
+}
+
+// TunnelsDown stops the ssm up process serving the manifest at path and
+// waits for it to close its tunnels
+func (s *Service) TunnelsDown(path string) error {
+	pidFile := manifestPIDFile(path)
+	pid, ok := readPIDFile(pidFile)
+	if !ok || !ssmProcessAlive(pid) {
+		os.Remove(pidFile)
+		return ErrTunnelsNotUp
+	}
+
+	if err := stopProcess(pid); err != nil {
+		return err
+	}
+	os.Remove(pidFile)
+	return nil
+}
+
+// resolveManifestTunnels resolves each manifest tunnel to an instance
+func resolveManifestTunnels(manifestTunnels []config.ManifestTunnel) ([]Tunnel, error) {
+	tunnels := make([]Tunnel, 0, len(manifestTunnels))
+	for _, mt := range manifestTunnels {
+		var (
+			instance *storage.Instance
+			err      error
+		)
+		if mt.Instance != "" {
+			instance, err = findInstance(mt.Instance)
+		} else {
+			instance, err = findInstanceBySelector(manifestSelector(mt.Tags))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("tunnel '%s': %w", mt.Name, err)
+		}
+
+		tunnels = append(tunnels, Tunnel{
+			Label:    mt.Name,
+			Instance: instance,
+			Mapping: PortMapping{
+				LocalPort:  mt.LocalPort,
+				RemoteHost: mt.RemoteHost,
+				RemotePort: mt.RemotePort,
+			},
+		})
+	}
+	return tunnels, nil
+}
+
+// manifestSelector converts a manifest tunnel's tags to the tag selector
+// -t would parse from the same key=value pairs
+func manifestSelector(tags map[string]string) storage.TagSelector {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	selector := make(storage.TagSelector, 0, len(keys))
+	for _, key := range keys {
+		selector = append(selector, storage.TagRequirement{Key: key, Operator: storage.TagEquals, Value: tags[key]})
+	}
+	return selector
+}
+
+// manifestPIDFile returns the PID file tracking the ssm up process for a
+// manifest, kept next to the database so it is shared across working dirs
+func manifestPIDFile(manifestPath string) string {
+	sum := sha256.Sum256([]byte(manifestPath))
+	name := "up-" + hex.EncodeToString(sum[:])[:16] + ".pid"
+	return filepath.Join(filepath.Dir(config.GetConfig().Database.Path), "run", name)
+}
+
+// readPIDFile reads a PID file
+func readPIDFile(path string) (int, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+	return pid, true
+}
+
+// writePIDFile records the current process in a PID file
+func writePIDFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create run directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write PID file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/andreclaro/ssm/internal/config"
+	"github.com/andreclaro/ssm/internal/storage"
+)
+
+// TestResolveManifestTunnels tests that tag tunnels resolve like -t selectors
+// and fail when several instances match
+func TestResolveManifestTunnels(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, storage.DB.AutoMigrate(&storage.Connection{}))
+
+	repo := storage.NewInstanceRepository()
+	for _, instance := range []storage.Instance{
+		{InstanceID: "i-0000000000000001", Name: "db", Profile: "prod", Region: "eu-west-1", State: "running", PingStatus: "Online",
+			Tags: []storage.Tag{{Key: "role", Value: "db"}, {Key: "env", Value: "prod"}}},
+		{InstanceID: "i-0000000000000002", Name: "web-a", Profile: "prod", Region: "eu-west-1", State: "running", PingStatus: "Online",
+			Tags: []storage.Tag{{Key: "role", Value: "web"}, {Key: "env", Value: "prod"}}},
+		{InstanceID: "i-0000000000000003", Name: "web-b", Profile: "prod", Region: "eu-west-1", State: "running", PingStatus: "Online",
+			Tags: []storage.Tag{{Key: "role", Value: "web"}, {Key: "env", Value: "prod"}}},
+	} {
+		require.NoError(t, repo.SaveOrUpdate(&instance))
+	}
+
+	tunnels, err := resolveManifestTunnels([]config.ManifestTunnel{
+		{Name: "postgres", Tags: map[string]string{"role": "db", "env": "prod"}, LocalPort: 5432, RemotePort: 5432},
+		{Name: "web", Instance: "web-a", LocalPort: 8080, RemotePort: 80},
+	})
+	require.NoError(t, err)
+	require.Len(t, tunnels, 2)
+	assert.Equal(t, "i-0000000000000001", tunnels[0].Instance.InstanceID)
+	assert.Equal(t, "i-0000000000000002", tunnels[1].Instance.InstanceID)
+
+	_, err = resolveManifestTunnels([]config.ManifestTunnel{
+		{Name: "web", Tags: map[string]string{"role": "web", "env": "prod"}, LocalPort: 8080, RemotePort: 80},
+	})
+	var ambiguous *storage.AmbiguousNameError
+	require.ErrorAs(t, err, &ambiguous)
+	assert.Len(t, ambiguous.Candidates, 2)
+	assert.Contains(t, err.Error(), "tunnel 'web': ")
+	assert.Contains(t, err.Error(), "prod/eu-west-1/web-a")
+	assert.Contains(t, err.Error(), "prod/eu-west-1/web-b")
+
+	_, err = resolveManifestTunnels([]config.ManifestTunnel{
+		{Name: "cache", Tags: map[string]string{"role": "cache"}, LocalPort: 6379, RemotePort: 6379},
+	})
+	assert.EqualError(t, err, "tunnel 'cache': no instance matches tags 'role=cache'")
+}
+
+// TestRecordManifestTunnels tests that manifest tunnels are recorded once per
+// instance and removed when they stop
+func TestRecordManifestTunnels(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, storage.DB.AutoMigrate(&storage.Tunnel{}, &storage.Connection{}))
+
+	db := &storage.Instance{InstanceID: "i-0000000000000001", Name: "db", Profile: "prod", Region: "eu-west-1"}
+	cache := &storage.Instance{InstanceID: "i-0000000000000002", Name: "cache", Profile: "prod", Region: "eu-west-1"}
+	done, err := recordManifestTunnels([]Tunnel{
+		{Label: "postgres", Instance: db, Mapping: PortMapping{LocalPort: 5432, RemotePort: 5432}},
+		{Label: "redis", Instance: cache, Mapping: PortMapping{LocalPort: 6379, RemotePort: 6379}},
+		{Label: "admin", Instance: db, Mapping: PortMapping{LocalPort: 8080, RemoteHost: "admin.internal", RemotePort: 80}},
+	})
+	require.NoError(t, err)
+
+	tunnels, err := storage.NewTunnelRepository().List()
+	require.NoError(t, err)
+	require.Len(t, tunnels, 2)
+	mappings := map[string]string{}
+	for _, tunnel := range tunnels {
+		mappings[tunnel.InstanceName] = tunnel.Mappings
+	}
+	assert.Equal(t, map[string]string{"db": "5432:5432,8080:admin.internal:80", "cache": "6379:6379"}, mappings)
+
+	connections, err := storage.NewConnectionRepository().List(10)
+	require.NoError(t, err)
+	require.Len(t, connections, 2)
+	for _, connection := range connections {
+		assert.Equal(t, storage.ConnectionModeForward, connection.Mode)
+		assert.Equal(t, mappings[connection.InstanceName], connection.Detail)
+	}
+
+	done()
+	tunnels, err = storage.NewTunnelRepository().List()
+	require.NoError(t, err)
+	assert.Empty(t, tunnels)
+}
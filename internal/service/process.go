@@ -0,0 +1,40 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// processStopTimeout bounds how long to wait for a stopped process to exit
+const processStopTimeout = 10 * time.Second
+
+// ssmProcessAlive reports whether pid is a running process of this
+// executable. PIDs are recycled, so a recorded PID alone does not identify a
+// tunnel process.
+func ssmProcessAlive(pid int) bool {
+	if !processAlive(pid) {
+		return false
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return false
+	}
+	return runsExecutable(pid, self)
+}
+
+// stopProcess terminates a process and waits for it to exit
+func stopProcess(pid int) error {
+	if err := terminateProcess(pid); err != nil {
+		return fmt.Errorf("failed to stop process %d: %w", pid, err)
+	}
+
+	deadline := time.Now().Add(processStopTimeout)
+	for processAlive(pid) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("process %d did not exit", pid)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+//go:build !windows
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// processAlive reports whether a process with the given PID is running
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
+// runsExecutable reports whether a process runs the executable at path. Linux
+// exposes the executable under /proc; elsewhere its name is asked from ps.
+func runsExecutable(pid int, path string) bool {
+	if _, err := os.Stat("/proc/self/exe"); err == nil {
+		exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+		if err != nil {
+			return false
+		}
+		// An executable replaced since the process started is marked deleted
+		return strings.TrimSuffix(exe, " (deleted)") == path
+	}
+
+	output, err := exec.Command("ps", "-p", strconv.Itoa(pid), "-o", "comm=").Output()
+	if err != nil {
+		return false
+	}
+	return filepath.Base(strings.TrimSpace(string(output))) == filepath.Base(path)
+}
+
+// listensOn reports whether a process has a TCP socket listening on port.
+// Linux lists the process's sockets under /proc; elsewhere lsof is asked.
+func listensOn(pid, port int) (bool, error) {
+	if _, err := os.Stat("/proc/self/net/tcp"); err == nil {
+		return procListensOn(pid, port)
+	}
+
+	output, err := exec.Command("lsof", "-nP", "-a", "-p", strconv.Itoa(pid), "-iTCP:"+strconv.Itoa(port), "-sTCP:LISTEN", "-t").Output()
+	if err != nil {
+		// lsof exits 1 when no socket matches
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to run lsof: %w", err)
+	}
+	return strings.TrimSpace(string(output)) != "", nil
+}
+
+// procListensOn finds the listening sockets on port in the process's network
+// namespace and reports whether one of them is open in the process
+func procListensOn(pid, port int) (bool, error) {
+	inodes := make(map[string]bool)
+	for _, table := range []string{"tcp", "tcp6"} {
+		data, err := os.ReadFile(fmt.Sprintf("/proc/%d/net/%s", pid, table))
+		if errors.Is(err, os.ErrNotExist) && table == "tcp6" {
+			// IPv6 is disabled
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		for _, inode := range listeningSockets(string(data), port) {
+			inodes[inode] = true
+		}
+	}
+	if len(inodes) == 0 {
+		return false, nil
+	}
+
+	fdDir := fmt.Sprintf("/proc/%d/fd", pid)
+	fds, err := os.ReadDir(fdDir)
+	if err != nil {
+		return false, err
+	}
+	for _, fd := range fds {
+		link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+		if err != nil {
+			continue
+		}
+		if inode, ok := strings.CutPrefix(link, "socket:["); ok && inodes[strings.TrimSuffix(inode, "]")] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// listeningSockets returns the inodes of the sockets listening on port in a
+// /proc/net/tcp or tcp6 table
+func listeningSockets(table string, port int) []string {
+	// Ports are hex encoded after the address, and state 0A is LISTEN
+	suffix := fmt.Sprintf(":%04X", port)
+	var inodes []string
+	for _, line := range strings.Split(table, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 10 || fields[3] != "0A" || !strings.HasSuffix(fields[1], suffix) {
+			continue
+		}
+		inodes = append(inodes, fields[9])
+	}
+	return inodes
+}
+
+// terminateProcess asks a process to shut down cleanly
+func terminateProcess(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Signal(syscall.SIGTERM)
+}
+
+// detachedProcAttr starts a process in its own session so it outlives the
+// terminal that started it
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
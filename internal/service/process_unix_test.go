@@ -0,0 +1,22 @@
+//go:build !windows
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestListeningSockets tests parsing listening sockets out of /proc/net/tcp
+func TestListeningSockets(t *testing.T) {
+	table := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0100007F:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 41234 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:1F90 0100007F:C350 01 00000000:00000000 00:00000000 00000000     0        0 41240 1 0000000000000000 20 4 30 10 -1
+   2: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 18231 1 0000000000000000 100 0 0 10 0
+`
+
+	assert.Equal(t, []string{"41234"}, listeningSockets(table, 8080))
+	assert.Equal(t, []string{"18231"}, listeningSockets(table, 22))
+	assert.Empty(t, listeningSockets(table, 443))
+}
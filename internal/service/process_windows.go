@@ -0,0 +1,74 @@
+//go:build windows
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// detachedProcess is the DETACHED_PROCESS process creation flag
+const detachedProcess = 0x00000008
+
+// processAlive reports whether a process with the given PID is running
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
+
+// runsExecutable reports whether a process runs an executable with the name
+// of the one at path, as listed by tasklist
+func runsExecutable(pid int, path string) bool {
+	output, err := exec.Command("tasklist", "/FI", fmt.Sprintf("PID eq %d", pid), "/FO", "CSV", "/NH").Output()
+	if err != nil {
+		return false
+	}
+	// The first CSV field is the quoted image name
+	image, _, _ := strings.Cut(strings.TrimSpace(string(output)), ",")
+	return strings.EqualFold(strings.Trim(image, `"`), filepath.Base(path))
+}
+
+// listensOn reports whether a process has a TCP socket listening on port, as
+// listed by netstat
+func listensOn(pid, port int) (bool, error) {
+	output, err := exec.Command("netstat", "-ano", "-p", "TCP").Output()
+	if err != nil {
+		return false, fmt.Errorf("failed to run netstat: %w", err)
+	}
+	// Proto, local address, foreign address, state and PID
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 5 && fields[3] == "LISTENING" && fields[4] == strconv.Itoa(pid) &&
+			strings.HasSuffix(fields[1], ":"+strconv.Itoa(port)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// terminateProcess stops a process. Windows has no SIGTERM, so it is killed.
+func terminateProcess(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Kill()
+}
+
+// detachedProcAttr starts a process without a console so it outlives the
+// terminal that started it
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		CreationFlags: detachedProcess | syscall.CREATE_NEW_PROCESS_GROUP,
+		HideWindow:    true,
+	}
+}
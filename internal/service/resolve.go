@@ -0,0 +1,122 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/andreclaro/ssm/internal/storage"
+)
+
+// PickerFunc chooses one of several instances matching an ambiguous name
+type PickerFunc func(name string, candidates []storage.Instance) (*storage.Instance, error)
+
+// InstancePicker resolves ambiguous instance names interactively. It is nil
+// unless the CLI runs in a terminal, in which case ambiguous names fail with
+// the list of candidates.
+var InstancePicker PickerFunc
+
+// findInstance resolves an instance name, asking InstancePicker to choose
+// when the name is ambiguous
+func findInstance(name string) (*storage.Instance, error) {
+	instance, err := storage.NewInstanceRepository().FindByName(name)
+
+	var ambiguous *storage.AmbiguousNameError
+	if errors.As(err, &ambiguous) && InstancePicker != nil {
+		return InstancePicker(ambiguous.Name, ambiguous.Candidates)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find instance: %w", err)
+	}
+	if instance == nil {
+		return nil, fmt.Errorf("instance '%s' not found", name)
+	}
+	return instance, nil
+}
+
+// ResolveTarget resolves the instance a command targets: by tag selector
+// when one is given, otherwise by name
+func (s *Service) ResolveTarget(name, selector string) (*storage.Instance, error) {
+	if selector == "" {
+		return findInstance(name)
+	}
+
+	parsed, err := storage.ParseTagSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return findInstanceBySelector(parsed)
+}
+
+// findInstanceBySelector finds the single instance a tag selector targets,
+// letting the user pick when several match, as findInstance does for names
+func findInstanceBySelector(selector storage.TagSelector) (*storage.Instance, error) {
+	instance, err := storage.NewInstanceRepository().FindOneBySelector(selector)
+
+	var ambiguous *storage.AmbiguousNameError
+	if errors.As(err, &ambiguous) && InstancePicker != nil {
+		return InstancePicker(ambiguous.Name, ambiguous.Candidates)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find instance: %w", err)
+	}
+	if instance == nil {
+		return nil, fmt.Errorf("no instance matches tags '%s'", selector)
+	}
+	return instance, nil
+}
+
+// ResolveTargets resolves the instances a multi-instance command targets:
+// every named instance plus every instance matching selector, whatever its
+// state
+func (s *Service) ResolveTargets(names []string, selector string) ([]*storage.Instance, error) {
+	return resolveTargets(names, selector, false)
+}
+
+// resolveTargets resolves every named instance plus every instance matching
+// selector, skipping duplicates. With reachableOnly, selector matches that
+// are not reachable via SSM are left out.
+func resolveTargets(names []string, selector string, reachableOnly bool) ([]*storage.Instance, error) {
+	var instances []*storage.Instance
+	seen := make(map[string]bool)
+	add := func(instance *storage.Instance) {
+		if !seen[instance.InstanceID] {
+			seen[instance.InstanceID] = true
+			instances = append(instances, instance)
+		}
+	}
+
+	for _, name := range names {
+		instance, err := findInstance(name)
+		if err != nil {
+			return nil, err
+		}
+		add(instance)
+	}
+
+	if selector != "" {
+		parsed, err := storage.ParseTagSelector(selector)
+		if err != nil {
+			return nil, err
+		}
+		matches, err := storage.NewInstanceRepository().FindBySelector(parsed)
+		if err != nil {
+			return nil, err
+		}
+
+		added := 0
+		for i := range matches {
+			if !reachableOnly || matches[i].Reachable() {
+				add(&matches[i])
+				added++
+			}
+		}
+		if added == 0 && reachableOnly {
+			return nil, fmt.Errorf("no reachable instance matches tags '%s'", selector)
+		}
+		if added == 0 {
+			return nil, fmt.Errorf("no instance matches tags '%s'", selector)
+		}
+	}
+
+	return instances, nil
+}
@@ -3,6 +3,10 @@ package service
 import (
 	"context"
 	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 
@@ -42,6 +46,17 @@ func (s *Service) SyncInstances(ctx context.Context, profile, region *string) er
 		if err != nil {
 			return fmt.Errorf("failed to get enabled profiles: %w", err)
 		}
+
+		// Add the accounts of the configured organization
+		orgProfiles, err := s.discovery.OrganizationProfiles(ctx)
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to discover organization accounts")
+		}
+		for _, orgProfile := range orgProfiles {
+			if !slices.Contains(profiles, orgProfile) {
+				profiles = append(profiles, orgProfile)
+			}
+		}
 	}
 
 	// Get available regions
@@ -61,12 +76,19 @@ func (s *Service) SyncInstances(ctx context.Context, profile, region *string) er
 }
 
 // ListInstances lists instances with optional filters
-func (s *Service) ListInstances(profile, region *string) ([]storage.Instance, error) {
+func (s *Service) ListInstances(profile, region *string, selector string) ([]storage.Instance, error) {
 	repo := storage.NewInstanceRepository()
 	filter := &storage.InstanceFilter{
 		Profile: profile,
 		Region:  region,
 	}
+	if selector != "" {
+		tags, err := storage.ParseTagSelector(selector)
+		if err != nil {
+			return nil, err
+		}
+		filter.Tags = tags
+	}
 
 	instances, err := repo.List(filter)
 	if err != nil {
@@ -78,18 +100,39 @@ func (s *Service) ListInstances(profile, region *string) ([]storage.Instance, er
 
 // ConnectToInstance connects to an instance via SSM Session Manager
 func (s *Service) ConnectToInstance(ctx context.Context, instanceName string) error {
-	repo := storage.NewInstanceRepository()
-
 	// Find instance by name
-	instance, err := repo.FindByName(instanceName)
+	instance, err := findInstance(instanceName)
 	if err != nil {
-		return fmt.Errorf("failed to find instance: %w", err)
+		return err
 	}
 
+	return s.Connect(ctx, instance, aws.SessionOptions{})
+}
+
+// ConnectToLast starts a shell session to the most recently used instance
+func (s *Service) ConnectToLast(ctx context.Context) error {
+	last, err := storage.NewConnectionRepository().Last()
+	if err != nil {
+		return err
+	}
+	if last == nil {
+		return fmt.Errorf("no previous connections")
+	}
+
+	instance, err := storage.NewInstanceRepository().FindByIDInScope(last.InstanceID, last.Profile, last.Region)
+	if err != nil {
+		return err
+	}
 	if instance == nil {
-		return fmt.Errorf("instance '%s' not found", instanceName)
+		return fmt.Errorf("last connected instance %s (%s) is no longer discovered in %s/%s; run 'ssm sync' or connect by name",
+			last.InstanceName, last.InstanceID, last.Profile, last.Region)
 	}
+	return s.Connect(ctx, instance, aws.SessionOptions{})
+}
 
+// Connect starts an interactive session to an instance record and records it
+// in the history. The zero SessionOptions open the default shell.
+func (s *Service) Connect(ctx context.Context, instance *storage.Instance, opts aws.SessionOptions) error {
 	logrus.WithFields(logrus.Fields{
 		"instance_id": instance.InstanceID,
 		"name":        instance.Name,
@@ -104,98 +147,171 @@ func (s *Service) ConnectToInstance(ctx context.Context, instanceName string) er
 		return fmt.Errorf("failed to get AWS client: %w", err)
 	}
 
+	detail := opts.Document
+	if command, ok := opts.Parameters["command"]; ok && opts.Document == aws.InteractiveCommandDocument {
+		detail = strings.Join(command, " ")
+	}
+	defer recordConnection(instance, storage.ConnectionModeShell, detail)()
+
 	// Start SSM session
 	ssmManager := aws.NewSSMSessionManager(client)
-	if err := ssmManager.StartSession(ctx, instance.InstanceID); err != nil {
+	if err := ssmManager.StartSession(ctx, instance.InstanceID, opts); err != nil {
 		return fmt.Errorf("failed to start SSM session: %w", err)
 	}
 
 	return nil
 }
 
-// PortForwardToInstance starts an SSM port forwarding session to the given instance name
-func (s *Service) PortForwardToInstance(ctx context.Context, instanceName string, localPort, remotePort int) error {
-	repo := storage.NewInstanceRepository()
+// ConnectionHistory returns the most recent connections, newest first
+func (s *Service) ConnectionHistory(limit int) ([]storage.Connection, error) {
+	return storage.NewConnectionRepository().List(limit)
+}
 
-	// Find instance by name
-	instance, err := repo.FindByName(instanceName)
-	if err != nil {
-		return fmt.Errorf("failed to find instance: %w", err)
+// recordConnection adds a connection to the history and returns a function
+// that records its duration once it ends. History is best effort, so
+// failures are only logged.
+func recordConnection(instance *storage.Instance, mode, detail string) func() {
+	repo := storage.NewConnectionRepository()
+	connection := &storage.Connection{
+		InstanceID:   instance.InstanceID,
+		InstanceName: instance.DisplayName(),
+		Profile:      instance.Profile,
+		Region:       instance.Region,
+		Mode:         mode,
+		Detail:       detail,
 	}
-	if instance == nil {
-		return fmt.Errorf("instance '%s' not found", instanceName)
+	if err := repo.Record(connection); err != nil {
+		logrus.WithError(err).Warn("Failed to record connection history")
+		return func() {}
 	}
 
-	logrus.WithFields(logrus.Fields{
-		"instance_id": instance.InstanceID,
-		"name":        instance.Name,
-		"profile":     instance.Profile,
-		"region":      instance.Region,
-		"local_port":  localPort,
-		"remote_port": remotePort,
-	}).Info("Starting port forwarding to instance")
+	return func() {
+		if err := repo.Finish(connection); err != nil {
+			logrus.WithError(err).Warn("Failed to record connection history")
+		}
+	}
+}
 
-	// Get AWS client
+// PortForwardToInstance starts an SSM port forwarding session to the given instance name
+func (s *Service) PortForwardToInstance(ctx context.Context, instanceName string, localPort, remotePort int) error {
+	return s.PortForwardToInstanceMultiple(ctx, instanceName, []PortMapping{{LocalPort: localPort, RemotePort: remotePort}})
+}
+
+// ProxyToInstance relays an SSH connection to port on instance over
+// stdin/stdout. When key is set, its public key is pushed with EC2 Instance
+// Connect first.
+func (s *Service) ProxyToInstance(ctx context.Context, instance *storage.Instance, port int, key *SSHKey, stdin io.Reader, stdout io.Writer) error {
 	clientManager := aws.NewClientManager()
 	client, err := clientManager.GetClient(ctx, instance.Profile, instance.Region)
 	if err != nil {
 		return fmt.Errorf("failed to get AWS client: %w", err)
 	}
 
-	// Start SSM port forwarding session
+	if key != nil {
+		if err := pushSSHKey(ctx, client, instance, key); err != nil {
+			return err
+		}
+	}
+
+	defer recordConnection(instance, storage.ConnectionModeProxy, strconv.Itoa(port))()
+
 	ssmManager := aws.NewSSMSessionManager(client)
-	if err := ssmManager.StartPortForwarding(ctx, instance.InstanceID, localPort, remotePort); err != nil {
-		return fmt.Errorf("failed to start SSM port forwarding: %w", err)
+	if err := ssmManager.StartSSHSession(ctx, instance.InstanceID, port, stdin, stdout); err != nil {
+		return fmt.Errorf("failed to start SSH session: %w", err)
 	}
 	return nil
 }
 
-// PortMapping represents a local to remote port mapping
+// PortMapping represents a local to remote port mapping. RemoteHost is empty
+// for ports on the instance itself; otherwise the instance relays to that
+// host (e.g. an RDS endpoint).
 type PortMapping struct {
 	LocalPort  int
+	RemoteHost string
 	RemotePort int
 }
 
-// PortForwardToInstanceMultiple starts multiple concurrent SSM port forwarding sessions
-func (s *Service) PortForwardToInstanceMultiple(ctx context.Context, instanceName string, mappings []PortMapping) error {
-	if len(mappings) == 0 {
-		return fmt.Errorf("no port mappings provided")
+// String formats the mapping in the same LOCAL:[HOST:]REMOTE form it is parsed from
+func (m PortMapping) String() string {
+	if m.RemoteHost == "" {
+		return fmt.Sprintf("%d:%d", m.LocalPort, m.RemotePort)
 	}
+	host := m.RemoteHost
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	return fmt.Sprintf("%d:%s:%d", m.LocalPort, host, m.RemotePort)
+}
 
-	repo := storage.NewInstanceRepository()
-	instance, err := repo.FindByName(instanceName)
-	if err != nil {
-		return fmt.Errorf("failed to find instance: %w", err)
+// ParsePortMapping parses an ssh-style LOCAL:REMOTE or LOCAL:HOST:REMOTE
+// forward. IPv6 hosts are written in brackets, e.g. 8080:[fd00::1]:80.
+func ParsePortMapping(spec string) (PortMapping, error) {
+	var mapping PortMapping
+
+	localPart, rest, ok := strings.Cut(spec, ":")
+	if !ok {
+		return mapping, fmt.Errorf("invalid port mapping '%s'. Use LOCAL:REMOTE or LOCAL:HOST:REMOTE (e.g., 8888:80)", spec)
 	}
-	if instance == nil {
-		return fmt.Errorf("instance '%s' not found", instanceName)
+
+	remotePart := rest
+	if strings.HasPrefix(rest, "[") {
+		end := strings.Index(rest, "]:")
+		if end < 0 {
+			return mapping, fmt.Errorf("invalid host in port mapping '%s'", spec)
+		}
+		mapping.RemoteHost = rest[1:end]
+		remotePart = rest[end+2:]
+	} else if host, port, ok := strings.Cut(rest, ":"); ok {
+		mapping.RemoteHost = host
+		remotePart = port
+	}
+	if strings.ContainsAny(mapping.RemoteHost, " \t") || (mapping.RemoteHost == "" && remotePart != rest) {
+		return mapping, fmt.Errorf("invalid host in port mapping '%s'", spec)
 	}
 
-	clientManager := aws.NewClientManager()
-	client, err := clientManager.GetClient(ctx, instance.Profile, instance.Region)
-	if err != nil {
-		return fmt.Errorf("failed to get AWS client: %w", err)
+	var err error
+	if mapping.LocalPort, err = parsePort(localPart); err != nil {
+		return mapping, fmt.Errorf("invalid local port in mapping '%s'", spec)
 	}
+	if mapping.RemotePort, err = parsePort(remotePart); err != nil {
+		return mapping, fmt.Errorf("invalid remote port in mapping '%s'", spec)
+	}
+	return mapping, nil
+}
 
-	ssmManager := aws.NewSSMSessionManager(client)
+// parsePort parses a TCP port number
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil || port <= 0 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return port, nil
+}
 
-	// Start each mapping in its own goroutine and wait; if any fails, return the error
-	errCh := make(chan error, len(mappings))
-	for _, m := range mappings {
-		m := m
-		go func() {
-			errCh <- ssmManager.StartPortForwarding(ctx, instance.InstanceID, m.LocalPort, m.RemotePort)
-		}()
+// ParseSessionParameters parses key=value session document parameters.
+// Repeating a key passes several values for it.
+func ParseSessionParameters(specs []string) (map[string][]string, error) {
+	if len(specs) == 0 {
+		return nil, nil
 	}
 
-	// If any of them errors immediately, return it; otherwise block forever until user exits sessions
-	// Collect first error if any
-	for i := 0; i < len(mappings); i++ {
-		if err := <-errCh; err != nil {
-			return err
+	params := make(map[string][]string)
+	for _, spec := range specs {
+		key, value, ok := strings.Cut(spec, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid session parameter '%s'. Use KEY=VALUE", spec)
 		}
+		key = strings.TrimSpace(key)
+		params[key] = append(params[key], value)
 	}
-	return nil
+	return params, nil
+}
+
+// PortForwardToInstanceMultiple forwards every mapping through the named
+// instance under a TunnelSupervisor, reconnecting dropped tunnels until the
+// process receives SIGINT or SIGTERM
+func (s *Service) PortForwardToInstanceMultiple(ctx context.Context, instanceName string, mappings []PortMapping) error {
+	return s.RunTunnel(ctx, instanceName, mappings, "")
 }
 
 // GetStats returns service statistics
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/andreclaro/ssm/internal/config"
+	"github.com/andreclaro/ssm/internal/storage"
+)
+
+// TestParsePortMapping tests parsing of ssh-style port forward specs
+func TestParsePortMapping(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected PortMapping
+	}{
+		{"8888:80", PortMapping{LocalPort: 8888, RemotePort: 80}},
+		{"5432:db.internal:5432", PortMapping{LocalPort: 5432, RemoteHost: "db.internal", RemotePort: 5432}},
+		{"6379:10.0.1.5:6379", PortMapping{LocalPort: 6379, RemoteHost: "10.0.1.5", RemotePort: 6379}},
+		{"8080:[fd00::1]:80", PortMapping{LocalPort: 8080, RemoteHost: "fd00::1", RemotePort: 80}},
+	}
+
+	for _, tt := range tests {
+		mapping, err := ParsePortMapping(tt.spec)
+		require.NoError(t, err, tt.spec)
+		assert.Equal(t, tt.expected, mapping)
+		assert.Equal(t, tt.spec, mapping.String())
+	}
+
+	for _, spec := range []string{"8888", "0:80", "8888:70000", "abc:80", "8888::80", "8888:db:port", "8888:[fd00::1:80"} {
+		_, err := ParsePortMapping(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+// TestParseSessionParameters tests parsing of key=value document parameters
+func TestParseSessionParameters(t *testing.T) {
+	params, err := ParseSessionParameters([]string{"runAsUser=deploy", "command=a=b", "tags=x", "tags=y"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"runAsUser": {"deploy"},
+		"command":   {"a=b"},
+		"tags":      {"x", "y"},
+	}, params)
+
+	params, err = ParseSessionParameters(nil)
+	require.NoError(t, err)
+	assert.Nil(t, params)
+
+	for _, spec := range []string{"novalue", "=value"} {
+		_, err := ParseSessionParameters([]string{spec})
+		assert.Error(t, err, spec)
+	}
+}
+
+// TestSSHUser tests login user selection by tag, platform and default
+func TestSSHUser(t *testing.T) {
+	require.NoError(t, config.InitConfig(""))
+
+	// EC2 reports PlatformDetails; the SSM agent reports the distribution
+	tests := []struct {
+		platform, platformName, want string
+	}{
+		{"Linux/UNIX", "Ubuntu", "ubuntu"},
+		{"Linux/UNIX", "Debian GNU/Linux", "admin"},
+		{"Linux/UNIX", "Amazon Linux", "ec2-user"},
+		{"Linux/UNIX", "CentOS Stream", "centos"},
+		{"Red Hat Enterprise Linux", "Red Hat Enterprise Linux", "ec2-user"},
+		{"Ubuntu Pro", "", "ubuntu"},
+		{"SUSE Linux", "", "ec2-user"},
+		{"", "Ubuntu", "ubuntu"},
+		{"Linux/UNIX", "", "ec2-user"},
+	}
+	for _, tt := range tests {
+		user, err := SSHUser(&storage.Instance{Platform: tt.platform, PlatformName: tt.platformName})
+		require.NoError(t, err, tt.platformName)
+		assert.Equal(t, tt.want, user, "%s / %s", tt.platform, tt.platformName)
+	}
+
+	user, err := SSHUser(&storage.Instance{Platform: "Linux/UNIX", PlatformName: "Ubuntu", Tags: []storage.Tag{{Key: "ssh-user", Value: "deploy"}}})
+	require.NoError(t, err)
+	assert.Equal(t, "deploy", user)
+
+	_, err = SSHUser(&storage.Instance{Platform: "Windows", PlatformName: "Microsoft Windows Server 2022 Datacenter"})
+	assert.Error(t, err)
+	_, err = SSHUser(&storage.Instance{PlatformName: "Microsoft Windows Server 2019 Datacenter"})
+	assert.Error(t, err)
+}
+
+// TestPrefixWriter tests that tunnel output is prefixed line by line
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	var mu sync.Mutex
+	w := &prefixWriter{prefix: []byte("[8888:80] "), w: &out, mu: &mu}
+
+	w.Write([]byte("Starting session\nWaiting"))
+	w.Write([]byte(" for connections...\npartial"))
+	assert.Equal(t, "[8888:80] Starting session\n[8888:80] Waiting for connections...\n", out.String())
+
+	w.Flush()
+	assert.Equal(t, "[8888:80] Starting session\n[8888:80] Waiting for connections...\n[8888:80] partial\n", out.String())
+}
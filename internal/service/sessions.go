@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/semaphore"
+
+	"github.com/andreclaro/ssm/internal/aws"
+	"github.com/andreclaro/ssm/internal/storage"
+)
+
+// ListSessions lists Session Manager sessions across the enabled profiles and
+// regions, or only the given ones. Active sessions are listed when since is
+// zero; otherwise sessions that started after since and have ended. Sessions
+// seen through several profiles of the same account are listed once, newest
+// first.
+func (s *Service) ListSessions(ctx context.Context, profile, region *string, since time.Time) ([]aws.SessionInfo, error) {
+	profiles, regions, err := sessionScope(profile, region)
+	if err != nil {
+		return nil, err
+	}
+
+	sem := semaphore.NewWeighted(maxConcurrentSessions())
+	clientManager := aws.NewClientManager()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		failures int
+	)
+	// Each scope fills its own slot so duplicates resolve in scope order
+	found := make([][]aws.SessionInfo, len(profiles)*len(regions))
+	for i, p := range profiles {
+		for j, r := range regions {
+			wg.Add(1)
+			go func(slot int, profile, region string) {
+				defer wg.Done()
+				if err := sem.Acquire(ctx, 1); err != nil {
+					return
+				}
+				defer sem.Release(1)
+
+				sessions, err := listSessionsIn(ctx, clientManager, profile, region, since)
+				if err != nil {
+					logrus.WithFields(logrus.Fields{
+						"profile": profile,
+						"region":  region,
+					}).WithError(err).Warn("Failed to list sessions")
+					mu.Lock()
+					failures++
+					mu.Unlock()
+					return
+				}
+				found[slot] = sessions
+			}(i*len(regions)+j, p, r)
+		}
+	}
+	wg.Wait()
+
+	if failures > 0 && failures == len(profiles)*len(regions) {
+		return nil, fmt.Errorf("failed to list sessions in every profile and region")
+	}
+	return mergeSessions(found), nil
+}
+
+// mergeSessions lists each session once, as first found, newest first
+func mergeSessions(found [][]aws.SessionInfo) []aws.SessionInfo {
+	var sessions []aws.SessionInfo
+	seen := make(map[string]bool)
+	for _, batch := range found {
+		for _, session := range batch {
+			if !seen[session.SessionID] {
+				seen[session.SessionID] = true
+				sessions = append(sessions, session)
+			}
+		}
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].StartDate.After(sessions[j].StartDate)
+	})
+	return sessions
+}
+
+// KillSession terminates an active session, looking up the profile and region
+// it belongs to among the enabled ones
+func (s *Service) KillSession(ctx context.Context, sessionID string, profile, region *string) (*aws.SessionInfo, error) {
+	sessions, err := s.ListSessions(ctx, profile, region, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, session := range sessions {
+		if session.SessionID != sessionID {
+			continue
+		}
+
+		client, err := aws.NewClientManager().GetClient(ctx, session.Profile, session.Region)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get AWS client: %w", err)
+		}
+		if err := aws.NewSSMSessionManager(client).TerminateSession(ctx, sessionID); err != nil {
+			return nil, err
+		}
+		return &session, nil
+	}
+	return nil, fmt.Errorf("no active session '%s' in the enabled profiles and regions", sessionID)
+}
+
+// listSessionsIn lists the sessions of a single profile and region
+func listSessionsIn(ctx context.Context, clientManager *aws.ClientManager, profile, region string, since time.Time) ([]aws.SessionInfo, error) {
+	client, err := clientManager.GetClient(ctx, profile, region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get AWS client: %w", err)
+	}
+	return aws.NewSSMSessionManager(client).ListSessions(ctx, since)
+}
+
+// sessionScope returns the profiles and regions to query: the given ones, or
+// the enabled ones from the database
+func sessionScope(profile, region *string) ([]string, []string, error) {
+	var profiles, regions []string
+	if profile != nil {
+		profiles = []string{*profile}
+	} else {
+		enabled, err := storage.NewProfileRepository().GetEnabledProfiles()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get enabled profiles: %w", err)
+		}
+		profiles = enabled
+
+		// Include the organization accounts found by the last sync
+		discovered, err := storage.NewInstanceRepository().Profiles()
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, p := range discovered {
+			if _, _, ok := aws.ParseAccountProfile(p); ok && !slices.Contains(profiles, p) {
+				profiles = append(profiles, p)
+			}
+		}
+	}
+
+	if region != nil {
+		regions = []string{*region}
+	} else {
+		enabled, err := storage.NewRegionRepository().GetEnabledRegions()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get enabled regions: %w", err)
+		}
+		regions = enabled
+	}
+
+	if len(profiles) == 0 || len(regions) == 0 {
+		return nil, nil, fmt.Errorf("no profiles or regions enabled; run 'ssm setup'")
+	}
+	return profiles, regions, nil
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/andreclaro/ssm/internal/aws"
+)
+
+// TestMergeSessions tests that sessions seen through several profiles are
+// listed once, from the first profile, newest first
+func TestMergeSessions(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	session := func(id, profile string, age time.Duration) aws.SessionInfo {
+		return aws.SessionInfo{SessionID: id, Profile: profile, Region: "us-east-1", StartDate: now.Add(-age)}
+	}
+
+	sessions := mergeSessions([][]aws.SessionInfo{
+		{session("alice-1", "prod", time.Hour), session("alice-2", "prod", time.Minute)},
+		nil, // a scope that failed or had no sessions
+		{session("alice-2", "prod-admin", time.Minute), session("bob-1", "prod-admin", 2*time.Hour), session("bob-2", "prod-admin", time.Hour)},
+	})
+
+	var got []string
+	for _, s := range sessions {
+		got = append(got, s.SessionID+"@"+s.Profile)
+	}
+	assert.Equal(t, []string{"alice-2@prod", "alice-1@prod", "bob-2@prod-admin", "bob-1@prod-admin"}, got)
+
+	assert.Empty(t, mergeSessions(nil))
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/andreclaro/ssm/internal/aws"
+	"github.com/andreclaro/ssm/internal/socks"
+	"github.com/andreclaro/ssm/internal/storage"
+)
+
+// SocksProxy runs a SOCKS5 proxy on listenAddr until interrupted. Every
+// CONNECT opens its own remote-host port forwarding session through
+// instance, so any host:port reachable from the instance can be used.
+func (s *Service) SocksProxy(ctx context.Context, instance *storage.Instance, listenAddr string, out io.Writer) error {
+	clientManager := aws.NewClientManager()
+	client, err := clientManager.GetClient(ctx, instance.Profile, instance.Region)
+	if err != nil {
+		return fmt.Errorf("failed to get AWS client: %w", err)
+	}
+
+	ssmManager := aws.NewSSMSessionManager(client)
+	if err := ssmManager.CheckReachability(ctx, instance.InstanceID); err != nil {
+		return fmt.Errorf("instance not reachable via SSM: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return fmt.Errorf("%w: %v", aws.ErrLocalPortUnavailable, err)
+	}
+	defer listener.Close()
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id": instance.InstanceID,
+		"name":        instance.Name,
+		"listen":      listener.Addr().String(),
+	}).Info("Starting SOCKS5 proxy")
+	fmt.Fprintf(out, "SOCKS5 proxy listening on %s via %s (%s)\n", listener.Addr(), instance.DisplayName(), instance.InstanceID)
+	defer recordConnection(instance, storage.ConnectionModeSocks, listener.Addr().String())()
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &socks.Server{
+		Dial: func(ctx context.Context, host string, port int) (io.ReadWriteCloser, error) {
+			return ssmManager.DialRemoteHost(ctx, instance.InstanceID, host, port)
+		},
+	}
+	return server.Serve(ctx, listener)
+}
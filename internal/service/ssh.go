@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/andreclaro/ssm/internal/aws"
+	"github.com/andreclaro/ssm/internal/config"
+	"github.com/andreclaro/ssm/internal/storage"
+)
+
+// platformUsers maps platform names, matched case-insensitively as
+// substrings, to the login user of their official AMIs
+var platformUsers = []struct{ platform, user string }{
+	{"ubuntu", "ubuntu"},
+	{"debian", "admin"},
+	{"centos", "centos"},
+	{"fedora", "fedora"},
+	{"rocky", "rocky"},
+	{"bitnami", "bitnami"},
+	{"amazon linux", "ec2-user"},
+	{"red hat", "ec2-user"},
+	{"suse", "ec2-user"},
+}
+
+// SSHKey is the key pushed with EC2 Instance Connect before relaying an SSH
+// connection
+type SSHKey struct {
+	// User is the OS user the key is authorized for
+	User string
+	// Identity is the private key file; empty selects SSHKeyPath
+	Identity string
+}
+
+// SSHOptions configure an 'ssm ssh' connection
+type SSHOptions struct {
+	User string
+	// Identity is the private key file; empty selects SSHKeyPath
+	Identity string
+	// ProxyCommand relays the connection over SSM, see ProxyToInstance
+	ProxyCommand string
+	// Args are passed to ssh after the destination: options or a command
+	Args []string
+}
+
+// SSHKeyPath returns the private key 'ssm ssh' generates and pushes when no
+// identity is given
+func SSHKeyPath() string {
+	return filepath.Join(filepath.Dir(config.GetConfig().Database.Path), "ssh", "id_ed25519")
+}
+
+// EnsureSSHKey returns the private key to authenticate with: identity when
+// given, otherwise the key at SSHKeyPath, which is generated with ssh-keygen
+// on first use. The public key is expected next to it with a .pub suffix.
+func EnsureSSHKey(identity string) (string, error) {
+	if identity != "" {
+		if _, err := os.Stat(identity + ".pub"); err != nil {
+			return "", fmt.Errorf("public key of identity %s not found: %w", identity, err)
+		}
+		return identity, nil
+	}
+
+	path := SSHKeyPath()
+	if _, err := os.Stat(path + ".pub"); err == nil {
+		return path, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", fmt.Errorf("failed to create key directory: %w", err)
+	}
+
+	logrus.WithField("path", path).Info("Generating SSH key")
+	cmd := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", "ssm", "-f", path)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to generate SSH key: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return path, nil
+}
+
+// SSHUser returns the login user of an instance: the value of its
+// ssh.user_tag tag, else the default user of its platform, else
+// ssh.default_user. The platform the SSM agent reports names the
+// distribution, so it is preferred over the EC2 platform details.
+func SSHUser(instance *storage.Instance) (string, error) {
+	cfg := config.GetConfig()
+	for _, tag := range instance.Tags {
+		if tag.Key == cfg.SSH.UserTag && tag.Value != "" {
+			return tag.Value, nil
+		}
+	}
+
+	platforms := []string{strings.ToLower(instance.PlatformName), strings.ToLower(instance.Platform)}
+	for _, platform := range platforms {
+		if strings.Contains(platform, "windows") {
+			return "", fmt.Errorf("%s runs Windows, which EC2 Instance Connect does not support", instance.DisplayName())
+		}
+	}
+	for _, platform := range platforms {
+		for _, p := range platformUsers {
+			if strings.Contains(platform, p.platform) {
+				return p.user, nil
+			}
+		}
+	}
+	return cfg.SSH.DefaultUser, nil
+}
+
+// SSH runs ssh against an instance, relaying the connection through
+// opts.ProxyCommand, and records it in the history. A non-zero exit of ssh is
+// returned as an *exec.ExitError.
+func (s *Service) SSH(ctx context.Context, instance *storage.Instance, opts SSHOptions) error {
+	identity, err := EnsureSSHKey(opts.Identity)
+	if err != nil {
+		return err
+	}
+
+	args := []string{
+		"-i", identity,
+		"-o", "IdentitiesOnly=yes",
+		"-o", "ProxyCommand=" + opts.ProxyCommand,
+		// Known host keys follow the instance, not the name it was reached by
+		"-o", "HostKeyAlias=" + instance.InstanceID,
+		opts.User + "@" + instance.InstanceID,
+	}
+	args = append(args, opts.Args...)
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id": instance.InstanceID,
+		"command":     "ssh " + strings.Join(args, " "),
+	}).Debug("Executing ssh")
+
+	defer recordConnection(instance, storage.ConnectionModeSSH, opts.User)()
+
+	cmd := exec.CommandContext(ctx, "ssh", args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// pushSSHKey authorizes the public key of key.Identity for key.User on the
+// instance for the next 60 seconds. Instances that are not EC2 instances keep
+// relying on their own authorized keys.
+func pushSSHKey(ctx context.Context, client *aws.Client, instance *storage.Instance, key *SSHKey) error {
+	if !aws.IsEC2Instance(instance.InstanceID) {
+		logrus.WithField("instance_id", instance.InstanceID).Warn("EC2 Instance Connect only supports EC2 instances; not pushing the SSH key")
+		return nil
+	}
+
+	identity, err := EnsureSSHKey(key.Identity)
+	if err != nil {
+		return err
+	}
+	publicKey, err := os.ReadFile(identity + ".pub")
+	if err != nil {
+		return fmt.Errorf("failed to read public key: %w", err)
+	}
+	return client.SendSSHPublicKey(ctx, instance.InstanceID, key.User, strings.TrimSpace(string(publicKey)))
+}
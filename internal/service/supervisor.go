@@ -0,0 +1,203 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/andreclaro/ssm/internal/aws"
+	"github.com/andreclaro/ssm/internal/storage"
+)
+
+const (
+	// tunnelInitialBackoff is the delay before the first restart of a dropped tunnel
+	tunnelInitialBackoff = time.Second
+
+	// tunnelMaxBackoff caps the delay between restarts
+	tunnelMaxBackoff = 30 * time.Second
+
+	// tunnelStableAfter is how long a tunnel must stay up for its backoff to reset
+	tunnelStableAfter = time.Minute
+)
+
+// Tunnel is a port forward through a specific instance
+type Tunnel struct {
+	// Label prefixes the tunnel's output; defaults to the port mapping
+	Label    string
+	Instance *storage.Instance
+	Mapping  PortMapping
+}
+
+// label returns the output prefix for the tunnel
+func (t Tunnel) label() string {
+	if t.Label != "" {
+		return t.Label
+	}
+	return t.Mapping.String()
+}
+
+// TunnelSupervisor keeps a set of port forwards alive. Each tunnel runs in its
+// own goroutine and is restarted with exponential backoff when it drops, for
+// example after the Session Manager idle timeout. Every restart goes through
+// StartPortForwarding, which checks SSM reachability before opening a session.
+type TunnelSupervisor struct {
+	clientManager *aws.ClientManager
+	out           io.Writer
+	outMu         sync.Mutex
+
+	// run runs a tunnel until it drops; runOnce unless replaced in tests
+	run func(ctx context.Context, tunnel Tunnel, out io.Writer) error
+
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	stableAfter    time.Duration
+}
+
+// NewTunnelSupervisor creates a supervisor that writes tunnel output to out
+func NewTunnelSupervisor(out io.Writer) *TunnelSupervisor {
+	ts := &TunnelSupervisor{
+		clientManager:  aws.NewClientManager(),
+		out:            out,
+		initialBackoff: tunnelInitialBackoff,
+		maxBackoff:     tunnelMaxBackoff,
+		stableAfter:    tunnelStableAfter,
+	}
+	ts.run = ts.runOnce
+	return ts
+}
+
+// Run supervises the tunnels until ctx is cancelled, which shuts every tunnel
+// down and returns nil. It returns early with an error only if every tunnel
+// failed permanently, e.g. because its local port is in use.
+func (ts *TunnelSupervisor) Run(ctx context.Context, tunnels []Tunnel) error {
+	if len(tunnels) == 0 {
+		return fmt.Errorf("no tunnels provided")
+	}
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, len(tunnels))
+	for _, tunnel := range tunnels {
+		wg.Add(1)
+		go func(tunnel Tunnel) {
+			defer wg.Done()
+			if err := ts.supervise(ctx, tunnel); err != nil {
+				errCh <- fmt.Errorf("%s: %w", tunnel.label(), err)
+			}
+		}(tunnel)
+	}
+	wg.Wait()
+	close(errCh)
+
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	var errs []error
+	for err := range errCh {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// supervise runs a single tunnel, restarting it until ctx is cancelled or it
+// fails permanently
+func (ts *TunnelSupervisor) supervise(ctx context.Context, tunnel Tunnel) error {
+	out := &prefixWriter{prefix: []byte("[" + tunnel.label() + "] "), w: ts.out, mu: &ts.outMu}
+	log := logrus.WithFields(logrus.Fields{
+		"tunnel":      tunnel.label(),
+		"instance_id": tunnel.Instance.InstanceID,
+	})
+
+	backoff := ts.initialBackoff
+	for {
+		started := time.Now()
+		err := ts.run(ctx, tunnel, out)
+		out.Flush()
+
+		if ctx.Err() != nil {
+			return nil
+		}
+		if errors.Is(err, aws.ErrLocalPortUnavailable) {
+			fmt.Fprintf(out, "Giving up: %v\n", err)
+			return err
+		}
+
+		if time.Since(started) >= ts.stableAfter {
+			backoff = ts.initialBackoff
+		}
+		if err != nil {
+			fmt.Fprintf(out, "Tunnel failed: %v; reconnecting in %s\n", err, backoff)
+		} else {
+			fmt.Fprintf(out, "Tunnel closed; reconnecting in %s\n", backoff)
+		}
+		log.WithError(err).Debug("Restarting tunnel")
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, ts.maxBackoff)
+	}
+}
+
+// runOnce starts the tunnel's port forwarding session and blocks until it ends
+func (ts *TunnelSupervisor) runOnce(ctx context.Context, tunnel Tunnel, out io.Writer) error {
+	client, err := ts.clientManager.GetClient(ctx, tunnel.Instance.Profile, tunnel.Instance.Region)
+	if err != nil {
+		return fmt.Errorf("failed to get AWS client: %w", err)
+	}
+
+	ssmManager := aws.NewSSMSessionManager(client)
+	return ssmManager.StartPortForwarding(ctx, tunnel.Instance.InstanceID,
+		tunnel.Mapping.RemoteHost, tunnel.Mapping.LocalPort, tunnel.Mapping.RemotePort, out)
+}
+
+// prefixWriter prefixes every line written to w. Complete lines are written
+// under a shared mutex so output from concurrent tunnels does not interleave.
+type prefixWriter struct {
+	prefix []byte
+	w      io.Writer
+	mu     *sync.Mutex
+	buf    []byte
+}
+
+// Write implements io.Writer
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.buf = append(p.buf, b...)
+	for {
+		idx := bytes.IndexByte(p.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		line := append(append([]byte{}, p.prefix...), p.buf[:idx+1]...)
+		if _, err := p.w.Write(line); err != nil {
+			return len(b), err
+		}
+		p.buf = p.buf[idx+1:]
+	}
+	return len(b), nil
+}
+
+// Flush writes any buffered partial line
+func (p *prefixWriter) Flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.buf) == 0 {
+		return
+	}
+	line := append(append([]byte{}, p.prefix...), p.buf...)
+	p.w.Write(append(line, '\n'))
+	p.buf = nil
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/andreclaro/ssm/internal/aws"
+	"github.com/andreclaro/ssm/internal/storage"
+)
+
+// newTestSupervisor returns a supervisor with millisecond backoffs whose
+// tunnels are run by run
+func newTestSupervisor(out io.Writer, run func(ctx context.Context, attempt int) error) *TunnelSupervisor {
+	attempts := 0
+	return &TunnelSupervisor{
+		out: out,
+		run: func(ctx context.Context, tunnel Tunnel, out io.Writer) error {
+			attempts++
+			return run(ctx, attempts)
+		},
+		initialBackoff: time.Millisecond,
+		maxBackoff:     4 * time.Millisecond,
+		stableAfter:    50 * time.Millisecond,
+	}
+}
+
+// testTunnel is a tunnel to a fake instance
+var testTunnel = Tunnel{
+	Instance: &storage.Instance{InstanceID: "i-0123456789abcdef0"},
+	Mapping:  PortMapping{LocalPort: 8888, RemotePort: 80},
+}
+
+// backoffs returns the reconnect delays announced in supervisor output
+func backoffs(output string) []string {
+	var delays []string
+	for _, match := range regexp.MustCompile(`reconnecting in (\S+)`).FindAllStringSubmatch(output, -1) {
+		delays = append(delays, match[1])
+	}
+	return delays
+}
+
+// TestTunnelSupervisor_RestartsWithBackoff tests that dropped tunnels are
+// restarted with a doubling, capped backoff
+func TestTunnelSupervisor_RestartsWithBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var out bytes.Buffer
+	ts := newTestSupervisor(&out, func(ctx context.Context, attempt int) error {
+		if attempt == 6 {
+			cancel()
+			return nil
+		}
+		return fmt.Errorf("connection lost")
+	})
+
+	require.NoError(t, ts.Run(ctx, []Tunnel{testTunnel}))
+	assert.Equal(t, []string{"1ms", "2ms", "4ms", "4ms", "4ms"}, backoffs(out.String()))
+	assert.Contains(t, out.String(), "[8888:80] Tunnel failed: connection lost; reconnecting in 1ms\n")
+}
+
+// TestTunnelSupervisor_ResetsBackoff tests that a tunnel that stayed up
+// restarts with the initial backoff
+func TestTunnelSupervisor_ResetsBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var out bytes.Buffer
+	ts := newTestSupervisor(&out, func(ctx context.Context, attempt int) error {
+		switch attempt {
+		case 3:
+			// Stays up past stableAfter, then closes cleanly
+			time.Sleep(60 * time.Millisecond)
+			return nil
+		case 4:
+			cancel()
+			return nil
+		}
+		return fmt.Errorf("connection lost")
+	})
+
+	require.NoError(t, ts.Run(ctx, []Tunnel{testTunnel}))
+	assert.Equal(t, []string{"1ms", "2ms", "1ms"}, backoffs(out.String()))
+	assert.Contains(t, out.String(), "Tunnel closed; reconnecting in 1ms\n")
+}
+
+// TestTunnelSupervisor_GivesUp tests that a tunnel whose local port is taken
+// is not restarted
+func TestTunnelSupervisor_GivesUp(t *testing.T) {
+	var out bytes.Buffer
+	attempts := 0
+	ts := newTestSupervisor(&out, func(ctx context.Context, attempt int) error {
+		attempts = attempt
+		return fmt.Errorf("%w: 8888", aws.ErrLocalPortUnavailable)
+	})
+
+	err := ts.Run(context.Background(), []Tunnel{testTunnel})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, aws.ErrLocalPortUnavailable))
+	assert.Equal(t, 1, attempts)
+	assert.Contains(t, out.String(), "[8888:80] Giving up: local port unavailable: 8888\n")
+}
@@ -0,0 +1,307 @@
+package service
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/andreclaro/ssm/internal/aws"
+	"github.com/andreclaro/ssm/internal/storage"
+)
+
+// CopyToInstance copies a local file, or with recursive a directory, to
+// remotePath on instance
+func (s *Service) CopyToInstance(ctx context.Context, localPath string, instance *storage.Instance, remotePath string, recursive bool, progress aws.ProgressFunc) error {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", localPath, err)
+	}
+	if info.IsDir() && !recursive {
+		return fmt.Errorf("%s is a directory (use -r)", localPath)
+	}
+
+	ssmManager, err := s.transferSessionManager(ctx, instance)
+	if err != nil {
+		return err
+	}
+
+	req := aws.UploadRequest{
+		Kind:       aws.TransferFile,
+		RemotePath: remotePath,
+		Name:       filepath.Base(localPath),
+		Mode:       info.Mode(),
+	}
+
+	// Directories are sent as a gzipped tar archive built in a temp file so
+	// the size and checksum are known before the transfer starts
+	sourcePath := localPath
+	if info.IsDir() {
+		archive, err := createArchive(localPath)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(archive)
+		sourcePath = archive
+		req.Kind = aws.TransferDirectory
+	}
+
+	file, err := os.Open(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", sourcePath, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return fmt.Errorf("failed to checksum %s: %w", sourcePath, err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind %s: %w", sourcePath, err)
+	}
+	req.Source = file
+	req.Size = size
+	req.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id": instance.InstanceID,
+		"local_path":  localPath,
+		"remote_path": remotePath,
+		"bytes":       size,
+	}).Info("Uploading to instance")
+	defer recordConnection(instance, storage.ConnectionModeCopy, localPath+" -> "+remotePath)()
+
+	if err := ssmManager.Upload(ctx, instance.InstanceID, req, progress); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", localPath, err)
+	}
+	return nil
+}
+
+// CopyFromInstance copies remotePath on instance, a file or with recursive a
+// directory, to localPath
+func (s *Service) CopyFromInstance(ctx context.Context, instance *storage.Instance, remotePath, localPath string, recursive bool, progress aws.ProgressFunc) error {
+	ssmManager, err := s.transferSessionManager(ctx, instance)
+	if err != nil {
+		return err
+	}
+
+	// Download into a temp file so a failed or corrupt transfer leaves nothing behind
+	tmp, err := os.CreateTemp(localDir(localPath), ".ssm-cp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id": instance.InstanceID,
+		"remote_path": remotePath,
+		"local_path":  localPath,
+	}).Info("Downloading from instance")
+	defer recordConnection(instance, storage.ConnectionModeCopy, remotePath+" -> "+localPath)()
+
+	kind, err := ssmManager.Download(ctx, instance.InstanceID, remotePath, recursive, tmp, progress)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", remotePath, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp.Name(), err)
+	}
+
+	if kind == aws.TransferDirectory {
+		if err := os.MkdirAll(localPath, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", localPath, err)
+		}
+		return extractArchive(tmp.Name(), localPath)
+	}
+
+	target := localPath
+	if info, err := os.Stat(localPath); err == nil && info.IsDir() {
+		target = filepath.Join(localPath, aws.RemoteBase(remotePath))
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to set permissions on %s: %w", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return fmt.Errorf("failed to move download to %s: %w", target, err)
+	}
+	return nil
+}
+
+// transferSessionManager returns a session manager for transfers to instance
+func (s *Service) transferSessionManager(ctx context.Context, instance *storage.Instance) (*aws.SSMSessionManager, error) {
+	if strings.Contains(strings.ToLower(instance.Platform), "windows") {
+		return nil, fmt.Errorf("file transfer is only supported on Linux instances")
+	}
+
+	clientManager := aws.NewClientManager()
+	client, err := clientManager.GetClient(ctx, instance.Profile, instance.Region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get AWS client: %w", err)
+	}
+
+	return aws.NewSSMSessionManager(client), nil
+}
+
+// localDir returns the directory a download to localPath should be staged in
+func localDir(localPath string) string {
+	if info, err := os.Stat(localPath); err == nil && info.IsDir() {
+		return localPath
+	}
+	return filepath.Dir(localPath)
+}
+
+// createArchive writes dir to a temporary gzipped tar archive whose entries
+// are rooted at the directory's base name, and returns the archive path
+func createArchive(dir string) (string, error) {
+	tmp, err := os.CreateTemp("", "ssm-cp-*.tar.gz")
+	if err != nil {
+		return "", fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer tmp.Close()
+
+	gz := gzip.NewWriter(tmp)
+	tw := tar.NewWriter(gz)
+
+	root := filepath.Clean(dir)
+	base := filepath.Base(root)
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(filepath.Join(base, rel))
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to archive %s: %w", dir, err)
+	}
+
+	return tmp.Name(), nil
+}
+
+// extractArchive extracts a gzipped tar archive into dest, rejecting entries
+// that would escape it
+func extractArchive(archive, dest string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+	defer gz.Close()
+
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	// Entries are written through an os.Root, so neither a path nor a symlink
+	// extracted earlier can lead outside dest
+	root, err := os.OpenRoot(dest)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if name == "." {
+			continue
+		}
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("archive entry %q escapes destination", header.Name)
+		}
+
+		mode := os.FileMode(header.Mode).Perm()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := root.MkdirAll(name, mode|0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := root.MkdirAll(filepath.Dir(name), 0755); err != nil {
+				return err
+			}
+			out, err := root.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return err
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			// Links may only point within dest, relative to their directory
+			link := filepath.FromSlash(header.Linkname)
+			if filepath.IsAbs(link) || !filepath.IsLocal(filepath.Join(filepath.Dir(name), link)) {
+				return fmt.Errorf("archive symlink %q points outside destination", header.Name)
+			}
+			if err := root.MkdirAll(filepath.Dir(name), 0755); err != nil {
+				return err
+			}
+			if err := root.Symlink(link, name); err != nil && !os.IsExist(err) {
+				return err
+			}
+		default:
+			logrus.WithField("entry", header.Name).Debug("Skipping unsupported archive entry")
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestArchive_RoundTrip tests that a directory survives createArchive and extractArchive
+func TestArchive_RoundTrip(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "nested"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "app.conf"), []byte("port=80\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "nested", "extra.conf"), []byte("debug=true\n"), 0644))
+
+	archive, err := createArchive(src)
+	require.NoError(t, err)
+	defer os.Remove(archive)
+
+	dest := t.TempDir()
+	require.NoError(t, extractArchive(archive, dest))
+
+	data, err := os.ReadFile(filepath.Join(dest, "config", "app.conf"))
+	require.NoError(t, err)
+	assert.Equal(t, "port=80\n", string(data))
+
+	info, err := os.Stat(filepath.Join(dest, "config", "app.conf"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	data, err = os.ReadFile(filepath.Join(dest, "config", "nested", "extra.conf"))
+	require.NoError(t, err)
+	assert.Equal(t, "debug=true\n", string(data))
+}
+
+// TestExtractArchive_RejectsTraversal tests that entries escaping the destination are refused
+func TestExtractArchive_RejectsTraversal(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "evil.tar.gz")
+	f, err := os.Create(archive)
+	require.NoError(t, err)
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "../escape", Mode: 0644, Size: 1, Typeflag: tar.TypeReg}))
+	_, err = tw.Write([]byte("x"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	require.NoError(t, f.Close())
+
+	dest := filepath.Join(t.TempDir(), "dest")
+	require.NoError(t, os.MkdirAll(dest, 0755))
+	assert.Error(t, extractArchive(archive, dest))
+
+	_, err = os.Stat(filepath.Join(filepath.Dir(dest), "escape"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+// TestExtractArchive_RejectsSymlinkEscape tests that symlinks cannot be used
+// to write outside the destination
+func TestExtractArchive_RejectsSymlinkEscape(t *testing.T) {
+	outside := t.TempDir()
+
+	tests := []struct {
+		name     string
+		linkname string
+	}{
+		{"absolute", outside},
+		{"relative", "../" + filepath.Base(outside)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := filepath.Join(t.TempDir(), "evil.tar.gz")
+			f, err := os.Create(archive)
+			require.NoError(t, err)
+
+			// A link to a directory outside dest, then a file written through it
+			gz := gzip.NewWriter(f)
+			tw := tar.NewWriter(gz)
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: "x", Linkname: tt.linkname, Typeflag: tar.TypeSymlink}))
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: "x/.ssh/authorized_keys", Mode: 0600, Size: 3, Typeflag: tar.TypeReg}))
+			_, err = tw.Write([]byte("key"))
+			require.NoError(t, err)
+			require.NoError(t, tw.Close())
+			require.NoError(t, gz.Close())
+			require.NoError(t, f.Close())
+
+			dest := filepath.Join(filepath.Dir(outside), "dest-"+tt.name)
+			require.NoError(t, os.MkdirAll(dest, 0755))
+			assert.Error(t, extractArchive(archive, dest))
+
+			_, err = os.Stat(filepath.Join(outside, ".ssh", "authorized_keys"))
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/andreclaro/ssm/internal/config"
+	"github.com/andreclaro/ssm/internal/storage"
+)
+
+// Tunnel health values reported by ListTunnels
+const (
+	// TunnelHealthUp means every local port of the tunnel is being served
+	TunnelHealthUp = "up"
+
+	// TunnelHealthConnecting means the tunnel process is (re)connecting
+	TunnelHealthConnecting = "connecting"
+
+	// TunnelHealthUnknown means the listening sockets could not be inspected
+	TunnelHealthUnknown = "unknown"
+)
+
+// detachTimeout bounds how long to wait for a detached tunnel to register
+const detachTimeout = 15 * time.Second
+
+// TunnelStatus is a recorded tunnel with its current health
+type TunnelStatus struct {
+	storage.Tunnel
+	Health string
+}
+
+// RunTunnel forwards ports through the named instance until interrupted. The
+// process is recorded in the tunnels table for the duration so it can be
+// listed and stopped from other shells.
+func (s *Service) RunTunnel(ctx context.Context, instanceName string, mappings []PortMapping, logPath string) error {
+	if len(mappings) == 0 {
+		return fmt.Errorf("no port mappings provided")
+	}
+
+	instance, err := findInstance(instanceName)
+	if err != nil {
+		return err
+	}
+	return s.RunTunnelTo(ctx, instance, mappings, logPath)
+}
+
+// RunTunnelTo forwards ports through an instance record like RunTunnel
+func (s *Service) RunTunnelTo(ctx context.Context, instance *storage.Instance, mappings []PortMapping, logPath string) error {
+	logrus.WithFields(logrus.Fields{
+		"instance_id": instance.InstanceID,
+		"name":        instance.Name,
+		"profile":     instance.Profile,
+		"region":      instance.Region,
+		"mappings":    len(mappings),
+	}).Info("Starting port forwarding to instance")
+
+	tunnels := make([]Tunnel, 0, len(mappings))
+	for _, m := range mappings {
+		tunnels = append(tunnels, Tunnel{Instance: instance, Mapping: m})
+	}
+
+	repo := storage.NewTunnelRepository()
+	record := &storage.Tunnel{
+		PID:          os.Getpid(),
+		InstanceID:   instance.InstanceID,
+		InstanceName: instance.DisplayName(),
+		Profile:      instance.Profile,
+		Region:       instance.Region,
+		Mappings:     joinMappings(mappings),
+		LogPath:      logPath,
+		StartedAt:    time.Now(),
+	}
+	if err := repo.Create(record); err != nil {
+		return err
+	}
+	defer repo.Delete(record.ID)
+
+	defer recordConnection(instance, storage.ConnectionModeForward, record.Mappings)()
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return NewTunnelSupervisor(os.Stdout).Run(ctx, tunnels)
+}
+
+// DetachTunnel re-runs the current executable with args as a background
+// process writing to logPath, and waits for it to record its tunnel
+func (s *Service) DetachTunnel(args []string, logPath string) (*storage.Tunnel, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate executable: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(executable, args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = detachedProcAttr()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start tunnel process: %w", err)
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	repo := storage.NewTunnelRepository()
+	deadline := time.After(detachTimeout)
+	for {
+		tunnel, err := repo.FindByPID(cmd.Process.Pid)
+		if err != nil {
+			return nil, err
+		}
+		if tunnel != nil {
+			return tunnel, nil
+		}
+
+		select {
+		case <-exited:
+			return nil, fmt.Errorf("tunnel process exited; see %s", logPath)
+		case <-deadline:
+			return nil, fmt.Errorf("tunnel process %d did not start in time; see %s", cmd.Process.Pid, logPath)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// TunnelLogPath returns a new log file path for a detached tunnel
+func TunnelLogPath() string {
+	name := fmt.Sprintf("tunnel-%s.log", time.Now().Format("20060102-150405.000"))
+	return filepath.Join(filepath.Dir(config.GetConfig().Database.Path), "run", name)
+}
+
+// ListTunnels returns the running tunnels with their health. Records left
+// behind by processes that no longer exist are removed.
+func (s *Service) ListTunnels() ([]TunnelStatus, error) {
+	repo := storage.NewTunnelRepository()
+	tunnels, err := repo.List()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]TunnelStatus, 0, len(tunnels))
+	for _, tunnel := range tunnels {
+		if !ssmProcessAlive(tunnel.PID) {
+			if err := repo.Delete(tunnel.ID); err != nil {
+				logrus.WithError(err).WithField("tunnel", tunnel.ID).Warn("Failed to remove stale tunnel")
+			}
+			continue
+		}
+		statuses = append(statuses, TunnelStatus{Tunnel: tunnel, Health: tunnelHealth(tunnel)})
+	}
+	return statuses, nil
+}
+
+// StopTunnel stops the process serving a tunnel and removes its record
+func (s *Service) StopTunnel(id uint) error {
+	repo := storage.NewTunnelRepository()
+	tunnel, err := repo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if tunnel == nil {
+		return fmt.Errorf("tunnel %d not found", id)
+	}
+
+	// A PID reused by another program is left alone; the record is stale
+	if ssmProcessAlive(tunnel.PID) {
+		if err := stopProcess(tunnel.PID); err != nil {
+			return err
+		}
+	}
+	return repo.Delete(tunnel.ID)
+}
+
+// StopAllTunnels stops every recorded tunnel and returns how many were stopped
+func (s *Service) StopAllTunnels() (int, error) {
+	tunnels, err := storage.NewTunnelRepository().List()
+	if err != nil {
+		return 0, err
+	}
+
+	stopped := 0
+	for _, tunnel := range tunnels {
+		if err := s.StopTunnel(tunnel.ID); err != nil {
+			return stopped, fmt.Errorf("tunnel %d: %w", tunnel.ID, err)
+		}
+		stopped++
+	}
+	return stopped, nil
+}
+
+// tunnelHealth reports whether the tunnel process listens on every local port
+// of a tunnel. The supervisor only holds a port while its session is up, so a
+// missing listener means the tunnel is reconnecting. The sockets are looked up
+// rather than connected to, which would open a stream to the remote port.
+func tunnelHealth(tunnel storage.Tunnel) string {
+	for _, spec := range splitMappings(tunnel.Mappings) {
+		mapping, err := ParsePortMapping(spec)
+		if err != nil {
+			return TunnelHealthConnecting
+		}
+		listening, err := listensOn(tunnel.PID, mapping.LocalPort)
+		if err != nil {
+			logrus.WithError(err).WithField("tunnel", tunnel.ID).Debug("Failed to inspect tunnel sockets")
+			return TunnelHealthUnknown
+		}
+		if !listening {
+			return TunnelHealthConnecting
+		}
+	}
+	return TunnelHealthUp
+}
+
+// joinMappings encodes port mappings for the tunnels table
+func joinMappings(mappings []PortMapping) string {
+	specs := make([]string, len(mappings))
+	for i, m := range mappings {
+		specs[i] = m.String()
+	}
+	return strings.Join(specs, ",")
+}
+
+// splitMappings decodes port mappings from the tunnels table
+func splitMappings(mappings string) []string {
+	if mappings == "" {
+		return nil
+	}
+	return strings.Split(mappings, ",")
+}
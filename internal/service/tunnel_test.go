@@ -0,0 +1,44 @@
+package service
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSSMProcessAlive tests that only processes of this executable count as
+// tunnel processes
+func TestSSMProcessAlive(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sleep(1)")
+	}
+	assert.True(t, ssmProcessAlive(os.Getpid()))
+
+	// A recycled PID belongs to some other program
+	other := exec.Command("sleep", "10")
+	require.NoError(t, other.Start())
+	defer other.Process.Kill()
+	assert.False(t, ssmProcessAlive(other.Process.Pid))
+}
+
+// TestListensOn tests that tunnel health comes from the process's own
+// listening sockets
+func TestListensOn(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	listening, err := listensOn(os.Getpid(), port)
+	require.NoError(t, err)
+	assert.True(t, listening)
+
+	ln.Close()
+	listening, err = listensOn(os.Getpid(), port)
+	require.NoError(t, err)
+	assert.False(t, listening)
+}
@@ -0,0 +1,615 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// ClientVersion is reported to the agent when the channel is opened. It must
+// be above 1.1.70, from which the agent multiplexes port sessions with smux
+// (see Mux), and below 1.2.0, from which it separates the stderr of
+// non-interactive commands into messages this client does not expect.
+const ClientVersion = "1.1.86.0"
+
+// muxAgentVersion is the agent version after which port sessions are multiplexed
+const muxAgentVersion = "3.0.196.0"
+
+const (
+	schemaVersion = 1
+
+	// streamDataPayloadSize is the largest input payload sent in one message
+	streamDataPayloadSize = 1024
+
+	// Unacknowledged input is resent after resendTimeout
+	resendTimeout  = 2 * time.Second
+	resendInterval = 500 * time.Millisecond
+
+	// pingInterval keeps idle connections open through proxies and the service
+	pingInterval = 5 * time.Minute
+
+	// handshakeTimeout bounds how long we wait for agents that never send a handshake
+	handshakeTimeout = 5 * time.Second
+)
+
+// Handshake action statuses
+const (
+	actionSuccess     = 1
+	actionFailed      = 2
+	actionUnsupported = 3
+)
+
+// ErrKMSEncryption is returned when the session requires KMS encryption
+var ErrKMSEncryption = errors.New("session requires KMS encryption, which the native client does not support (set session.client to \"cli\")")
+
+// Options controls how a Channel delivers agent output
+type Options struct {
+	// Stderr receives stderr output of interactive commands. When nil,
+	// stderr is merged into the channel's read stream.
+	Stderr io.Writer
+}
+
+// openDataChannelInput is the first (text) frame sent on the WebSocket
+type openDataChannelInput struct {
+	MessageSchemaVersion string
+	RequestID            string `json:"RequestId"`
+	TokenValue           string
+	ClientID             string `json:"ClientId"`
+	ClientVersion        string
+}
+
+// acknowledgeContent is the payload of an acknowledge message
+type acknowledgeContent struct {
+	AcknowledgedMessageType           string
+	AcknowledgedMessageID             string `json:"AcknowledgedMessageId"`
+	AcknowledgedMessageSequenceNumber int64
+	IsSequentialMessage               bool
+}
+
+// channelClosedContent is the payload of a channel_closed message
+type channelClosedContent struct {
+	SessionID string `json:"SessionId"`
+	Output    string
+}
+
+// requestedClientAction is an action the agent asks for during the handshake
+type requestedClientAction struct {
+	ActionType       string
+	ActionParameters json.RawMessage
+}
+
+// handshakeRequest is sent by the agent before the session starts
+type handshakeRequest struct {
+	AgentVersion           string
+	RequestedClientActions []requestedClientAction
+}
+
+// processedClientAction reports the outcome of one requested action
+type processedClientAction struct {
+	ActionType   string
+	ActionStatus int
+	ActionResult json.RawMessage
+	Error        string
+}
+
+// handshakeResponse answers a handshakeRequest
+type handshakeResponse struct {
+	ClientVersion          string
+	ProcessedClientActions []processedClientAction
+	Errors                 []string
+}
+
+// handshakeComplete is sent by the agent once the session is ready
+type handshakeComplete struct {
+	HandshakeTimeToComplete time.Duration
+	CustomerMessage         string
+}
+
+// pendingMessage is an input message waiting for an acknowledgement
+type pendingMessage struct {
+	data   []byte
+	sentAt time.Time
+}
+
+// Channel is a Session Manager data channel. Reading returns the output of
+// the remote session in order; writing sends input to it.
+type Channel struct {
+	conn *websocket.Conn
+	opts Options
+
+	// writeMu serialises writes to the WebSocket connection
+	writeMu sync.Mutex
+
+	mu          sync.Mutex
+	cond        *sync.Cond
+	paused      bool
+	closed      bool
+	nextSeq     int64
+	expectedSeq int64
+	inbound     map[int64]*Message
+	outbound    map[int64]*pendingMessage
+	closeReason string
+	err         error
+
+	agentVersion string
+
+	reader *io.PipeReader
+	writer *io.PipeWriter
+
+	ready     chan struct{}
+	readyOnce sync.Once
+	done      chan struct{}
+	doneOnce  sync.Once
+}
+
+// Dial connects to a session stream URL returned by ssm.StartSession and
+// opens the data channel with the session token
+func Dial(ctx context.Context, streamURL, token string, opts Options) (*Channel, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, streamURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to session stream: %w", err)
+	}
+
+	ch := newChannel(conn, opts)
+
+	open := openDataChannelInput{
+		MessageSchemaVersion: "1.0",
+		RequestID:            NewUUID().String(),
+		TokenValue:           token,
+		ClientID:             NewUUID().String(),
+		ClientVersion:        ClientVersion,
+	}
+	data, err := json.Marshal(open)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to encode open data channel request: %w", err)
+	}
+	if err := ch.writeMessage(websocket.TextMessage, data); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open data channel: %w", err)
+	}
+
+	go ch.readLoop()
+	go ch.resendLoop()
+	go ch.pingLoop()
+
+	return ch, nil
+}
+
+// newChannel wraps an established WebSocket connection
+func newChannel(conn *websocket.Conn, opts Options) *Channel {
+	reader, writer := io.Pipe()
+	ch := &Channel{
+		conn:     conn,
+		opts:     opts,
+		inbound:  make(map[int64]*Message),
+		outbound: make(map[int64]*pendingMessage),
+		reader:   reader,
+		writer:   writer,
+		ready:    make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	ch.cond = sync.NewCond(&ch.mu)
+	return ch
+}
+
+// Read reads session output, returning io.EOF once the channel is closed
+func (c *Channel) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// Write sends p to the session as input, split into stream data messages
+func (c *Channel) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		end := written + streamDataPayloadSize
+		if end > len(p) {
+			end = len(p)
+		}
+		if err := c.send(PayloadOutput, p[written:end]); err != nil {
+			return written, err
+		}
+		written = end
+	}
+	return written, nil
+}
+
+// SetSize informs the agent of the local terminal size
+func (c *Channel) SetSize(cols, rows int) error {
+	payload, err := json.Marshal(struct {
+		Cols int `json:"cols"`
+		Rows int `json:"rows"`
+	}{cols, rows})
+	if err != nil {
+		return err
+	}
+	return c.send(PayloadSize, payload)
+}
+
+// SendFlag sends a control flag such as FlagDisconnectToPort
+func (c *Channel) SendFlag(flag uint32) error {
+	payload := []byte{byte(flag >> 24), byte(flag >> 16), byte(flag >> 8), byte(flag)}
+	return c.send(PayloadFlag, payload)
+}
+
+// Ready is closed once the agent completes the session handshake
+func (c *Channel) Ready() <-chan struct{} {
+	return c.ready
+}
+
+// WaitReady blocks until the session handshake completes. Agents that never
+// send a handshake are given handshakeTimeout, after which the session is
+// used anyway.
+func (c *Channel) WaitReady(ctx context.Context) error {
+	select {
+	case <-c.ready:
+	case <-c.done:
+		return c.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(handshakeTimeout):
+	}
+	return nil
+}
+
+// AgentVersion returns the version of the agent, known once the handshake
+// completed. It is empty for agents that never send a handshake.
+func (c *Channel) AgentVersion() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.agentVersion
+}
+
+// Multiplexed reports whether the agent multiplexes port sessions, so their
+// data must be relayed through a Mux. It is only meaningful once WaitReady
+// returned.
+func (c *Channel) Multiplexed() bool {
+	version := c.AgentVersion()
+	return version != "" && compareVersions(version, muxAgentVersion) > 0
+}
+
+// Done is closed once the channel is closed by either side
+func (c *Channel) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the reason the channel closed, or nil for a clean close
+func (c *Channel) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// CloseReason returns the message sent by the agent when it closed the channel
+func (c *Channel) CloseReason() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closeReason
+}
+
+// Close closes the data channel
+func (c *Channel) Close() error {
+	c.writeMu.Lock()
+	c.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		time.Now().Add(time.Second))
+	c.writeMu.Unlock()
+
+	c.finish(nil)
+	return c.conn.Close()
+}
+
+// send queues an input stream data message and writes it to the connection
+func (c *Channel) send(payloadType PayloadType, payload []byte) error {
+	c.mu.Lock()
+	for c.paused && !c.closed {
+		c.cond.Wait()
+	}
+	if c.closed {
+		c.mu.Unlock()
+		return io.ErrClosedPipe
+	}
+
+	msg := &Message{
+		MessageType:    InputStreamMessage,
+		SchemaVersion:  schemaVersion,
+		CreatedDate:    time.Now(),
+		SequenceNumber: c.nextSeq,
+		MessageID:      NewUUID(),
+		PayloadType:    payloadType,
+		Payload:        payload,
+	}
+	data, err := msg.MarshalBinary()
+	if err != nil {
+		c.mu.Unlock()
+		return err
+	}
+	c.outbound[c.nextSeq] = &pendingMessage{data: data, sentAt: time.Now()}
+	c.nextSeq++
+
+	// Take the write lock before releasing mu so messages hit the wire in sequence order
+	c.writeMu.Lock()
+	c.mu.Unlock()
+	defer c.writeMu.Unlock()
+
+	if err := c.conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+		return fmt.Errorf("failed to send input: %w", err)
+	}
+	return nil
+}
+
+// writeMessage writes a single frame to the connection
+func (c *Channel) writeMessage(messageType int, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteMessage(messageType, data)
+}
+
+// readLoop processes frames from the agent until the connection closes
+func (c *Channel) readLoop() {
+	for {
+		messageType, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				err = nil
+			}
+			c.finish(err)
+			return
+		}
+		if messageType != websocket.BinaryMessage {
+			continue
+		}
+
+		var msg Message
+		if err := msg.UnmarshalBinary(data); err != nil {
+			logrus.WithError(err).Debug("Dropping malformed session message")
+			continue
+		}
+
+		switch msg.MessageType {
+		case OutputStreamMessage:
+			c.handleOutput(&msg)
+		case AcknowledgeMessage:
+			c.handleAcknowledge(&msg)
+		case ChannelClosedMessage:
+			var content channelClosedContent
+			if err := json.Unmarshal(msg.Payload, &content); err == nil {
+				c.mu.Lock()
+				c.closeReason = content.Output
+				c.mu.Unlock()
+			}
+			c.finish(nil)
+			return
+		case StartPublicationMessage, PausePublicationMessage:
+			c.mu.Lock()
+			c.paused = msg.MessageType == PausePublicationMessage
+			c.cond.Broadcast()
+			c.mu.Unlock()
+		default:
+			logrus.WithField("message_type", msg.MessageType).Debug("Ignoring unknown session message")
+		}
+	}
+}
+
+// handleOutput acknowledges an output message and delivers it in sequence order
+func (c *Channel) handleOutput(msg *Message) {
+	if err := c.acknowledge(msg); err != nil {
+		logrus.WithError(err).Debug("Failed to acknowledge session message")
+	}
+
+	c.mu.Lock()
+	if msg.SequenceNumber < c.expectedSeq {
+		// Duplicate of a message we already processed
+		c.mu.Unlock()
+		return
+	}
+	c.inbound[msg.SequenceNumber] = msg
+
+	var deliver []*Message
+	for {
+		next, ok := c.inbound[c.expectedSeq]
+		if !ok {
+			break
+		}
+		delete(c.inbound, c.expectedSeq)
+		deliver = append(deliver, next)
+		c.expectedSeq++
+	}
+	c.mu.Unlock()
+
+	for _, m := range deliver {
+		c.process(m)
+	}
+}
+
+// process handles a single in-order output message
+func (c *Channel) process(msg *Message) {
+	switch msg.PayloadType {
+	case PayloadOutput:
+		c.writer.Write(msg.Payload)
+	case PayloadStdErr:
+		if c.opts.Stderr != nil {
+			c.opts.Stderr.Write(msg.Payload)
+		} else {
+			c.writer.Write(msg.Payload)
+		}
+	case PayloadHandshakeRequest:
+		if err := c.handleHandshakeRequest(msg.Payload); err != nil {
+			logrus.WithError(err).Warn("Session handshake failed")
+		}
+	case PayloadHandshakeComplete:
+		var complete handshakeComplete
+		if err := json.Unmarshal(msg.Payload, &complete); err == nil && complete.CustomerMessage != "" {
+			c.writer.Write([]byte(complete.CustomerMessage + "\r\n"))
+		}
+		c.readyOnce.Do(func() { close(c.ready) })
+	default:
+		logrus.WithField("payload_type", msg.PayloadType).Debug("Ignoring session payload")
+	}
+}
+
+// handleHandshakeRequest answers the actions requested by the agent
+func (c *Channel) handleHandshakeRequest(payload []byte) error {
+	var request handshakeRequest
+	if err := json.Unmarshal(payload, &request); err != nil {
+		return fmt.Errorf("invalid handshake request: %w", err)
+	}
+
+	logrus.WithField("agent_version", request.AgentVersion).Debug("Received session handshake")
+
+	c.mu.Lock()
+	c.agentVersion = request.AgentVersion
+	c.mu.Unlock()
+
+	response := handshakeResponse{
+		ClientVersion: ClientVersion,
+		Errors:        []string{},
+	}
+	var handshakeErr error
+	for _, action := range request.RequestedClientActions {
+		processed := processedClientAction{ActionType: action.ActionType}
+		switch action.ActionType {
+		case "SessionType":
+			processed.ActionStatus = actionSuccess
+		case "KMSEncryption":
+			processed.ActionStatus = actionFailed
+			processed.Error = ErrKMSEncryption.Error()
+			response.Errors = append(response.Errors, processed.Error)
+			handshakeErr = ErrKMSEncryption
+		default:
+			processed.ActionStatus = actionUnsupported
+			processed.Error = fmt.Sprintf("unsupported action %s", action.ActionType)
+		}
+		response.ProcessedClientActions = append(response.ProcessedClientActions, processed)
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	if err := c.send(PayloadHandshakeResponse, data); err != nil {
+		return err
+	}
+
+	if handshakeErr != nil {
+		c.mu.Lock()
+		c.err = handshakeErr
+		c.mu.Unlock()
+		return handshakeErr
+	}
+	return nil
+}
+
+// acknowledge sends an acknowledgement for a received message
+func (c *Channel) acknowledge(msg *Message) error {
+	payload, err := json.Marshal(acknowledgeContent{
+		AcknowledgedMessageType:           msg.MessageType,
+		AcknowledgedMessageID:             msg.MessageID.String(),
+		AcknowledgedMessageSequenceNumber: msg.SequenceNumber,
+		IsSequentialMessage:               true,
+	})
+	if err != nil {
+		return err
+	}
+
+	ack := &Message{
+		MessageType:   AcknowledgeMessage,
+		SchemaVersion: schemaVersion,
+		CreatedDate:   time.Now(),
+		Flags:         3,
+		MessageID:     NewUUID(),
+		Payload:       payload,
+	}
+	data, err := ack.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return c.writeMessage(websocket.BinaryMessage, data)
+}
+
+// handleAcknowledge removes acknowledged input from the resend queue
+func (c *Channel) handleAcknowledge(msg *Message) {
+	var content acknowledgeContent
+	if err := json.Unmarshal(msg.Payload, &content); err != nil {
+		logrus.WithError(err).Debug("Dropping malformed acknowledgement")
+		return
+	}
+
+	c.mu.Lock()
+	delete(c.outbound, content.AcknowledgedMessageSequenceNumber)
+	c.mu.Unlock()
+}
+
+// resendLoop retransmits input that the agent has not acknowledged in time
+func (c *Channel) resendLoop() {
+	ticker := time.NewTicker(resendInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		var resend [][]byte
+		c.mu.Lock()
+		for _, pending := range c.outbound {
+			if now.Sub(pending.sentAt) >= resendTimeout {
+				pending.sentAt = now
+				resend = append(resend, pending.data)
+			}
+		}
+		c.mu.Unlock()
+
+		for _, data := range resend {
+			if err := c.writeMessage(websocket.BinaryMessage, data); err != nil {
+				logrus.WithError(err).Debug("Failed to resend session message")
+			}
+		}
+	}
+}
+
+// pingLoop sends periodic WebSocket pings
+func (c *Channel) pingLoop() {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.writeMu.Lock()
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second))
+			c.writeMu.Unlock()
+			if err != nil {
+				logrus.WithError(err).Debug("Failed to ping session stream")
+			}
+		}
+	}
+}
+
+// finish marks the channel closed and unblocks readers and writers
+func (c *Channel) finish(err error) {
+	c.doneOnce.Do(func() {
+		c.mu.Lock()
+		c.closed = true
+		if c.err == nil {
+			c.err = err
+		}
+		c.cond.Broadcast()
+		c.mu.Unlock()
+
+		c.writer.CloseWithError(err)
+		close(c.done)
+	})
+}
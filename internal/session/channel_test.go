@@ -0,0 +1,225 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAgent is a minimal Session Manager endpoint for exercising the client
+type fakeAgent struct {
+	t      *testing.T
+	server *httptest.Server
+	conns  chan *websocket.Conn
+}
+
+// newFakeAgent starts a WebSocket server that hands accepted connections to the test
+func newFakeAgent(t *testing.T) *fakeAgent {
+	agent := &fakeAgent{t: t, conns: make(chan *websocket.Conn, 1)}
+	upgrader := websocket.Upgrader{}
+	agent.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		agent.conns <- conn
+	}))
+	t.Cleanup(agent.server.Close)
+	return agent
+}
+
+// url returns the WebSocket URL of the fake agent
+func (a *fakeAgent) url() string {
+	return "ws" + strings.TrimPrefix(a.server.URL, "http")
+}
+
+// accept waits for the client to connect and checks the open request
+func (a *fakeAgent) accept(token string) *websocket.Conn {
+	conn := <-a.conns
+
+	messageType, data, err := conn.ReadMessage()
+	require.NoError(a.t, err)
+	assert.Equal(a.t, websocket.TextMessage, messageType)
+
+	var open openDataChannelInput
+	require.NoError(a.t, json.Unmarshal(data, &open))
+	assert.Equal(a.t, token, open.TokenValue)
+	assert.Equal(a.t, ClientVersion, open.ClientVersion)
+	return conn
+}
+
+// send writes a message to the client
+func (a *fakeAgent) send(conn *websocket.Conn, msg *Message) {
+	msg.SchemaVersion = schemaVersion
+	msg.CreatedDate = time.Now()
+	msg.MessageID = NewUUID()
+	data, err := msg.MarshalBinary()
+	require.NoError(a.t, err)
+	require.NoError(a.t, conn.WriteMessage(websocket.BinaryMessage, data))
+}
+
+// receive reads the next message from the client
+func (a *fakeAgent) receive(conn *websocket.Conn) *Message {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := conn.ReadMessage()
+	require.NoError(a.t, err)
+
+	var msg Message
+	require.NoError(a.t, msg.UnmarshalBinary(data))
+	return &msg
+}
+
+// TestMessage_RoundTrip tests encoding and decoding of the wire format
+func TestMessage_RoundTrip(t *testing.T) {
+	msg := &Message{
+		MessageType:    InputStreamMessage,
+		SchemaVersion:  1,
+		CreatedDate:    time.UnixMilli(1700000000000),
+		SequenceNumber: 42,
+		Flags:          3,
+		MessageID:      NewUUID(),
+		PayloadType:    PayloadOutput,
+		Payload:        []byte("ls -la\n"),
+	}
+
+	data, err := msg.MarshalBinary()
+	require.NoError(t, err)
+	assert.Len(t, data, payloadOffset+len(msg.Payload))
+
+	var decoded Message
+	require.NoError(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, msg.MessageType, decoded.MessageType)
+	assert.Equal(t, msg.SequenceNumber, decoded.SequenceNumber)
+	assert.Equal(t, msg.Flags, decoded.Flags)
+	assert.Equal(t, msg.MessageID, decoded.MessageID)
+	assert.Equal(t, msg.PayloadType, decoded.PayloadType)
+	assert.Equal(t, msg.Payload, decoded.Payload)
+	assert.True(t, msg.CreatedDate.Equal(decoded.CreatedDate))
+
+	// Corrupting the payload must fail the digest check
+	data[len(data)-1] ^= 0xff
+	assert.Error(t, decoded.UnmarshalBinary(data))
+}
+
+// TestChannel_Session tests the handshake, ordering, acknowledgements and close
+func TestChannel_Session(t *testing.T) {
+	agent := newFakeAgent(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ch, err := Dial(ctx, agent.url(), "secret-token", Options{})
+	require.NoError(t, err)
+	defer ch.Close()
+
+	conn := agent.accept("secret-token")
+	defer conn.Close()
+
+	// Handshake
+	request, err := json.Marshal(handshakeRequest{
+		AgentVersion: "3.3.0.0",
+		RequestedClientActions: []requestedClientAction{
+			{ActionType: "SessionType", ActionParameters: json.RawMessage(`{"SessionType":"Standard_Stream"}`)},
+		},
+	})
+	require.NoError(t, err)
+	agent.send(conn, &Message{MessageType: OutputStreamMessage, SequenceNumber: 0, PayloadType: PayloadHandshakeRequest, Payload: request})
+
+	ack := agent.receive(conn)
+	assert.Equal(t, AcknowledgeMessage, ack.MessageType)
+
+	response := agent.receive(conn)
+	assert.Equal(t, InputStreamMessage, response.MessageType)
+	assert.Equal(t, PayloadHandshakeResponse, response.PayloadType)
+	assert.Equal(t, int64(0), response.SequenceNumber)
+
+	var processed handshakeResponse
+	require.NoError(t, json.Unmarshal(response.Payload, &processed))
+	require.Len(t, processed.ProcessedClientActions, 1)
+	assert.Equal(t, actionSuccess, processed.ProcessedClientActions[0].ActionStatus)
+
+	agent.send(conn, &Message{MessageType: OutputStreamMessage, SequenceNumber: 1, PayloadType: PayloadHandshakeComplete, Payload: []byte(`{}`)})
+	agent.receive(conn) // ack
+
+	select {
+	case <-ch.Ready():
+	case <-time.After(5 * time.Second):
+		t.Fatal("handshake did not complete")
+	}
+
+	// Output delivered out of order must be read in sequence order
+	agent.send(conn, &Message{MessageType: OutputStreamMessage, SequenceNumber: 3, PayloadType: PayloadOutput, Payload: []byte("world")})
+	agent.send(conn, &Message{MessageType: OutputStreamMessage, SequenceNumber: 2, PayloadType: PayloadOutput, Payload: []byte("hello ")})
+
+	acked := map[int64]bool{}
+	for i := 0; i < 2; i++ {
+		msg := agent.receive(conn)
+		require.Equal(t, AcknowledgeMessage, msg.MessageType)
+
+		var content acknowledgeContent
+		require.NoError(t, json.Unmarshal(msg.Payload, &content))
+		acked[content.AcknowledgedMessageSequenceNumber] = true
+	}
+	assert.Equal(t, map[int64]bool{2: true, 3: true}, acked)
+
+	buf := make([]byte, len("hello world"))
+	_, err = io.ReadFull(ch, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(buf))
+
+	// Input continues the client's own sequence
+	_, err = ch.Write([]byte("uptime\n"))
+	require.NoError(t, err)
+	input := agent.receive(conn)
+	assert.Equal(t, int64(1), input.SequenceNumber)
+	assert.Equal(t, PayloadOutput, input.PayloadType)
+	assert.Equal(t, "uptime\n", string(input.Payload))
+
+	// Closing the channel ends the read stream
+	closed, err := json.Marshal(channelClosedContent{Output: "Session terminated"})
+	require.NoError(t, err)
+	agent.send(conn, &Message{MessageType: ChannelClosedMessage, Payload: closed})
+
+	rest, err := io.ReadAll(ch)
+	require.NoError(t, err)
+	assert.Empty(t, rest)
+	assert.Equal(t, "Session terminated", ch.CloseReason())
+	assert.NoError(t, ch.Err())
+}
+
+// TestChannel_KMSEncryptionRejected tests that unsupported encryption fails the session
+func TestChannel_KMSEncryptionRejected(t *testing.T) {
+	agent := newFakeAgent(t)
+
+	ch, err := Dial(context.Background(), agent.url(), "token", Options{})
+	require.NoError(t, err)
+	defer ch.Close()
+
+	conn := agent.accept("token")
+	defer conn.Close()
+
+	request, err := json.Marshal(handshakeRequest{
+		RequestedClientActions: []requestedClientAction{{ActionType: "KMSEncryption"}},
+	})
+	require.NoError(t, err)
+	agent.send(conn, &Message{MessageType: OutputStreamMessage, PayloadType: PayloadHandshakeRequest, Payload: request})
+
+	agent.receive(conn) // ack
+	response := agent.receive(conn)
+
+	var processed handshakeResponse
+	require.NoError(t, json.Unmarshal(response.Payload, &processed))
+	require.Len(t, processed.ProcessedClientActions, 1)
+	assert.Equal(t, actionFailed, processed.ProcessedClientActions[0].ActionStatus)
+
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	<-ch.Done()
+	assert.ErrorIs(t, ch.Err(), ErrKMSEncryption)
+}
@@ -0,0 +1,174 @@
+package session
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Message types exchanged on the Session Manager data channel
+const (
+	InputStreamMessage      = "input_stream_data"
+	OutputStreamMessage     = "output_stream_data"
+	AcknowledgeMessage      = "acknowledge"
+	ChannelClosedMessage    = "channel_closed"
+	StartPublicationMessage = "start_publication"
+	PausePublicationMessage = "pause_publication"
+)
+
+// PayloadType identifies the content of a stream data message
+type PayloadType uint32
+
+// Payload types understood by the SSM agent
+const (
+	PayloadOutput               PayloadType = 1
+	PayloadError                PayloadType = 2
+	PayloadSize                 PayloadType = 3
+	PayloadParameter            PayloadType = 4
+	PayloadHandshakeRequest     PayloadType = 5
+	PayloadHandshakeResponse    PayloadType = 6
+	PayloadHandshakeComplete    PayloadType = 7
+	PayloadEncChallengeRequest  PayloadType = 8
+	PayloadEncChallengeResponse PayloadType = 9
+	PayloadFlag                 PayloadType = 10
+	PayloadStdErr               PayloadType = 11
+	PayloadExitCode             PayloadType = 12
+)
+
+// Flags carried in a PayloadFlag message
+const (
+	FlagDisconnectToPort   uint32 = 1
+	FlagTerminateSession   uint32 = 2
+	FlagConnectToPortError uint32 = 3
+)
+
+// Binary layout of a client message. Every field is big-endian and the
+// payload starts right after the payload length field.
+const (
+	headerLengthOffset   = 0
+	messageTypeOffset    = 4
+	messageTypeLength    = 32
+	schemaVersionOffset  = 36
+	createdDateOffset    = 40
+	sequenceNumberOffset = 48
+	flagsOffset          = 56
+	messageIDOffset      = 64
+	payloadDigestOffset  = 80
+	payloadDigestLength  = 32
+	payloadTypeOffset    = 112
+	payloadLengthOffset  = 116
+	payloadOffset        = 120
+)
+
+// UUID is a 16 byte message or client identifier
+type UUID [16]byte
+
+// NewUUID returns a random (version 4) UUID
+func NewUUID() UUID {
+	var u UUID
+	if _, err := rand.Read(u[:]); err != nil {
+		panic(fmt.Sprintf("failed to generate uuid: %v", err))
+	}
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return u
+}
+
+// String formats the UUID in its canonical 8-4-4-4-12 form
+func (u UUID) String() string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:36], u[10:16])
+	return string(buf[:])
+}
+
+// Message is a single frame on the data channel
+type Message struct {
+	MessageType    string
+	SchemaVersion  uint32
+	CreatedDate    time.Time
+	SequenceNumber int64
+	Flags          uint64
+	MessageID      UUID
+	PayloadType    PayloadType
+	Payload        []byte
+}
+
+// MarshalBinary encodes the message in the agent wire format
+func (m *Message) MarshalBinary() ([]byte, error) {
+	if len(m.MessageType) > messageTypeLength {
+		return nil, fmt.Errorf("message type %q exceeds %d bytes", m.MessageType, messageTypeLength)
+	}
+
+	buf := make([]byte, payloadOffset+len(m.Payload))
+	binary.BigEndian.PutUint32(buf[headerLengthOffset:], payloadLengthOffset)
+
+	// The message type is right-padded with spaces
+	copy(buf[messageTypeOffset:messageTypeOffset+messageTypeLength], bytes.Repeat([]byte{' '}, messageTypeLength))
+	copy(buf[messageTypeOffset:], m.MessageType)
+
+	binary.BigEndian.PutUint32(buf[schemaVersionOffset:], m.SchemaVersion)
+	binary.BigEndian.PutUint64(buf[createdDateOffset:], uint64(m.CreatedDate.UnixMilli()))
+	binary.BigEndian.PutUint64(buf[sequenceNumberOffset:], uint64(m.SequenceNumber))
+	binary.BigEndian.PutUint64(buf[flagsOffset:], m.Flags)
+
+	// The agent stores UUIDs as two longs, least significant half first
+	copy(buf[messageIDOffset:messageIDOffset+8], m.MessageID[8:16])
+	copy(buf[messageIDOffset+8:messageIDOffset+16], m.MessageID[0:8])
+
+	digest := sha256.Sum256(m.Payload)
+	copy(buf[payloadDigestOffset:payloadDigestOffset+payloadDigestLength], digest[:])
+
+	binary.BigEndian.PutUint32(buf[payloadTypeOffset:], uint32(m.PayloadType))
+	binary.BigEndian.PutUint32(buf[payloadLengthOffset:], uint32(len(m.Payload)))
+	copy(buf[payloadOffset:], m.Payload)
+
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a message in the agent wire format
+func (m *Message) UnmarshalBinary(data []byte) error {
+	if len(data) < payloadOffset {
+		return fmt.Errorf("message too short: %d bytes", len(data))
+	}
+
+	headerLength := binary.BigEndian.Uint32(data[headerLengthOffset:])
+	if headerLength < payloadLengthOffset || int(headerLength)+4 > len(data) {
+		return fmt.Errorf("invalid header length %d", headerLength)
+	}
+
+	m.MessageType = strings.TrimRight(string(data[messageTypeOffset:messageTypeOffset+messageTypeLength]), " \x00")
+	m.SchemaVersion = binary.BigEndian.Uint32(data[schemaVersionOffset:])
+	m.CreatedDate = time.UnixMilli(int64(binary.BigEndian.Uint64(data[createdDateOffset:])))
+	m.SequenceNumber = int64(binary.BigEndian.Uint64(data[sequenceNumberOffset:]))
+	m.Flags = binary.BigEndian.Uint64(data[flagsOffset:])
+	copy(m.MessageID[8:16], data[messageIDOffset:messageIDOffset+8])
+	copy(m.MessageID[0:8], data[messageIDOffset+8:messageIDOffset+16])
+	m.PayloadType = PayloadType(binary.BigEndian.Uint32(data[payloadTypeOffset:]))
+
+	payloadLength := binary.BigEndian.Uint32(data[headerLength:])
+	start := int(headerLength) + 4
+	if start+int(payloadLength) > len(data) {
+		return fmt.Errorf("payload length %d exceeds message size %d", payloadLength, len(data))
+	}
+	m.Payload = data[start : start+int(payloadLength)]
+
+	digest := sha256.Sum256(m.Payload)
+	if !bytes.Equal(digest[:], data[payloadDigestOffset:payloadDigestOffset+payloadDigestLength]) {
+		return fmt.Errorf("payload digest mismatch for %s message %d", m.MessageType, m.SequenceNumber)
+	}
+
+	return nil
+}
@@ -0,0 +1,333 @@
+package session
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// smux v1 framing, as spoken by the agent on multiplexed port sessions: a
+// version byte, a command byte, the little-endian data length and stream ID,
+// then the data
+const (
+	muxVersion    = 1
+	muxHeaderSize = 8
+
+	// muxMaxFrameSize is the largest data sent in one frame
+	muxMaxFrameSize = 32 * 1024
+
+	// muxStreamBuffer bounds the unread data of a stream before the session
+	// stops reading, as smux does
+	muxStreamBuffer = 1024 * 1024
+
+	// muxKeepAliveInterval keeps the agent's smux session from timing out
+	muxKeepAliveInterval = 10 * time.Second
+)
+
+// smux commands
+const (
+	muxSYN byte = iota
+	muxFIN
+	muxPSH
+	muxNOP
+)
+
+// Mux multiplexes connections over a port session. Every stream is a
+// connection the agent makes to the session's port, so a single session
+// serves any number of concurrent connections.
+type Mux struct {
+	ch *Channel
+
+	// writeMu keeps frames whole on the channel
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	streams map[uint32]*MuxStream
+	nextID  uint32
+	err     error
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewMux starts multiplexing streams over ch, which must be a port session
+// of an agent for which ch.Multiplexed reports true
+func NewMux(ch *Channel) *Mux {
+	m := &Mux{
+		ch:      ch,
+		streams: make(map[uint32]*MuxStream),
+		// Client streams have odd IDs
+		nextID: 1,
+		done:   make(chan struct{}),
+	}
+	go m.readLoop()
+	go m.keepAlive()
+	return m
+}
+
+// Open opens a new stream, which the agent connects to the session's port
+func (m *Mux) Open() (*MuxStream, error) {
+	m.mu.Lock()
+	if m.err != nil {
+		err := m.err
+		m.mu.Unlock()
+		return nil, err
+	}
+	s := &MuxStream{mux: m, id: m.nextID}
+	s.cond = sync.NewCond(&s.mu)
+	m.streams[s.id] = s
+	m.nextID += 2
+	m.mu.Unlock()
+
+	if err := m.writeFrame(muxSYN, s.id, nil); err != nil {
+		m.remove(s.id)
+		return nil, err
+	}
+	return s, nil
+}
+
+// Done is closed once the mux is closed or the channel fails
+func (m *Mux) Done() <-chan struct{} {
+	return m.done
+}
+
+// Err returns the error that ended the mux, io.EOF once the channel closed
+// cleanly, or nil while it is running
+func (m *Mux) Err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.err
+}
+
+// Close closes all streams. The channel is left open.
+func (m *Mux) Close() error {
+	m.fail(io.ErrClosedPipe)
+	return nil
+}
+
+// fail ends the mux and all of its streams with err
+func (m *Mux) fail(err error) {
+	m.closeOnce.Do(func() {
+		m.mu.Lock()
+		m.err = err
+		streams := m.streams
+		m.streams = make(map[uint32]*MuxStream)
+		m.mu.Unlock()
+
+		for _, s := range streams {
+			s.finish(err)
+		}
+		close(m.done)
+	})
+}
+
+// stream returns the open stream with id, or nil
+func (m *Mux) stream(id uint32) *MuxStream {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.streams[id]
+}
+
+// remove forgets a stream
+func (m *Mux) remove(id uint32) {
+	m.mu.Lock()
+	delete(m.streams, id)
+	m.mu.Unlock()
+}
+
+// writeFrame sends one frame
+func (m *Mux) writeFrame(cmd byte, id uint32, data []byte) error {
+	frame := make([]byte, muxHeaderSize+len(data))
+	frame[0] = muxVersion
+	frame[1] = cmd
+	binary.LittleEndian.PutUint16(frame[2:], uint16(len(data)))
+	binary.LittleEndian.PutUint32(frame[4:], id)
+	copy(frame[muxHeaderSize:], data)
+
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+	_, err := m.ch.Write(frame)
+	return err
+}
+
+// readLoop dispatches frames from the agent to their streams until the
+// channel closes
+func (m *Mux) readLoop() {
+	header := make([]byte, muxHeaderSize)
+	for {
+		if _, err := io.ReadFull(m.ch, header); err != nil {
+			m.fail(channelError(m.ch, err))
+			return
+		}
+		if header[0] != muxVersion {
+			m.fail(fmt.Errorf("unsupported smux version %d", header[0]))
+			return
+		}
+
+		data := make([]byte, binary.LittleEndian.Uint16(header[2:]))
+		if _, err := io.ReadFull(m.ch, data); err != nil {
+			m.fail(channelError(m.ch, err))
+			return
+		}
+
+		id := binary.LittleEndian.Uint32(header[4:])
+		switch header[1] {
+		case muxPSH:
+			if s := m.stream(id); s != nil {
+				s.push(data)
+			}
+		case muxFIN:
+			if s := m.stream(id); s != nil {
+				s.finish(io.EOF)
+			}
+		}
+	}
+}
+
+// keepAlive sends NOP frames so the agent does not time the session out
+func (m *Mux) keepAlive() {
+	ticker := time.NewTicker(muxKeepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			if err := m.writeFrame(muxNOP, 0, nil); err != nil {
+				m.fail(err)
+				return
+			}
+		}
+	}
+}
+
+// channelError returns the error that ended the mux's channel, io.EOF for a
+// clean close
+func channelError(ch *Channel, err error) error {
+	if chErr := ch.Err(); chErr != nil {
+		return chErr
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return io.EOF
+	}
+	return err
+}
+
+// MuxStream is a single connection over a Mux
+type MuxStream struct {
+	mux *Mux
+	id  uint32
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	buf  []byte
+	// err is returned by Read once buf is drained: io.EOF after the agent
+	// closed the stream
+	err       error
+	closeOnce sync.Once
+}
+
+// Read reads data the agent received from the remote port
+func (s *MuxStream) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.buf) == 0 && s.err == nil {
+		s.cond.Wait()
+	}
+	if len(s.buf) == 0 {
+		return 0, s.err
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	s.cond.Broadcast()
+	return n, nil
+}
+
+// Write sends p to the remote port
+func (s *MuxStream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	closed := s.err != nil && s.err != io.EOF
+	s.mu.Unlock()
+	if closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	written := 0
+	for written < len(p) {
+		end := min(written+muxMaxFrameSize, len(p))
+		if err := s.mux.writeFrame(muxPSH, s.id, p[written:end]); err != nil {
+			return written, err
+		}
+		written = end
+	}
+	return written, nil
+}
+
+// Close closes the stream, which closes the agent's connection to the port
+func (s *MuxStream) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.buf = nil
+		s.err = io.ErrClosedPipe
+		s.cond.Broadcast()
+		s.mu.Unlock()
+
+		s.mux.remove(s.id)
+		select {
+		case <-s.mux.done:
+		default:
+			err = s.mux.writeFrame(muxFIN, s.id, nil)
+		}
+	})
+	return err
+}
+
+// push buffers data for Read, waiting while the buffer is full
+func (s *MuxStream) push(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.buf) >= muxStreamBuffer && s.err == nil {
+		s.cond.Wait()
+	}
+	if s.err == nil {
+		s.buf = append(s.buf, data...)
+		s.cond.Broadcast()
+	}
+}
+
+// finish ends the stream with err once the buffered data is read
+func (s *MuxStream) finish(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.cond.Broadcast()
+}
+
+// compareVersions compares two dotted version numbers, returning -1, 0 or 1
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,227 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Pipe relays data between a local stream and a session stream until either
+// side closes or ctx is cancelled
+func Pipe(ctx context.Context, ch io.ReadWriter, r io.Reader, w io.Writer) error {
+	errCh := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(ch, r)
+		errCh <- err
+	}()
+	go func() {
+		_, err := io.Copy(w, ch)
+		errCh <- err
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// channelStream is the single connection of a port session that is not
+// multiplexed. Closing it leaves the channel open.
+type channelStream struct {
+	*Channel
+}
+
+// Close does nothing; the channel is closed by its owner
+func (channelStream) Close() error {
+	return nil
+}
+
+// muxStream is the only stream of a Mux, closing the Mux with it
+type muxStream struct {
+	*MuxStream
+}
+
+// Close closes the stream and its Mux
+func (s muxStream) Close() error {
+	err := s.MuxStream.Close()
+	s.mux.Close()
+	return err
+}
+
+// OpenStream waits for the handshake of a port session and returns its
+// connection to the remote port. Closing the stream leaves the channel open.
+func OpenStream(ctx context.Context, ch *Channel) (io.ReadWriteCloser, error) {
+	if err := ch.WaitReady(ctx); err != nil {
+		return nil, err
+	}
+	if !ch.Multiplexed() {
+		return channelStream{ch}, nil
+	}
+
+	mux := NewMux(ch)
+	stream, err := mux.Open()
+	if err != nil {
+		mux.Close()
+		return nil, err
+	}
+	return muxStream{stream}, nil
+}
+
+// ForwardPort accepts connections on listener and relays them over a port
+// forwarding session. Agents that multiplex port sessions serve every
+// connection concurrently on a stream of its own. Older agents serve one
+// connection per session at a time, so connections are handled
+// sequentially; after each one the agent is told to disconnect from the
+// remote port so the next connection starts fresh.
+func ForwardPort(ctx context.Context, ch *Channel, listener net.Listener) error {
+	if err := ch.WaitReady(ctx); err != nil {
+		return err
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-ch.Done():
+		}
+		listener.Close()
+	}()
+
+	if ch.Multiplexed() {
+		return forwardMultiplexed(ctx, ch, listener)
+	}
+	return forwardSequential(ctx, ch, listener)
+}
+
+// forwardMultiplexed relays every accepted connection over a stream of its own
+func forwardMultiplexed(ctx context.Context, ch *Channel, listener net.Listener) error {
+	// Closing the mux ends the relays, so wait for them after it is closed
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	mux := NewMux(ch)
+	defer mux.Close()
+
+	go func() {
+		<-mux.Done()
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if muxErr := mux.Err(); muxErr != nil && muxErr != io.EOF && ctx.Err() == nil {
+				return muxErr
+			}
+			return acceptError(ctx, ch, err)
+		}
+
+		logrus.WithField("remote", conn.RemoteAddr().String()).Debug("Accepted port forwarding connection")
+
+		stream, err := mux.Open()
+		if err != nil {
+			conn.Close()
+			return acceptError(ctx, ch, err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			relay(conn, stream)
+		}()
+	}
+}
+
+// relay copies between a connection and a stream until either side closes,
+// then closes both
+func relay(conn net.Conn, stream io.ReadWriteCloser) {
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(stream, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, stream)
+		done <- struct{}{}
+	}()
+
+	<-done
+	conn.Close()
+	stream.Close()
+	<-done
+}
+
+// acceptError turns the error that ended the accept loop into the result of
+// ForwardPort
+func acceptError(ctx context.Context, ch *Channel, err error) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-ch.Done():
+		return ch.Err()
+	default:
+	}
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// forwardSequential relays accepted connections one at a time
+func forwardSequential(ctx context.Context, ch *Channel, listener net.Listener) error {
+	var (
+		mu      sync.Mutex
+		current net.Conn
+	)
+
+	// A single reader drains the channel and hands output to the active connection
+	go func() {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := ch.Read(buf)
+			if n > 0 {
+				mu.Lock()
+				conn := current
+				mu.Unlock()
+				if conn != nil {
+					conn.Write(buf[:n])
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return acceptError(ctx, ch, err)
+		}
+
+		logrus.WithField("remote", conn.RemoteAddr().String()).Debug("Accepted port forwarding connection")
+
+		mu.Lock()
+		current = conn
+		mu.Unlock()
+
+		_, copyErr := io.Copy(ch, conn)
+
+		mu.Lock()
+		current = nil
+		mu.Unlock()
+		conn.Close()
+
+		if copyErr != nil && errors.Is(copyErr, io.ErrClosedPipe) {
+			return ch.Err()
+		}
+		if err := ch.SendFlag(FlagDisconnectToPort); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package session
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// muxFrame is an smux frame sent by the client
+type muxFrame struct {
+	cmd  byte
+	id   uint32
+	data []byte
+}
+
+// muxFrameReader decodes the smux frames in the client's input messages
+type muxFrameReader struct {
+	agent *fakeAgent
+	conn  *websocket.Conn
+	seen  map[int64]bool
+	buf   []byte
+}
+
+// next returns the next frame that is not a keepalive
+func (r *muxFrameReader) next() muxFrame {
+	for {
+		if len(r.buf) >= muxHeaderSize {
+			length := int(binary.LittleEndian.Uint16(r.buf[2:]))
+			if len(r.buf) >= muxHeaderSize+length {
+				frame := muxFrame{
+					cmd:  r.buf[1],
+					id:   binary.LittleEndian.Uint32(r.buf[4:]),
+					data: r.buf[muxHeaderSize : muxHeaderSize+length],
+				}
+				r.buf = r.buf[muxHeaderSize+length:]
+				if frame.cmd != muxNOP {
+					return frame
+				}
+				continue
+			}
+		}
+
+		msg := r.agent.receive(r.conn)
+		// Unacknowledged input is resent; keep the first copy
+		if msg.MessageType != InputStreamMessage || msg.PayloadType != PayloadOutput || r.seen[msg.SequenceNumber] {
+			continue
+		}
+		r.seen[msg.SequenceNumber] = true
+		r.buf = append(r.buf, msg.Payload...)
+	}
+}
+
+// encodeMuxFrame encodes a frame sent by the agent
+func encodeMuxFrame(cmd byte, id uint32, data []byte) []byte {
+	frame := make([]byte, muxHeaderSize+len(data))
+	frame[0] = muxVersion
+	frame[1] = cmd
+	binary.LittleEndian.PutUint16(frame[2:], uint16(len(data)))
+	binary.LittleEndian.PutUint32(frame[4:], id)
+	copy(frame[muxHeaderSize:], data)
+	return frame
+}
+
+// TestForwardPort_Multiplexed tests that connections are relayed concurrently
+// on streams of their own when the agent multiplexes port sessions
+func TestForwardPort_Multiplexed(t *testing.T) {
+	agent := newFakeAgent(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ch, err := Dial(ctx, agent.url(), "token", Options{})
+	require.NoError(t, err)
+	defer ch.Close()
+
+	conn := agent.accept("token")
+	defer conn.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	forwardErr := make(chan error, 1)
+	go func() {
+		forwardErr <- ForwardPort(ctx, ch, listener)
+	}()
+
+	request, err := json.Marshal(handshakeRequest{AgentVersion: "3.3.0.0"})
+	require.NoError(t, err)
+	agent.send(conn, &Message{MessageType: OutputStreamMessage, SequenceNumber: 0, PayloadType: PayloadHandshakeRequest, Payload: request})
+	agent.send(conn, &Message{MessageType: OutputStreamMessage, SequenceNumber: 1, PayloadType: PayloadHandshakeComplete, Payload: []byte(`{}`)})
+
+	frames := &muxFrameReader{agent: agent, conn: conn, seen: map[int64]bool{0: true}}
+
+	// Both connections are open at the same time
+	first, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer first.Close()
+	_, err = first.Write([]byte("first"))
+	require.NoError(t, err)
+
+	syn := frames.next()
+	require.Equal(t, muxSYN, syn.cmd)
+	firstID := syn.id
+	psh := frames.next()
+	assert.Equal(t, muxPSH, psh.cmd)
+	assert.Equal(t, firstID, psh.id)
+	assert.Equal(t, "first", string(psh.data))
+
+	second, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer second.Close()
+	_, err = second.Write([]byte("second"))
+	require.NoError(t, err)
+
+	syn = frames.next()
+	require.Equal(t, muxSYN, syn.cmd)
+	secondID := syn.id
+	assert.NotEqual(t, firstID, secondID)
+	psh = frames.next()
+	assert.Equal(t, secondID, psh.id)
+	assert.Equal(t, "second", string(psh.data))
+
+	// Responses reach the connection of their stream
+	agent.send(conn, &Message{MessageType: OutputStreamMessage, SequenceNumber: 2, PayloadType: PayloadOutput, Payload: encodeMuxFrame(muxPSH, secondID, []byte("to second"))})
+	agent.send(conn, &Message{MessageType: OutputStreamMessage, SequenceNumber: 3, PayloadType: PayloadOutput, Payload: encodeMuxFrame(muxPSH, firstID, []byte("to first"))})
+
+	buf := make([]byte, len("to second"))
+	second.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = io.ReadFull(second, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "to second", string(buf))
+
+	buf = make([]byte, len("to first"))
+	first.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = io.ReadFull(first, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "to first", string(buf))
+
+	// Closing a connection closes its stream only
+	first.Close()
+	fin := frames.next()
+	assert.Equal(t, muxFIN, fin.cmd)
+	assert.Equal(t, firstID, fin.id)
+
+	// The agent closing a stream closes its connection
+	agent.send(conn, &Message{MessageType: OutputStreamMessage, SequenceNumber: 4, PayloadType: PayloadOutput, Payload: encodeMuxFrame(muxFIN, secondID, nil)})
+	second.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = second.Read(buf)
+	assert.ErrorIs(t, err, io.EOF)
+
+	cancel()
+	assert.ErrorIs(t, <-forwardErr, context.Canceled)
+}
+
+// TestCompareVersions tests ordering of agent versions
+func TestCompareVersions(t *testing.T) {
+	assert.Equal(t, 1, compareVersions("3.0.197.0", muxAgentVersion))
+	assert.Equal(t, 0, compareVersions("3.0.196.0", muxAgentVersion))
+	assert.Equal(t, -1, compareVersions("2.3.1644.0", muxAgentVersion))
+	assert.Equal(t, 1, compareVersions("3.10.0.0", "3.9.99.0"))
+	assert.Equal(t, 0, compareVersions("1.1", "1.1.0.0"))
+}
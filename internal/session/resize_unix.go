@@ -0,0 +1,30 @@
+//go:build !windows
+
+package session
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+// watchResize calls fn with the new terminal size whenever SIGWINCH is received
+func watchResize(ctx context.Context, fd int, fn func(cols, rows int)) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGWINCH)
+	defer signal.Stop(sigCh)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigCh:
+			if cols, rows, err := term.GetSize(fd); err == nil {
+				fn(cols, rows)
+			}
+		}
+	}
+}
@@ -0,0 +1,36 @@
+//go:build windows
+
+package session
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// resizePollInterval is how often the console size is checked, as Windows
+// has no resize signal
+const resizePollInterval = 500 * time.Millisecond
+
+// watchResize polls the console size and calls fn whenever it changes
+func watchResize(ctx context.Context, fd int, fn func(cols, rows int)) {
+	lastCols, lastRows, _ := term.GetSize(fd)
+
+	ticker := time.NewTicker(resizePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cols, rows, err := term.GetSize(fd)
+			if err != nil || (cols == lastCols && rows == lastRows) {
+				continue
+			}
+			lastCols, lastRows = cols, rows
+			fn(cols, rows)
+		}
+	}
+}
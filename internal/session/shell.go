@@ -0,0 +1,57 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// RunShell attaches the local terminal to an interactive session until the
+// remote side closes the channel or ctx is cancelled. When stdin is a
+// terminal it is put in raw mode so keys like Ctrl+C reach the remote shell.
+func RunShell(ctx context.Context, ch *Channel, stdin *os.File, stdout io.Writer) error {
+	if err := ch.WaitReady(ctx); err != nil {
+		return err
+	}
+
+	fd := int(stdin.Fd())
+	if term.IsTerminal(fd) {
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return fmt.Errorf("failed to set terminal to raw mode: %w", err)
+		}
+		defer term.Restore(fd, state)
+
+		if cols, rows, err := term.GetSize(fd); err == nil {
+			ch.SetSize(cols, rows)
+		}
+
+		resizeCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go watchResize(resizeCtx, fd, func(cols, rows int) {
+			ch.SetSize(cols, rows)
+		})
+	}
+
+	go io.Copy(ch, stdin)
+
+	outputDone := make(chan struct{})
+	go func() {
+		io.Copy(stdout, ch)
+		close(outputDone)
+	}()
+
+	select {
+	case <-outputDone:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if reason := ch.CloseReason(); reason != "" {
+		fmt.Fprintf(stdout, "\r\n%s\r\n", reason)
+	}
+	return ch.Err()
+}
@@ -0,0 +1,210 @@
+package socks
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+const socksVersion = 5
+
+// Authentication methods
+const (
+	methodNoAuth       = 0x00
+	methodNoAcceptable = 0xff
+)
+
+// Request commands
+const cmdConnect = 0x01
+
+// Address types
+const (
+	addrIPv4   = 0x01
+	addrDomain = 0x03
+	addrIPv6   = 0x04
+)
+
+// Reply codes
+const (
+	replySucceeded           = 0x00
+	replyGeneralFailure      = 0x01
+	replyCommandNotSupported = 0x07
+	replyAddrNotSupported    = 0x08
+)
+
+// DialFunc opens a stream to host:port
+type DialFunc func(ctx context.Context, host string, port int) (io.ReadWriteCloser, error)
+
+// Server is a SOCKS5 server supporting the CONNECT command without
+// authentication. Every CONNECT is served by a stream opened with Dial.
+type Server struct {
+	Dial DialFunc
+}
+
+// Serve accepts connections on listener until ctx is cancelled
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+			if err := s.handle(ctx, conn); err != nil {
+				logrus.WithError(err).WithField("client", conn.RemoteAddr().String()).Debug("SOCKS connection failed")
+			}
+		}()
+	}
+}
+
+// handle serves a single client connection
+func (s *Server) handle(ctx context.Context, conn net.Conn) error {
+	// Unblock reads on shutdown
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := negotiate(conn); err != nil {
+		return err
+	}
+
+	host, port, err := readRequest(conn)
+	if err != nil {
+		return err
+	}
+
+	logrus.WithField("target", net.JoinHostPort(host, strconv.Itoa(port))).Debug("SOCKS CONNECT")
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := s.Dial(ctx, host, port)
+	if err != nil {
+		writeReply(conn, replyGeneralFailure)
+		return fmt.Errorf("failed to connect to %s: %w", net.JoinHostPort(host, strconv.Itoa(port)), err)
+	}
+	defer stream.Close()
+
+	if err := writeReply(conn, replySucceeded); err != nil {
+		return err
+	}
+
+	// Relay until either side finishes, then close both
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(stream, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, stream)
+		done <- struct{}{}
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+// negotiate reads the client greeting and selects no authentication
+func negotiate(conn net.Conn) error {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return fmt.Errorf("failed to read greeting: %w", err)
+	}
+	if header[0] != socksVersion {
+		return fmt.Errorf("unsupported SOCKS version %d", header[0])
+	}
+
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return fmt.Errorf("failed to read auth methods: %w", err)
+	}
+	for _, method := range methods {
+		if method == methodNoAuth {
+			_, err := conn.Write([]byte{socksVersion, methodNoAuth})
+			return err
+		}
+	}
+
+	conn.Write([]byte{socksVersion, methodNoAcceptable})
+	return fmt.Errorf("client does not support unauthenticated access")
+}
+
+// readRequest reads a CONNECT request and returns its destination
+func readRequest(conn net.Conn) (string, int, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", 0, fmt.Errorf("failed to read request: %w", err)
+	}
+	if header[0] != socksVersion {
+		return "", 0, fmt.Errorf("unsupported SOCKS version %d", header[0])
+	}
+
+	var host string
+	switch header[3] {
+	case addrIPv4, addrIPv6:
+		size := net.IPv4len
+		if header[3] == addrIPv6 {
+			size = net.IPv6len
+		}
+		addr := make([]byte, size)
+		if _, err := io.ReadFull(conn, addr); err != nil {
+			return "", 0, fmt.Errorf("failed to read address: %w", err)
+		}
+		host = net.IP(addr).String()
+	case addrDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return "", 0, fmt.Errorf("failed to read address: %w", err)
+		}
+		name := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return "", 0, fmt.Errorf("failed to read address: %w", err)
+		}
+		host = string(name)
+	default:
+		writeReply(conn, replyAddrNotSupported)
+		return "", 0, fmt.Errorf("unsupported address type %d", header[3])
+	}
+
+	portBytes := make([]byte, 2)
+	if _, err := io.ReadFull(conn, portBytes); err != nil {
+		return "", 0, fmt.Errorf("failed to read port: %w", err)
+	}
+
+	if header[1] != cmdConnect {
+		writeReply(conn, replyCommandNotSupported)
+		return "", 0, fmt.Errorf("unsupported command %d", header[1])
+	}
+	return host, int(binary.BigEndian.Uint16(portBytes)), nil
+}
+
+// writeReply sends a reply with an unspecified bound address
+func writeReply(conn net.Conn, code byte) error {
+	_, err := conn.Write([]byte{socksVersion, code, 0, addrIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
@@ -0,0 +1,95 @@
+package socks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startServer runs a server with dial on a random local port
+func startServer(t *testing.T, dial DialFunc) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		(&Server{Dial: dial}).Serve(ctx, listener)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return listener.Addr().String()
+}
+
+// TestServer_Connect tests relaying a CONNECT to a domain name
+func TestServer_Connect(t *testing.T) {
+	var gotHost string
+	var gotPort int
+	addr := startServer(t, func(ctx context.Context, host string, port int) (io.ReadWriteCloser, error) {
+		gotHost, gotPort = host, port
+		local, remote := net.Pipe()
+		go func() {
+			defer remote.Close()
+			io.Copy(remote, remote) // echo
+		}()
+		return local, nil
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte{5, 1, 0})
+	require.NoError(t, err)
+	greeting := make([]byte, 2)
+	_, err = io.ReadFull(conn, greeting)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{5, 0}, greeting)
+
+	host := "grafana.internal"
+	request := append([]byte{5, 1, 0, 3, byte(len(host))}, host...)
+	request = append(request, 0x0b, 0xb8) // 3000
+	_, err = conn.Write(request)
+	require.NoError(t, err)
+
+	reply := make([]byte, 10)
+	_, err = io.ReadFull(conn, reply)
+	require.NoError(t, err)
+	assert.Equal(t, byte(0), reply[1])
+	assert.Equal(t, "grafana.internal", gotHost)
+	assert.Equal(t, 3000, gotPort)
+
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	echo := make([]byte, 4)
+	_, err = io.ReadFull(conn, echo)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(echo))
+}
+
+// TestServer_DialFailure tests that dial errors are reported to the client
+func TestServer_DialFailure(t *testing.T) {
+	addr := startServer(t, func(ctx context.Context, host string, port int) (io.ReadWriteCloser, error) {
+		return nil, errors.New("session failed")
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte{5, 1, 0, 5, 1, 0, 1, 10, 0, 0, 1, 0, 80})
+	require.NoError(t, err)
+
+	reply := make([]byte, 12)
+	_, err = io.ReadFull(conn, reply)
+	require.NoError(t, err)
+	assert.Equal(t, byte(replyGeneralFailure), reply[3])
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// Connection modes
+const (
+	ConnectionModeShell   = "shell"
+	ConnectionModeForward = "forward"
+	ConnectionModeSSH     = "ssh"
+	ConnectionModeProxy   = "proxy"
+	ConnectionModeSocks   = "socks"
+	ConnectionModeCopy    = "copy"
+)
+
+// frecencyWindow is how many recent connections are considered for frecency
+const frecencyWindow = 1000
+
+// ConnectionRepository handles database operations for connection history
+type ConnectionRepository struct{}
+
+// NewConnectionRepository creates a new connection repository
+func NewConnectionRepository() *ConnectionRepository {
+	return &ConnectionRepository{}
+}
+
+// Record stores the start of a connection
+func (r *ConnectionRepository) Record(connection *Connection) error {
+	if connection.StartedAt.IsZero() {
+		connection.StartedAt = time.Now()
+	}
+	if err := DB.Create(connection).Error; err != nil {
+		return fmt.Errorf("failed to record connection: %w", err)
+	}
+	return nil
+}
+
+// Finish stores how long a recorded connection lasted
+func (r *ConnectionRepository) Finish(connection *Connection) error {
+	connection.Duration = time.Since(connection.StartedAt).Round(time.Second)
+	if err := DB.Model(connection).Update("duration", connection.Duration).Error; err != nil {
+		return fmt.Errorf("failed to update connection: %w", err)
+	}
+	return nil
+}
+
+// List returns the most recent connections, newest first
+func (r *ConnectionRepository) List(limit int) ([]Connection, error) {
+	var connections []Connection
+	if err := DB.Order("id DESC").Limit(limit).Find(&connections).Error; err != nil {
+		return nil, fmt.Errorf("failed to list connections: %w", err)
+	}
+	return connections, nil
+}
+
+// Last returns the most recent connection, or nil if there is none
+func (r *ConnectionRepository) Last() (*Connection, error) {
+	connections, err := r.List(1)
+	if err != nil {
+		return nil, err
+	}
+	if len(connections) == 0 {
+		return nil, nil
+	}
+	return &connections[0], nil
+}
+
+// FrecencyScores scores instances by how often and how recently they were
+// connected to, keyed by instanceKey
+func (r *ConnectionRepository) FrecencyScores() (map[string]float64, error) {
+	connections, err := r.List(frecencyWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	scores := make(map[string]float64)
+	for _, c := range connections {
+		scores[instanceKey(c.InstanceID, c.Profile, c.Region)] += frecencyWeight(now.Sub(c.StartedAt))
+	}
+	return scores, nil
+}
+
+// frecencyWeight weights a connection by its age so recent use counts more
+func frecencyWeight(age time.Duration) float64 {
+	switch {
+	case age < 4*time.Hour:
+		return 100
+	case age < 24*time.Hour:
+		return 80
+	case age < 7*24*time.Hour:
+		return 60
+	case age < 30*24*time.Hour:
+		return 40
+	case age < 90*24*time.Hour:
+		return 20
+	default:
+		return 10
+	}
+}
+
+// instanceKey identifies an instance record across profiles and regions
+func instanceKey(instanceID, profile, region string) string {
+	return instanceID + "|" + profile + "|" + region
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConnectionRepository_RecordAndLast tests recording connections and finding the latest
+func TestConnectionRepository_RecordAndLast(t *testing.T) {
+	setupTestDB(t)
+	repo := NewConnectionRepository()
+
+	last, err := repo.Last()
+	require.NoError(t, err)
+	assert.Nil(t, last)
+
+	first := &Connection{InstanceID: "i-1111111111111111a", InstanceName: "web", Mode: ConnectionModeShell}
+	require.NoError(t, repo.Record(first))
+	second := &Connection{InstanceID: "i-2222222222222222b", InstanceName: "db", Mode: ConnectionModeForward, Detail: "5432:5432"}
+	require.NoError(t, repo.Record(second))
+
+	second.StartedAt = time.Now().Add(-90 * time.Second)
+	require.NoError(t, repo.Finish(second))
+
+	last, err = repo.Last()
+	require.NoError(t, err)
+	require.NotNil(t, last)
+	assert.Equal(t, "db", last.InstanceName)
+	assert.Equal(t, 90*time.Second, last.Duration)
+
+	connections, err := repo.List(10)
+	require.NoError(t, err)
+	assert.Len(t, connections, 2)
+}
+
+// TestInstanceRepository_Frecency tests that connection history ranks name matches and completions
+func TestInstanceRepository_Frecency(t *testing.T) {
+	setupTestDB(t)
+	repo := &InstanceRepository{}
+	connections := NewConnectionRepository()
+
+	instances := []*Instance{
+		{InstanceID: "i-1111111111111111a", Name: "bastion", Profile: "dev", Region: "us-east-1", State: "running"},
+		{InstanceID: "i-2222222222222222b", Name: "bastion", Profile: "prod", Region: "us-east-1", State: "running"},
+		{InstanceID: "i-3333333333333333c", Name: "bastion", Profile: "stage", Region: "us-east-1", State: "stopped"},
+		{InstanceID: "i-4444444444444444d", Name: "batch", Profile: "dev", Region: "us-east-1", State: "running"},
+	}
+	require.NoError(t, repo.SaveOrUpdateBatch(instances))
+
+	// The prod bastion is used often, the stopped stage bastion even more
+	for i := 0; i < 3; i++ {
+		require.NoError(t, connections.Record(&Connection{InstanceID: "i-2222222222222222b", Profile: "prod", Region: "us-east-1"}))
+	}
+	for i := 0; i < 5; i++ {
+		require.NoError(t, connections.Record(&Connection{InstanceID: "i-3333333333333333c", Profile: "stage", Region: "us-east-1"}))
+	}
+	require.NoError(t, connections.Record(&Connection{InstanceID: "i-4444444444444444d", Profile: "dev", Region: "us-east-1"}))
+
+	// Reachability still wins over frecency
+	candidates, err := repo.findNameCandidates(InstanceQuery{Name: "bastion"})
+	require.NoError(t, err)
+	require.Len(t, candidates, 3)
+	assert.Equal(t, "prod", candidates[0].Profile)
+	assert.Equal(t, "dev", candidates[1].Profile)
+	assert.Equal(t, "stage", candidates[2].Profile)
+
+	names, err := repo.CompleteNames("ba")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bastion", "batch"}, names)
+}
@@ -65,7 +65,7 @@ func InitDB() error {
 // runMigrations runs database migrations
 func runMigrations() error {
 	// Auto-migrate the schema
-	if err := DB.AutoMigrate(&Instance{}, &Tag{}, &Region{}, &Profile{}); err != nil {
+	if err := DB.AutoMigrate(&Instance{}, &Tag{}, &Region{}, &Profile{}, &Tunnel{}, &Connection{}, &ECSTask{}); err != nil {
 		return fmt.Errorf("failed to migrate schema: %w", err)
 	}
 
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ECSTaskRepository handles database operations for ECS tasks
+type ECSTaskRepository struct{}
+
+// NewECSTaskRepository creates a new ECS task repository
+func NewECSTaskRepository() *ECSTaskRepository {
+	return &ECSTaskRepository{}
+}
+
+// ReplaceAll replaces the tasks recorded for a profile and region. Tasks are
+// short-lived, so every discovery stores a fresh snapshot.
+func (r *ECSTaskRepository) ReplaceAll(profile, region string, tasks []ECSTask) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("profile = ? AND region = ?", profile, region).Delete(&ECSTask{}).Error; err != nil {
+			return fmt.Errorf("failed to delete ECS tasks: %w", err)
+		}
+		if len(tasks) == 0 {
+			return nil
+		}
+
+		now := time.Now()
+		for i := range tasks {
+			tasks[i].Profile = profile
+			tasks[i].Region = region
+			tasks[i].LastSeen = now
+		}
+		if err := tx.CreateInBatches(tasks, 100).Error; err != nil {
+			return fmt.Errorf("failed to save ECS tasks: %w", err)
+		}
+		return nil
+	})
+}
+
+// List returns every recorded task ordered by cluster, service and task ID
+func (r *ECSTaskRepository) List() ([]ECSTask, error) {
+	var tasks []ECSTask
+	if err := DB.Order("cluster, service, task_id").Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("failed to list ECS tasks: %w", err)
+	}
+	return tasks, nil
+}
+
+// FindByTarget returns the tasks a target refers to: a task ARN, a task ID or
+// a unique prefix of one, or a service name. Task IDs and service names may
+// be qualified with their cluster as cluster/name, or with the profile and
+// region too as profile/region/cluster/name.
+func (r *ECSTaskRepository) FindByTarget(target string) ([]ECSTask, error) {
+	db := DB
+	name := target
+	if strings.HasPrefix(target, "arn:") {
+		db = db.Where("task_arn = ?", target)
+	} else {
+		switch parts := strings.Split(target, "/"); len(parts) {
+		case 2:
+			db = db.Where("cluster = ?", parts[0])
+			name = parts[1]
+		case 4:
+			db = db.Where("profile = ? AND region = ? AND cluster = ?", parts[0], parts[1], parts[2])
+			name = parts[3]
+		}
+		db = db.Where(`service = ? OR task_id = ? OR task_id LIKE ? ESCAPE '\'`, name, name, escapeLike(name)+"%")
+	}
+
+	var tasks []ECSTask
+	if err := db.Order("last_seen DESC, task_id").Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("failed to find ECS tasks: %w", err)
+	}
+
+	// Prefer exact service or task ID matches over task ID prefixes
+	var exact []ECSTask
+	for _, task := range tasks {
+		if task.Service == name || task.TaskID == name || task.TaskArn == target {
+			exact = append(exact, task)
+		}
+	}
+	if len(exact) > 0 {
+		return exact, nil
+	}
+	return tasks, nil
+}
+
+// ContainerNames returns the task's exec-enabled containers
+func (t *ECSTask) ContainerNames() []string {
+	if t.Containers == "" {
+		return nil
+	}
+	return strings.Split(t.Containers, ",")
+}
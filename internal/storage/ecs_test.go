@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestECSTaskRepository_FindByTarget tests resolving services and task IDs
+func TestECSTaskRepository_FindByTarget(t *testing.T) {
+	setupTestDB(t)
+	repo := NewECSTaskRepository()
+
+	require.NoError(t, repo.ReplaceAll("dev", "us-east-1", []ECSTask{
+		{TaskArn: "arn:aws:ecs:us-east-1:111111111111:task/app/0a1b2c3d", TaskID: "0a1b2c3d", Cluster: "app", Service: "api", Containers: "api,envoy"},
+		{TaskArn: "arn:aws:ecs:us-east-1:111111111111:task/app/9f8e7d6c", TaskID: "9f8e7d6c", Cluster: "app", Service: "api", Containers: "api,envoy"},
+		{TaskArn: "arn:aws:ecs:us-east-1:111111111111:task/jobs/5a5a5a5a", TaskID: "5a5a5a5a", Cluster: "jobs", Service: "api", Containers: "worker"},
+	}))
+
+	tasks, err := repo.FindByTarget("api")
+	require.NoError(t, err)
+	assert.Len(t, tasks, 3)
+
+	tasks, err = repo.FindByTarget("app/api")
+	require.NoError(t, err)
+	assert.Len(t, tasks, 2)
+
+	// The same service in another profile is told apart by profile/region
+	require.NoError(t, repo.ReplaceAll("prod", "eu-west-1", []ECSTask{
+		{TaskArn: "arn:aws:ecs:eu-west-1:222222222222:task/app/1c1c1c1c", TaskID: "1c1c1c1c", Cluster: "app", Service: "api", Containers: "api"},
+	}))
+	tasks, err = repo.FindByTarget("app/api")
+	require.NoError(t, err)
+	assert.Len(t, tasks, 3)
+	tasks, err = repo.FindByTarget("prod/eu-west-1/app/api")
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "1c1c1c1c", tasks[0].TaskID)
+	tasks, err = repo.FindByTarget("dev/us-east-1/app/api")
+	require.NoError(t, err)
+	assert.Len(t, tasks, 2)
+
+	// Task ID prefixes are not patterns
+	tasks, err = repo.FindByTarget("_f8e")
+	require.NoError(t, err)
+	assert.Empty(t, tasks)
+
+	tasks, err = repo.FindByTarget("9f8e")
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, []string{"api", "envoy"}, tasks[0].ContainerNames())
+
+	tasks, err = repo.FindByTarget("arn:aws:ecs:us-east-1:111111111111:task/jobs/5a5a5a5a")
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "jobs", tasks[0].Cluster)
+
+	// A new snapshot replaces the previous one
+	require.NoError(t, repo.ReplaceAll("dev", "us-east-1", nil))
+	tasks, err = repo.List()
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "prod", tasks[0].Profile)
+}
@@ -2,10 +2,13 @@ package storage
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	ectype "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
@@ -17,6 +20,7 @@ type InstanceFilter struct {
 	Region  *string
 	Name    *string
 	State   *string
+	Tags    TagSelector
 }
 
 // InstanceRepository handles database operations for instances
@@ -27,46 +31,21 @@ func NewInstanceRepository() *InstanceRepository {
 	return &InstanceRepository{}
 }
 
+// instanceColumns are the discovered columns an update writes. They are
+// written even when empty, so a re-sync clears what EC2 no longer reports,
+// such as a released public IP or a detached instance profile. The SSM agent
+// status is written by UpdateSSMStatus.
+var instanceColumns = []string{
+	"name", "account_id", "state", "platform", "private_ip", "private_dns",
+	"public_ip", "vpc_id", "subnet_id", "availability_zone", "instance_type",
+	"launch_time", "image_id", "key_name", "iam_instance_profile", "lifecycle",
+	"last_seen",
+}
+
 // SaveOrUpdate saves or updates an instance in the database
 func (r *InstanceRepository) SaveOrUpdate(instance *Instance) error {
-	// Use transaction to ensure data consistency
 	return DB.Transaction(func(tx *gorm.DB) error {
-		// Upsert instance
-		if err := tx.Where(Instance{
-			InstanceID: instance.InstanceID,
-			Region:     instance.Region,
-			Profile:    instance.Profile,
-		}).Assign(Instance{
-			Name:      instance.Name,
-			AccountID: instance.AccountID,
-			State:     instance.State,
-			Platform:  instance.Platform,
-			LastSeen:  time.Now(),
-		}).FirstOrCreate(instance).Error; err != nil {
-			return fmt.Errorf("failed to save instance: %w", err)
-		}
-
-		// Only replace tags if provided to avoid wiping tags on partial updates (e.g., SSM sync)
-		if len(instance.Tags) > 0 {
-			// Delete existing tags for this instance
-			if err := tx.Where("instance_id = ?", instance.InstanceID).Delete(&Tag{}).Error; err != nil {
-				return fmt.Errorf("failed to delete existing tags: %w", err)
-			}
-
-			// Insert new tags in batches to reduce round-trips
-			newTags := make([]Tag, 0, len(instance.Tags))
-			for _, tag := range instance.Tags {
-				tag.InstanceID = instance.InstanceID
-				newTags = append(newTags, tag)
-			}
-			if len(newTags) > 0 {
-				if err := tx.CreateInBatches(newTags, 100).Error; err != nil {
-					return fmt.Errorf("failed to save tags: %w", err)
-				}
-			}
-		}
-
-		return nil
+		return upsertInstance(tx, instance)
 	})
 }
 
@@ -77,80 +56,321 @@ func (r *InstanceRepository) SaveOrUpdateBatch(instances []*Instance) error {
 	}
 
 	return DB.Transaction(func(tx *gorm.DB) error {
-		now := time.Now()
-
 		for _, instance := range instances {
-			// Upsert instance
-			if err := tx.Where(Instance{
-				InstanceID: instance.InstanceID,
-				Region:     instance.Region,
-				Profile:    instance.Profile,
-			}).Assign(Instance{
-				Name:      instance.Name,
-				AccountID: instance.AccountID,
-				State:     instance.State,
-				Platform:  instance.Platform,
-				LastSeen:  now,
-			}).FirstOrCreate(instance).Error; err != nil {
-				return fmt.Errorf("failed to save instance: %w", err)
+			if err := upsertInstance(tx, instance); err != nil {
+				return err
 			}
+		}
+		return nil
+	})
+}
 
-			// Only replace tags if provided to avoid wiping tags on partial updates
-			if len(instance.Tags) > 0 {
-				if err := tx.Where("instance_id = ?", instance.InstanceID).Delete(&Tag{}).Error; err != nil {
-					return fmt.Errorf("failed to delete existing tags: %w", err)
-				}
-
-				newTags := make([]Tag, 0, len(instance.Tags))
-				for _, tag := range instance.Tags {
-					tag.InstanceID = instance.InstanceID
-					newTags = append(newTags, tag)
-				}
-				if len(newTags) > 0 {
-					if err := tx.CreateInBatches(newTags, 100).Error; err != nil {
-						return fmt.Errorf("failed to save tags: %w", err)
-					}
-				}
-			}
+// upsertInstance creates an instance or overwrites the discovered columns of
+// its existing record, then replaces its tags
+func upsertInstance(tx *gorm.DB, instance *Instance) error {
+	var existing []Instance
+	if err := tx.Where("instance_id = ? AND profile = ? AND region = ?", instance.InstanceID, instance.Profile, instance.Region).
+		Limit(1).Find(&existing).Error; err != nil {
+		return fmt.Errorf("failed to save instance: %w", err)
+	}
+	if len(existing) == 0 {
+		if err := tx.Omit(clause.Associations).Create(instance).Error; err != nil {
+			return fmt.Errorf("failed to save instance: %w", err)
 		}
+	} else {
+		instance.ID = existing[0].ID
+		if err := tx.Model(instance).Select(instanceColumns).Updates(instance).Error; err != nil {
+			return fmt.Errorf("failed to save instance: %w", err)
+		}
+	}
 
+	// Only replace tags if provided to avoid wiping tags on partial updates
+	if len(instance.Tags) == 0 {
 		return nil
-	})
+	}
+	if err := tx.Where("instance_id = ?", instance.InstanceID).Delete(&Tag{}).Error; err != nil {
+		return fmt.Errorf("failed to delete existing tags: %w", err)
+	}
+
+	newTags := make([]Tag, 0, len(instance.Tags))
+	for _, tag := range instance.Tags {
+		tag.ID = 0
+		tag.InstanceID = instance.InstanceID
+		newTags = append(newTags, tag)
+	}
+	if err := tx.CreateInBatches(newTags, 100).Error; err != nil {
+		return fmt.Errorf("failed to save tags: %w", err)
+	}
+	return nil
 }
 
+// reachabilityOrder prioritizes reachable instances, then favors newest records.
+// Note: "running" may be stored in various cases, so compare in lower().
+const reachabilityOrder = `CASE 
+        WHEN state = 'Online' OR (ping_status = 'Online' AND lower(state) = 'running') THEN 0 
+        WHEN lower(state) = 'running' THEN 1 
+        ELSE 2 
+    END ASC, last_seen DESC, updated_at DESC`
+
 // FindByName finds an instance by name, preferring reachable instances.
 // Preference order:
-//  1. SSM Online
+//  1. SSM Online (hybrid instances, or running EC2 instances whose agent is Online)
 //  2. EC2 running
 //  3. Everything else (e.g., ConnectionLost, stopped)
 //
-// Within the same priority, choose the most recently seen/updated.
+// Within the same priority, choose the instance with the highest frecency
+// score from the connection history, then the most recently seen/updated.
+// A target that matches no Name tag is tried as an instance ID, private IP,
+// private DNS name and SSM computer name. Targets may be qualified as
+// profile/region/name or account:name. When several distinct instances share
+// the best priority, an *AmbiguousNameError listing them is returned.
 func (r *InstanceRepository) FindByName(name string) (*Instance, error) {
-	var instance Instance
-	// Use CASE ordering to prioritize desired states, then favor newest records.
-	// Note: "running" may be stored in various cases, so compare in lower().
-	orderExpr := `CASE 
-        WHEN state = 'Online' THEN 0 
-        WHEN lower(state) = 'running' THEN 1 
-        ELSE 2 
-    END ASC, last_seen DESC, updated_at DESC`
+	candidates, err := r.findNameCandidates(InstanceQuery{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		if query, ok := ParseInstanceQuery(name); ok {
+			if candidates, err = r.findNameCandidates(query); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
 
-	if err := DB.Preload("Tags").Where("name = ?", name).Order(orderExpr).First(&instance).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			// Try again by stripping common domain suffixes (e.g., .maas)
-			// This allows connecting with either base name or FQDN.
-			var alt Instance
-			if idx := indexOfDot(name); idx > 0 {
-				base := name[:idx]
-				if err2 := DB.Preload("Tags").Where("name = ?", base).Order(orderExpr).First(&alt).Error; err2 == nil {
-					return &alt, nil
-				}
+	if ambiguous := ambiguousCandidates(candidates); ambiguous != nil {
+		return nil, &AmbiguousNameError{Name: name, Candidates: ambiguous}
+	}
+	return &candidates[0], nil
+}
+
+// targetClause matches a target against a column. Domain clauses take the
+// target as a LIKE prefix, so its wildcards are escaped.
+type targetClause struct {
+	query  string
+	domain bool
+}
+
+// targetClauses match a target against the Name tag and then the identifiers
+// persisted at discovery, in order. Host names compare case-insensitively and
+// may omit their domain (ip-10-0-1-2 matches ip-10-0-1-2.ec2.internal).
+var targetClauses = []targetClause{
+	{query: "name = ?"},
+	{query: "instance_id = ?"},
+	{query: "private_ip = ?"},
+	{query: "lower(private_dns) = lower(?)"},
+	{query: "lower(computer_name) = lower(?)"},
+	{query: `private_dns LIKE ? || '.%' ESCAPE '\'`, domain: true},
+	{query: `computer_name LIKE ? || '.%' ESCAPE '\'`, domain: true},
+}
+
+// escapeLike escapes the LIKE wildcards in s for use with ESCAPE '\'
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// findNameCandidates returns the instances matching a query, best first
+func (r *InstanceRepository) findNameCandidates(query InstanceQuery) ([]Instance, error) {
+	if query.Name == "" {
+		return nil, nil
+	}
+
+	find := func(clause, name string) ([]Instance, error) {
+		db := DB.Preload("Tags").Where(clause, name)
+		if query.Profile != "" {
+			db = db.Where("profile = ?", query.Profile)
+		}
+		if query.Region != "" {
+			db = db.Where("region = ?", query.Region)
+		}
+		if query.AccountID != "" {
+			db = db.Where("account_id = ?", query.AccountID)
+		}
+
+		var instances []Instance
+		if err := db.Order(reachabilityOrder).Find(&instances).Error; err != nil {
+			return nil, fmt.Errorf("failed to find instance by name: %w", err)
+		}
+		return instances, nil
+	}
+
+	var instances []Instance
+	for _, clause := range targetClauses {
+		name := query.Name
+		if clause.domain {
+			name = escapeLike(name)
+		}
+		var err error
+		if instances, err = find(clause.query, name); err != nil {
+			return nil, err
+		}
+		if len(instances) > 0 {
+			break
+		}
+	}
+
+	// Try again by stripping common domain suffixes (e.g., .maas)
+	// This allows connecting with either base name or FQDN.
+	if len(instances) == 0 {
+		if idx := indexOfDot(query.Name); idx > 0 {
+			var err error
+			if instances, err = find("name = ?", query.Name[:idx]); err != nil {
+				return nil, err
 			}
-			return nil, nil
 		}
-		return nil, fmt.Errorf("failed to find instance by name: %w", err)
 	}
-	return &instance, nil
+
+	if err := rankCandidates(instances); err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
+
+// ListByFrecency returns every instance with its tags, most frecent first,
+// then reachable instances first and alphabetically
+func (r *InstanceRepository) ListByFrecency() ([]Instance, error) {
+	var instances []Instance
+	if err := DB.Preload("Tags").Order("name ASC").Find(&instances).Error; err != nil {
+		return nil, fmt.Errorf("failed to list instances: %w", err)
+	}
+
+	scores, err := NewConnectionRepository().FrecencyScores()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(instances, func(i, j int) bool {
+		si, sj := scores[instances[i].key()], scores[instances[j].key()]
+		if si != sj {
+			return si > sj
+		}
+		return reachabilityTier(&instances[i]) < reachabilityTier(&instances[j])
+	})
+	return instances, nil
+}
+
+// rankCandidates orders instances already sorted by reachabilityOrder by
+// reachability, then frecency score
+func rankCandidates(instances []Instance) error {
+	if len(instances) < 2 {
+		return nil
+	}
+
+	scores, err := NewConnectionRepository().FrecencyScores()
+	if err != nil {
+		return err
+	}
+
+	// Stable sort keeps the last_seen ordering among equal scores
+	sort.SliceStable(instances, func(i, j int) bool {
+		ti, tj := reachabilityTier(&instances[i]), reachabilityTier(&instances[j])
+		if ti != tj {
+			return ti < tj
+		}
+		return scores[instances[i].key()] > scores[instances[j].key()]
+	})
+	return nil
+}
+
+// CompleteNames returns the distinct instance names starting with prefix,
+// most frecent first and then alphabetically
+func (r *InstanceRepository) CompleteNames(prefix string) ([]string, error) {
+	var instances []Instance
+	if err := DB.Select("instance_id", "name", "profile", "region").
+		Where("name LIKE ? AND name != ''", prefix+"%").Find(&instances).Error; err != nil {
+		return nil, fmt.Errorf("failed to complete instance names: %w", err)
+	}
+
+	scores, err := NewConnectionRepository().FrecencyScores()
+	if err != nil {
+		return nil, err
+	}
+
+	best := make(map[string]float64)
+	for _, instance := range instances {
+		score := scores[instance.key()]
+		if current, ok := best[instance.Name]; !ok || score > current {
+			best[instance.Name] = score
+		}
+	}
+
+	names := make([]string, 0, len(best))
+	for name := range best {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if best[names[i]] != best[names[j]] {
+			return best[names[i]] > best[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return names, nil
+}
+
+// reachabilityTier mirrors reachabilityOrder for sorting in Go
+func reachabilityTier(instance *Instance) int {
+	switch {
+	case instance.Reachable():
+		return 0
+	case strings.EqualFold(instance.State, "running"):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// key identifies the instance record for connection history lookups
+func (i *Instance) key() string {
+	return instanceKey(i.InstanceID, i.Profile, i.Region)
+}
+
+// FindByTags finds the instances carrying every given tag key/value pair,
+// ordered like FindByName so the most reachable instance comes first
+func (r *InstanceRepository) FindByTags(tags map[string]string) ([]Instance, error) {
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("no tags provided")
+	}
+
+	selector := make(TagSelector, 0, len(tags))
+	for key, value := range tags {
+		selector = append(selector, TagRequirement{Key: key, Operator: TagEquals, Value: value})
+	}
+	return r.FindBySelector(selector)
+}
+
+// FindBySelector finds the instances matching a tag selector, ranked like
+// FindByName candidates
+func (r *InstanceRepository) FindBySelector(selector TagSelector) ([]Instance, error) {
+	if len(selector) == 0 {
+		return nil, fmt.Errorf("empty tag selector")
+	}
+
+	var instances []Instance
+	if err := selector.apply(DB.Preload("Tags")).Order(reachabilityOrder).Find(&instances).Error; err != nil {
+		return nil, fmt.Errorf("failed to find instances by tags: %w", err)
+	}
+	if err := rankCandidates(instances); err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
+
+// FindOneBySelector finds the single instance a tag selector targets. Like
+// FindByName it prefers reachable instances and returns an
+// *AmbiguousNameError when several distinct instances remain.
+func (r *InstanceRepository) FindOneBySelector(selector TagSelector) (*Instance, error) {
+	candidates, err := r.FindBySelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	if ambiguous := ambiguousCandidates(candidates); ambiguous != nil {
+		return nil, &AmbiguousNameError{Name: selector.String(), Candidates: ambiguous}
+	}
+	return &candidates[0], nil
 }
 
 // indexOfDot returns the index of the first '.' in s, or -1 if none
@@ -175,6 +395,22 @@ func (r *InstanceRepository) FindByID(instanceID string) (*Instance, error) {
 	return &instance, nil
 }
 
+// FindByIDInScope finds an instance by instance ID among those discovered
+// through a profile and region
+func (r *InstanceRepository) FindByIDInScope(instanceID, profile, region string) (*Instance, error) {
+	var instance Instance
+	err := DB.Preload("Tags").
+		Where("instance_id = ? AND profile = ? AND region = ?", instanceID, profile, region).
+		First(&instance).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find instance by ID: %w", err)
+	}
+	return &instance, nil
+}
+
 // List returns a list of instances with optional filters
 func (r *InstanceRepository) List(filter *InstanceFilter) ([]Instance, error) {
 	var instances []Instance
@@ -193,6 +429,7 @@ func (r *InstanceRepository) List(filter *InstanceFilter) ([]Instance, error) {
 		if filter.State != nil {
 			query = query.Where("state = ?", *filter.State)
 		}
+		query = filter.Tags.apply(query)
 	}
 
 	// Order alphabetically by profile (account), region, then name for stable listing
@@ -221,6 +458,55 @@ func (r *InstanceRepository) DeleteStale(olderThan time.Duration) error {
 	return nil
 }
 
+// ssmStatusColumns are the columns ApplySSMInformation sets
+var ssmStatusColumns = []string{"ping_status", "agent_version", "is_latest_version", "last_ping_at", "ip_address", "platform_name", "platform_version", "computer_name"}
+
+// UpdateSSMStatus stores the SSM agent status of instances saved by
+// SaveOrUpdateBatch. Unlike the upsert, zero values are written too, so
+// instances that left SSM lose their stale status.
+func (r *InstanceRepository) UpdateSSMStatus(instances []*Instance) error {
+	if len(instances) == 0 {
+		return nil
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		for _, instance := range instances {
+			if err := tx.Model(&Instance{}).
+				Where("instance_id = ? AND profile = ? AND region = ?", instance.InstanceID, instance.Profile, instance.Region).
+				Select(ssmStatusColumns).
+				Updates(instance).Error; err != nil {
+				return fmt.Errorf("failed to update SSM status: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// UpdatePingStatus sets the cached SSM agent status of every record of an instance
+func (r *InstanceRepository) UpdatePingStatus(instanceID, status string) error {
+	if err := DB.Model(&Instance{}).Where("instance_id = ?", instanceID).Update("ping_status", status).Error; err != nil {
+		return fmt.Errorf("failed to update instance ping status: %w", err)
+	}
+	return nil
+}
+
+// Profiles returns the distinct profiles instances were discovered with
+func (r *InstanceRepository) Profiles() ([]string, error) {
+	var profiles []string
+	if err := DB.Model(&Instance{}).Distinct().Order("profile").Pluck("profile", &profiles).Error; err != nil {
+		return nil, fmt.Errorf("failed to list instance profiles: %w", err)
+	}
+	return profiles, nil
+}
+
+// UpdateState sets the cached state of every record of an instance
+func (r *InstanceRepository) UpdateState(instanceID, state string) error {
+	if err := DB.Model(&Instance{}).Where("instance_id = ?", instanceID).Update("state", state).Error; err != nil {
+		return fmt.Errorf("failed to update instance state: %w", err)
+	}
+	return nil
+}
+
 // DeleteByState removes instances with the specified state
 func (r *InstanceRepository) DeleteByState(state string) (int64, error) {
 	// First, get the instance IDs that will be deleted to clean up associated tags
@@ -297,7 +583,10 @@ func ConvertEC2Instance(ec2Instance ectype.Instance, region, profile, accountID
 		Region:     region,
 		Profile:    profile,
 		AccountID:  accountID,
-		State:      string(ec2Instance.State.Name),
+	}
+	// EC2 may omit the state, which leaves it empty
+	if ec2Instance.State != nil {
+		instance.State = string(ec2Instance.State.Name)
 	}
 
 	// Extract name from tags
@@ -321,6 +610,42 @@ func ConvertEC2Instance(ec2Instance ectype.Instance, region, profile, accountID
 	if ec2Instance.PlatformDetails != nil {
 		instance.Platform = *ec2Instance.PlatformDetails
 	}
+	if ec2Instance.PrivateIpAddress != nil {
+		instance.PrivateIP = *ec2Instance.PrivateIpAddress
+	}
+	if ec2Instance.PrivateDnsName != nil {
+		instance.PrivateDNS = *ec2Instance.PrivateDnsName
+	}
+	if ec2Instance.PublicIpAddress != nil {
+		instance.PublicIP = *ec2Instance.PublicIpAddress
+	}
+	if ec2Instance.VpcId != nil {
+		instance.VpcID = *ec2Instance.VpcId
+	}
+	if ec2Instance.SubnetId != nil {
+		instance.SubnetID = *ec2Instance.SubnetId
+	}
+	if ec2Instance.Placement != nil && ec2Instance.Placement.AvailabilityZone != nil {
+		instance.AvailabilityZone = *ec2Instance.Placement.AvailabilityZone
+	}
+	instance.InstanceType = string(ec2Instance.InstanceType)
+	if ec2Instance.LaunchTime != nil {
+		instance.LaunchTime = *ec2Instance.LaunchTime
+	}
+	if ec2Instance.ImageId != nil {
+		instance.ImageID = *ec2Instance.ImageId
+	}
+	if ec2Instance.KeyName != nil {
+		instance.KeyName = *ec2Instance.KeyName
+	}
+	if ec2Instance.IamInstanceProfile != nil && ec2Instance.IamInstanceProfile.Arn != nil {
+		instance.IAMInstanceProfile = *ec2Instance.IamInstanceProfile.Arn
+	}
+	// On-demand instances have no lifecycle in the EC2 API
+	instance.Lifecycle = "on-demand"
+	if ec2Instance.InstanceLifecycle != "" {
+		instance.Lifecycle = string(ec2Instance.InstanceLifecycle)
+	}
 
 	return instance
 }
@@ -344,7 +669,44 @@ func ConvertSSMManagedInstance(info ssmtypes.InstanceInformation, region, profil
 	if info.PlatformName != nil {
 		instance.Platform = *info.PlatformName
 	}
+	if info.IPAddress != nil {
+		instance.PrivateIP = *info.IPAddress
+	}
+	ApplySSMInformation(instance, info)
 
 	// SSM DescribeInstanceInformation does not return EC2 tags; skip tags here
 	return instance
 }
+
+// ApplySSMInformation records the SSM agent status of an instance. EC2
+// instances get it merged from the matching SSM record; see UpdateSSMStatus.
+func ApplySSMInformation(instance *Instance, info ssmtypes.InstanceInformation) {
+	instance.PingStatus = string(info.PingStatus)
+	if info.AgentVersion != nil {
+		instance.AgentVersion = *info.AgentVersion
+	}
+	if info.IsLatestVersion != nil {
+		instance.IsLatestVersion = *info.IsLatestVersion
+	}
+	if info.LastPingDateTime != nil {
+		instance.LastPingAt = *info.LastPingDateTime
+	}
+	if info.IPAddress != nil {
+		instance.IPAddress = *info.IPAddress
+	}
+	if info.PlatformName != nil {
+		instance.PlatformName = *info.PlatformName
+	}
+	if info.PlatformVersion != nil {
+		instance.PlatformVersion = *info.PlatformVersion
+	}
+	if info.ComputerName != nil {
+		instance.ComputerName = *info.ComputerName
+	}
+}
+
+// Reachable reports whether the instance can take sessions: its SSM agent is
+// Online and, for EC2 instances, the instance is running
+func (i *Instance) Reachable() bool {
+	return i.State == "Online" || (i.PingStatus == "Online" && strings.EqualFold(i.State, "running"))
+}
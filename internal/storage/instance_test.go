@@ -2,7 +2,10 @@ package storage
 
 import (
 	"testing"
+	"time"
 
+	ectype "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gorm.io/driver/sqlite"
@@ -15,7 +18,7 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	require.NoError(t, err)
 
 	// Run migrations
-	err = db.AutoMigrate(&Instance{}, &Tag{})
+	err = db.AutoMigrate(&Instance{}, &Tag{}, &Tunnel{}, &Connection{}, &ECSTask{})
 	require.NoError(t, err)
 
 	// Ensure repository code uses this in-memory DB
@@ -76,6 +79,44 @@ func TestInstanceRepository_SaveOrUpdate(t *testing.T) {
 	assert.Len(t, updatedInstance.Tags, 2) // Tags should be replaced
 }
 
+// TestInstanceRepository_SaveOrUpdateBatch_ClearsStaleColumns tests that a
+// re-sync clears what EC2 stopped reporting and keeps the SSM status and tags
+func TestInstanceRepository_SaveOrUpdateBatch_ClearsStaleColumns(t *testing.T) {
+	setupTestDB(t)
+	repo := &InstanceRepository{}
+
+	instance := &Instance{
+		InstanceID: "i-1111111111111111a", Name: "web", Profile: "dev", Region: "us-east-1", State: "running",
+		PrivateIP: "10.0.1.2", PublicIP: "54.1.2.3", KeyName: "deploy",
+		IAMInstanceProfile: "arn:aws:iam::111111111111:instance-profile/web",
+		Tags:               []Tag{{Key: "Name", Value: "web"}},
+	}
+	require.NoError(t, repo.SaveOrUpdateBatch([]*Instance{instance}))
+	require.NoError(t, repo.UpdateSSMStatus([]*Instance{
+		{InstanceID: "i-1111111111111111a", Profile: "dev", Region: "us-east-1", PingStatus: "Online"},
+	}))
+
+	// Stopped: the public IP is released and the instance profile detached
+	require.NoError(t, repo.SaveOrUpdateBatch([]*Instance{
+		{InstanceID: "i-1111111111111111a", Name: "web", Profile: "dev", Region: "us-east-1", State: "stopped", PrivateIP: "10.0.1.2"},
+	}))
+
+	found, err := repo.FindByID("i-1111111111111111a")
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, "stopped", found.State)
+	assert.Equal(t, "10.0.1.2", found.PrivateIP)
+	assert.Empty(t, found.PublicIP)
+	assert.Empty(t, found.KeyName)
+	assert.Empty(t, found.IAMInstanceProfile)
+	assert.Equal(t, "Online", found.PingStatus)
+	assert.Len(t, found.Tags, 1)
+
+	instances, err := repo.List(&InstanceFilter{})
+	require.NoError(t, err)
+	assert.Len(t, instances, 1)
+}
+
 // TestInstanceRepository_FindByName tests finding instances by name
 func TestInstanceRepository_FindByName(t *testing.T) {
 	db := setupTestDB(t)
@@ -106,6 +147,51 @@ func TestInstanceRepository_FindByName(t *testing.T) {
 	assert.Nil(t, notFound)
 }
 
+// TestInstanceRepository_FindByTags tests finding instances by tag key/value pairs
+func TestInstanceRepository_FindByTags(t *testing.T) {
+	setupTestDB(t)
+	repo := &InstanceRepository{}
+
+	instances := []*Instance{
+		{
+			InstanceID: "i-1111111111111111a",
+			Name:       "bastion-old",
+			Region:     "us-east-1",
+			Profile:    "dev",
+			State:      "stopped",
+			Tags:       []Tag{{Key: "env", Value: "dev"}, {Key: "role", Value: "bastion"}},
+		},
+		{
+			InstanceID: "i-2222222222222222b",
+			Name:       "bastion",
+			Region:     "us-east-1",
+			Profile:    "dev",
+			State:      "running",
+			Tags:       []Tag{{Key: "env", Value: "dev"}, {Key: "role", Value: "bastion"}},
+		},
+		{
+			InstanceID: "i-3333333333333333c",
+			Name:       "web",
+			Region:     "us-east-1",
+			Profile:    "dev",
+			State:      "running",
+			Tags:       []Tag{{Key: "env", Value: "dev"}, {Key: "role", Value: "web"}},
+		},
+	}
+	require.NoError(t, repo.SaveOrUpdateBatch(instances))
+
+	// Reachable instances come first
+	found, err := repo.FindByTags(map[string]string{"env": "dev", "role": "bastion"})
+	require.NoError(t, err)
+	require.Len(t, found, 2)
+	assert.Equal(t, "bastion", found[0].Name)
+	assert.Equal(t, "bastion-old", found[1].Name)
+
+	found, err = repo.FindByTags(map[string]string{"env": "prod"})
+	require.NoError(t, err)
+	assert.Empty(t, found)
+}
+
 // TestInstanceRepository_List tests listing instances with filters
 func TestInstanceRepository_List(t *testing.T) {
 	db := setupTestDB(t)
@@ -154,22 +240,255 @@ func TestInstanceRepository_List(t *testing.T) {
 
 // TestConvertEC2Instance tests converting EC2 instances to our model
 func TestConvertEC2Instance(t *testing.T) {
-	// This would require importing EC2 types, but for now we'll test the basic structure
-	// In a real test, we'd create mock EC2 instances
-
-	instance := &Instance{
-		InstanceID: "i-1234567890abcdef0",
-		Region:     "us-east-1",
-		Profile:    "default",
-		AccountID:  "123456789012",
-	}
+	launched := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	instance := ConvertEC2Instance(ectype.Instance{
+		InstanceId:         stringPtr("i-1234567890abcdef0"),
+		State:              &ectype.InstanceState{Name: ectype.InstanceStateNameRunning},
+		Tags:               []ectype.Tag{{Key: stringPtr("Name"), Value: stringPtr("web-1")}},
+		PlatformDetails:    stringPtr("Linux/UNIX"),
+		PrivateIpAddress:   stringPtr("10.0.1.12"),
+		PublicIpAddress:    stringPtr("203.0.113.7"),
+		VpcId:              stringPtr("vpc-0abc"),
+		SubnetId:           stringPtr("subnet-0def"),
+		Placement:          &ectype.Placement{AvailabilityZone: stringPtr("us-east-1a")},
+		InstanceType:       ectype.InstanceTypeT3Micro,
+		LaunchTime:         &launched,
+		ImageId:            stringPtr("ami-0123"),
+		KeyName:            stringPtr("deploy"),
+		IamInstanceProfile: &ectype.IamInstanceProfile{Arn: stringPtr("arn:aws:iam::123456789012:instance-profile/web")},
+		InstanceLifecycle:  ectype.InstanceLifecycleTypeSpot,
+	}, "us-east-1", "default", "123456789012")
 
 	assert.Equal(t, "i-1234567890abcdef0", instance.InstanceID)
-	assert.Equal(t, "us-east-1", instance.Region)
-	assert.Equal(t, "default", instance.Profile)
+	assert.Equal(t, "web-1", instance.Name)
+	assert.Equal(t, "running", instance.State)
+	assert.Equal(t, "203.0.113.7", instance.PublicIP)
+	assert.Equal(t, "vpc-0abc", instance.VpcID)
+	assert.Equal(t, "subnet-0def", instance.SubnetID)
+	assert.Equal(t, "us-east-1a", instance.AvailabilityZone)
+	assert.Equal(t, "t3.micro", instance.InstanceType)
+	assert.Equal(t, launched, instance.LaunchTime)
+	assert.Equal(t, "ami-0123", instance.ImageID)
+	assert.Equal(t, "deploy", instance.KeyName)
+	assert.Equal(t, "arn:aws:iam::123456789012:instance-profile/web", instance.IAMInstanceProfile)
+	assert.Equal(t, "spot", instance.Lifecycle)
+
+	onDemand := ConvertEC2Instance(ectype.Instance{
+		InstanceId: stringPtr("i-0fedcba9876543210"),
+		State:      &ectype.InstanceState{Name: ectype.InstanceStateNameStopped},
+	}, "us-east-1", "default", "123456789012")
+	assert.Equal(t, "on-demand", onDemand.Lifecycle)
+
+	stateless := ConvertEC2Instance(ectype.Instance{
+		InstanceId: stringPtr("i-0aaaaaaaaaaaaaaaa"),
+	}, "us-east-1", "default", "123456789012")
+	assert.Empty(t, stateless.State)
 }
 
 // Helper function to create string pointer
 func stringPtr(s string) *string {
 	return &s
 }
+
+// TestInstanceRepository_FindByName_Ambiguous tests ambiguity detection and qualified names
+func TestInstanceRepository_FindByName_Ambiguous(t *testing.T) {
+	setupTestDB(t)
+	repo := &InstanceRepository{}
+
+	instances := []*Instance{
+		{InstanceID: "i-1111111111111111a", Name: "bastion", Profile: "dev", Region: "us-east-1", AccountID: "111111111111", State: "running"},
+		{InstanceID: "i-2222222222222222b", Name: "bastion", Profile: "prod", Region: "eu-west-1", AccountID: "222222222222", State: "running"},
+		{InstanceID: "i-3333333333333333c", Name: "bastion", Profile: "prod", Region: "us-east-1", AccountID: "222222222222", State: "stopped"},
+		// The same instance seen through a second profile is not ambiguous
+		{InstanceID: "i-4444444444444444d", Name: "web", Profile: "dev", Region: "us-east-1", AccountID: "111111111111", State: "running"},
+		{InstanceID: "i-4444444444444444d", Name: "web", Profile: "dev-admin", Region: "us-east-1", AccountID: "111111111111", State: "running"},
+		// A stopped duplicate does not compete with a running instance
+		{InstanceID: "i-5555555555555555e", Name: "api", Profile: "dev", Region: "us-east-1", State: "running"},
+		{InstanceID: "i-6666666666666666f", Name: "api", Profile: "dev", Region: "us-east-1", State: "stopped"},
+	}
+	require.NoError(t, repo.SaveOrUpdateBatch(instances))
+
+	_, err := repo.FindByName("bastion")
+	var ambiguous *AmbiguousNameError
+	require.ErrorAs(t, err, &ambiguous)
+	assert.Len(t, ambiguous.Candidates, 2)
+	assert.Contains(t, err.Error(), "prod/eu-west-1/bastion")
+
+	found, err := repo.FindByName("web")
+	require.NoError(t, err)
+	assert.Equal(t, "i-4444444444444444d", found.InstanceID)
+
+	// Instances sharing a name in one profile and region are suggested by ID
+	require.NoError(t, repo.SaveOrUpdateBatch([]*Instance{
+		{InstanceID: "i-7777777777777777a", Name: "worker", Profile: "prod", Region: "us-east-1", State: "running"},
+		{InstanceID: "i-8888888888888888b", Name: "worker", Profile: "prod", Region: "us-east-1", State: "running"},
+	}))
+	_, err = repo.FindByName("worker")
+	require.ErrorAs(t, err, &ambiguous)
+	assert.Contains(t, err.Error(), "prod/us-east-1/i-7777777777777777a")
+	assert.Contains(t, err.Error(), "prod/us-east-1/i-8888888888888888b")
+	assert.NotContains(t, err.Error(), "prod/us-east-1/worker")
+
+	found, err = repo.FindByName("prod/us-east-1/i-8888888888888888b")
+	require.NoError(t, err)
+	assert.Equal(t, "i-8888888888888888b", found.InstanceID)
+
+	found, err = repo.FindByName("api")
+	require.NoError(t, err)
+	assert.Equal(t, "i-5555555555555555e", found.InstanceID)
+
+	found, err = repo.FindByName("prod/us-east-1/bastion")
+	require.NoError(t, err)
+	assert.Equal(t, "i-3333333333333333c", found.InstanceID)
+
+	found, err = repo.FindByName("111111111111:bastion")
+	require.NoError(t, err)
+	assert.Equal(t, "i-1111111111111111a", found.InstanceID)
+
+	found, err = repo.FindByName("999999999999:bastion")
+	require.NoError(t, err)
+	assert.Nil(t, found)
+}
+
+// TestInstanceRepository_FindByName_Identifiers tests resolving targets by
+// instance ID, private IP, private DNS name and computer name
+func TestInstanceRepository_FindByName_Identifiers(t *testing.T) {
+	setupTestDB(t)
+	repo := &InstanceRepository{}
+
+	instances := []*Instance{
+		{InstanceID: "i-1111111111111111a", Name: "web", Profile: "dev", Region: "us-east-1", State: "running",
+			PrivateIP: "10.0.1.2", PrivateDNS: "ip-10-0-1-2.ec2.internal"},
+		{InstanceID: "mi-22222222222222222", Profile: "dev", Region: "us-east-1", State: "Online",
+			PrivateIP: "192.168.1.10", ComputerName: "build01.corp.example.com"},
+	}
+	require.NoError(t, repo.SaveOrUpdateBatch(instances))
+
+	for target, want := range map[string]string{
+		"i-1111111111111111a":                "i-1111111111111111a",
+		"10.0.1.2":                           "i-1111111111111111a",
+		"ip-10-0-1-2.ec2.internal":           "i-1111111111111111a",
+		"IP-10-0-1-2":                        "i-1111111111111111a",
+		"dev/us-east-1/10.0.1.2":             "i-1111111111111111a",
+		"build01.corp.example.com":           "mi-22222222222222222",
+		"build01":                            "mi-22222222222222222",
+		"dev/us-east-1/mi-22222222222222222": "mi-22222222222222222",
+	} {
+		found, err := repo.FindByName(target)
+		require.NoError(t, err, target)
+		require.NotNil(t, found, target)
+		assert.Equal(t, want, found.InstanceID, target)
+	}
+
+	// SSM status updates keep the persisted identifiers
+	require.NoError(t, repo.UpdateSSMStatus([]*Instance{
+		{InstanceID: "i-1111111111111111a", Profile: "dev", Region: "us-east-1", PingStatus: "Online", ComputerName: "ip-10-0-1-2"},
+	}))
+	found, err := repo.FindByName("10.0.1.2")
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, "ip-10-0-1-2.ec2.internal", found.PrivateDNS)
+
+	found, err = repo.FindByName("10.0.9.9")
+	require.NoError(t, err)
+	assert.Nil(t, found)
+}
+
+// TestInstanceRepository_FindByName_Wildcards tests that LIKE wildcards in a
+// target match only themselves
+func TestInstanceRepository_FindByName_Wildcards(t *testing.T) {
+	setupTestDB(t)
+	repo := &InstanceRepository{}
+
+	require.NoError(t, repo.SaveOrUpdateBatch([]*Instance{
+		{InstanceID: "mi-33333333333333333", Profile: "dev", Region: "us-east-1", State: "Online",
+			ComputerName: "build-01.corp.example.com"},
+		{InstanceID: "mi-44444444444444444", Profile: "dev", Region: "us-east-1", State: "Online",
+			ComputerName: "Build_02.corp.example.com"},
+	}))
+
+	for _, target := range []string{"build_01", "build_01.corp.example.com", "build%", "%", "_uild-01"} {
+		found, err := repo.FindByName(target)
+		require.NoError(t, err, target)
+		assert.Nil(t, found, target)
+	}
+
+	for _, target := range []string{"build_02", "BUILD_02.corp.example.com"} {
+		found, err := repo.FindByName(target)
+		require.NoError(t, err, target)
+		require.NotNil(t, found, target)
+		assert.Equal(t, "mi-44444444444444444", found.InstanceID, target)
+	}
+}
+
+// TestInstanceRepository_UpdateSSMStatus tests merging SSM agent status onto
+// EC2 rows and ranking by it
+func TestInstanceRepository_UpdateSSMStatus(t *testing.T) {
+	setupTestDB(t)
+	repo := &InstanceRepository{}
+
+	agentless := &Instance{InstanceID: "i-1111111111111111a", Name: "web", Profile: "default", Region: "us-east-1", State: "running", Platform: "Linux/UNIX"}
+	managed := &Instance{InstanceID: "i-2222222222222222b", Name: "web", Profile: "default", Region: "us-east-1", State: "running", Platform: "Linux/UNIX"}
+	require.NoError(t, repo.SaveOrUpdateBatch([]*Instance{agentless, managed}))
+
+	// Both are running, so neither is preferred yet
+	_, err := repo.FindByName("web")
+	var ambiguous *AmbiguousNameError
+	require.ErrorAs(t, err, &ambiguous)
+
+	ApplySSMInformation(managed, ssmtypes.InstanceInformation{
+		PingStatus:      ssmtypes.PingStatusOnline,
+		AgentVersion:    stringPtr("3.3.40.0"),
+		IsLatestVersion: boolPtr(true),
+		PlatformName:    stringPtr("Amazon Linux"),
+		PlatformVersion: stringPtr("2023"),
+	})
+	require.NoError(t, repo.UpdateSSMStatus([]*Instance{agentless, managed}))
+
+	found, err := repo.FindByName("web")
+	require.NoError(t, err)
+	assert.Equal(t, "i-2222222222222222b", found.InstanceID)
+	assert.Equal(t, "Online", found.PingStatus)
+	assert.Equal(t, "3.3.40.0", found.AgentVersion)
+	assert.Equal(t, "Amazon Linux", found.PlatformName)
+	assert.True(t, found.IsLatestVersion)
+	assert.True(t, found.Reachable())
+
+	// An instance that left SSM loses its stale status
+	require.NoError(t, repo.UpdateSSMStatus([]*Instance{
+		{InstanceID: "i-2222222222222222b", Profile: "default", Region: "us-east-1"},
+	}))
+	found, err = repo.FindByID("i-2222222222222222b")
+	require.NoError(t, err)
+	assert.Empty(t, found.PingStatus)
+	assert.Empty(t, found.PlatformName)
+	assert.False(t, found.IsLatestVersion)
+	assert.False(t, found.Reachable())
+}
+
+// TestInstanceRepository_FindByIDInScope tests that a lookup by ID is limited
+// to the profile and region it is recorded under
+func TestInstanceRepository_FindByIDInScope(t *testing.T) {
+	setupTestDB(t)
+	repo := &InstanceRepository{}
+
+	for _, profile := range []string{"dev", "admin"} {
+		require.NoError(t, repo.SaveOrUpdate(&Instance{
+			InstanceID: "i-1234567890abcdef0", Name: "web", Profile: profile, Region: "us-east-1", State: "running",
+		}))
+	}
+
+	found, err := repo.FindByIDInScope("i-1234567890abcdef0", "admin", "us-east-1")
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, "admin", found.Profile)
+
+	found, err = repo.FindByIDInScope("i-1234567890abcdef0", "admin", "eu-west-1")
+	require.NoError(t, err)
+	assert.Nil(t, found)
+}
+
+// Helper function to create bool pointer
+func boolPtr(b bool) *bool {
+	return &b
+}
@@ -8,17 +8,45 @@ import (
 
 // Instance represents an EC2 instance in the database
 type Instance struct {
-	ID         uint      `gorm:"primarykey" json:"-"`
-	InstanceID string    `gorm:"uniqueIndex:idx_instance_profile_region;size:20" json:"instance_id"`
-	Name       string    `gorm:"index;size:255" json:"name"`
-	Region     string    `gorm:"uniqueIndex:idx_instance_profile_region;size:20" json:"region"`
-	Profile    string    `gorm:"uniqueIndex:idx_instance_profile_region;size:100" json:"profile"`
-	AccountID  string    `gorm:"index;size:20" json:"account_id"`
-	State      string    `gorm:"size:20" json:"state"`
-	Platform   string    `gorm:"size:50" json:"platform"`
-	LastSeen   time.Time `json:"last_seen"`
-	CreatedAt  time.Time `json:"-"`
-	UpdatedAt  time.Time `json:"-"`
+	ID         uint   `gorm:"primarykey" json:"-"`
+	InstanceID string `gorm:"uniqueIndex:idx_instance_profile_region;size:20" json:"instance_id"`
+	Name       string `gorm:"index;size:255" json:"name"`
+	Region     string `gorm:"uniqueIndex:idx_instance_profile_region;size:20" json:"region"`
+	Profile    string `gorm:"uniqueIndex:idx_instance_profile_region;size:100" json:"profile"`
+	AccountID  string `gorm:"index;size:20" json:"account_id"`
+	State      string `gorm:"size:20" json:"state"`
+	Platform   string `gorm:"size:50" json:"platform"`
+	PrivateIP  string `gorm:"column:private_ip;index;size:45" json:"private_ip"`
+	PrivateDNS string `gorm:"column:private_dns;index;size:255" json:"private_dns"`
+	// ComputerName is the host name reported by the SSM agent
+	ComputerName     string    `gorm:"index;size:255" json:"computer_name"`
+	PublicIP         string    `gorm:"column:public_ip;index;size:45" json:"public_ip"`
+	VpcID            string    `gorm:"column:vpc_id;size:32" json:"vpc_id"`
+	SubnetID         string    `gorm:"column:subnet_id;size:32" json:"subnet_id"`
+	AvailabilityZone string    `gorm:"size:32" json:"availability_zone"`
+	InstanceType     string    `gorm:"size:32" json:"instance_type"`
+	LaunchTime       time.Time `json:"launch_time"`
+	ImageID          string    `gorm:"column:image_id;size:32" json:"image_id"`
+	KeyName          string    `gorm:"size:255" json:"key_name"`
+	// IAMInstanceProfile is the ARN of the attached instance profile
+	IAMInstanceProfile string `gorm:"column:iam_instance_profile;size:2048" json:"iam_instance_profile"`
+	// Lifecycle is "spot", "scheduled", "capacity-block" or "on-demand"
+	Lifecycle string `gorm:"size:20" json:"lifecycle"`
+	// PingStatus is the SSM agent status; empty when the instance is not
+	// registered with SSM
+	PingStatus      string    `gorm:"size:20" json:"ping_status"`
+	AgentVersion    string    `gorm:"size:32" json:"agent_version"`
+	IsLatestVersion bool      `json:"is_latest_version"`
+	LastPingAt      time.Time `json:"last_ping_at"`
+	// IPAddress is the address the SSM agent reports
+	IPAddress string `gorm:"column:ip_address;size:45" json:"ip_address"`
+	// PlatformName is the operating system the SSM agent reports, e.g.
+	// Ubuntu where Platform only says Linux/UNIX
+	PlatformName    string    `gorm:"size:100" json:"platform_name"`
+	PlatformVersion string    `gorm:"size:50" json:"platform_version"`
+	LastSeen        time.Time `json:"last_seen"`
+	CreatedAt       time.Time `json:"-"`
+	UpdatedAt       time.Time `json:"-"`
 
 	Tags []Tag `gorm:"foreignKey:InstanceID;references:InstanceID" json:"tags"`
 }
@@ -45,6 +73,49 @@ type Profile struct {
 	Enabled bool   `gorm:"default:true" json:"enabled"`
 }
 
+// Tunnel represents a running ssm port forwarding process
+type Tunnel struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	PID          int       `gorm:"column:pid;index" json:"pid"`
+	InstanceID   string    `gorm:"size:20" json:"instance_id"`
+	InstanceName string    `gorm:"size:255" json:"instance_name"`
+	Profile      string    `gorm:"size:100" json:"profile"`
+	Region       string    `gorm:"size:20" json:"region"`
+	Mappings     string    `gorm:"size:1024" json:"mappings"` // comma-separated port mappings
+	LogPath      string    `gorm:"size:1024" json:"log_path"`
+	StartedAt    time.Time `json:"started_at"`
+}
+
+// Connection represents a past session or port forward to an instance
+type Connection struct {
+	ID           uint          `gorm:"primarykey" json:"id"`
+	InstanceID   string        `gorm:"index;size:20" json:"instance_id"`
+	InstanceName string        `gorm:"size:255" json:"instance_name"`
+	Profile      string        `gorm:"size:100" json:"profile"`
+	Region       string        `gorm:"size:20" json:"region"`
+	Mode         string        `gorm:"size:20" json:"mode"`
+	Detail       string        `gorm:"size:1024" json:"detail"` // e.g. the port mappings of a forward
+	StartedAt    time.Time     `gorm:"index" json:"started_at"`
+	Duration     time.Duration `json:"duration"`
+}
+
+// ECSTask represents a running ECS task with ECS Exec enabled
+type ECSTask struct {
+	ID         uint      `gorm:"primarykey" json:"-"`
+	TaskArn    string    `gorm:"uniqueIndex:idx_task_profile;size:255" json:"task_arn"`
+	TaskID     string    `gorm:"index;size:64" json:"task_id"`
+	Cluster    string    `gorm:"index;size:255" json:"cluster"` // cluster name
+	ClusterArn string    `gorm:"size:255" json:"cluster_arn"`
+	Service    string    `gorm:"index;size:255" json:"service"`
+	Containers string    `gorm:"size:1024" json:"containers"` // comma-separated containers with a running exec agent
+	LaunchType string    `gorm:"size:20" json:"launch_type"`
+	LastStatus string    `gorm:"size:20" json:"last_status"`
+	Region     string    `gorm:"index;size:20" json:"region"`
+	Profile    string    `gorm:"uniqueIndex:idx_task_profile;size:100" json:"profile"`
+	AccountID  string    `gorm:"size:20" json:"account_id"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
 // TableName specifies the table name for Instance
 func (Instance) TableName() string {
 	return "instances"
@@ -65,6 +136,21 @@ func (Profile) TableName() string {
 	return "profiles"
 }
 
+// TableName specifies the table name for Tunnel
+func (Tunnel) TableName() string {
+	return "tunnels"
+}
+
+// TableName specifies the table name for Connection
+func (Connection) TableName() string {
+	return "connections"
+}
+
+// TableName specifies the table name for ECSTask
+func (ECSTask) TableName() string {
+	return "ecs_tasks"
+}
+
 // BeforeCreate sets the LastSeen timestamp before creating a record
 func (i *Instance) BeforeCreate(tx *gorm.DB) error {
 	i.LastSeen = time.Now()
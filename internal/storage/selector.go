@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Tag requirement operators
+const (
+	TagExists    = "exists"
+	TagNotExists = "!exists"
+	TagEquals    = "="
+	TagNotEquals = "!="
+)
+
+// TagRequirement is a single condition on an instance's tags. Keys and
+// values may contain the glob wildcards * and ?.
+type TagRequirement struct {
+	Key      string
+	Operator string
+	Value    string
+}
+
+// TagSelector is a set of tag requirements that must all hold
+type TagSelector []TagRequirement
+
+// ParseTagSelector parses a comma-separated selector such as
+// "env=prod,role!=db,team,!deprecated,name=web-*". A bare key requires the
+// tag to exist, !key requires it to be absent, and k!=v also matches
+// instances without the tag.
+func ParseTagSelector(s string) (TagSelector, error) {
+	var selector TagSelector
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			return nil, fmt.Errorf("invalid tag selector %q: empty term", s)
+		}
+
+		var req TagRequirement
+		switch {
+		case strings.Contains(term, "!="):
+			key, value, _ := strings.Cut(term, "!=")
+			req = TagRequirement{Key: key, Operator: TagNotEquals, Value: value}
+		case strings.Contains(term, "="):
+			key, value, _ := strings.Cut(term, "=")
+			req = TagRequirement{Key: key, Operator: TagEquals, Value: value}
+		case strings.HasPrefix(term, "!"):
+			req = TagRequirement{Key: term[1:], Operator: TagNotExists}
+		default:
+			req = TagRequirement{Key: term, Operator: TagExists}
+		}
+
+		req.Key = strings.TrimSpace(req.Key)
+		req.Value = strings.TrimSpace(req.Value)
+		if req.Key == "" {
+			return nil, fmt.Errorf("invalid tag selector term %q: missing key", term)
+		}
+		selector = append(selector, req)
+	}
+	return selector, nil
+}
+
+// String formats the selector in the syntax accepted by ParseTagSelector
+func (s TagSelector) String() string {
+	terms := make([]string, len(s))
+	for i, req := range s {
+		switch req.Operator {
+		case TagExists:
+			terms[i] = req.Key
+		case TagNotExists:
+			terms[i] = "!" + req.Key
+		default:
+			terms[i] = req.Key + req.Operator + req.Value
+		}
+	}
+	return strings.Join(terms, ",")
+}
+
+// apply restricts an instances query to instances satisfying the selector
+func (s TagSelector) apply(query *gorm.DB) *gorm.DB {
+	for _, req := range s {
+		tags := whereGlob(DB.Model(&Tag{}).Select("instance_id"), "key", req.Key)
+		if req.Operator == TagEquals || req.Operator == TagNotEquals {
+			tags = whereGlob(tags, "value", req.Value)
+		}
+
+		if req.Operator == TagExists || req.Operator == TagEquals {
+			query = query.Where("instance_id IN (?)", tags)
+		} else {
+			query = query.Where("instance_id NOT IN (?)", tags)
+		}
+	}
+	return query
+}
+
+// whereGlob matches column against pattern, using GLOB only when the
+// pattern has wildcards so plain lookups stay exact
+func whereGlob(db *gorm.DB, column, pattern string) *gorm.DB {
+	if strings.ContainsAny(pattern, "*?[") {
+		return db.Where(column+" GLOB ?", pattern)
+	}
+	return db.Where(column+" = ?", pattern)
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseTagSelector tests parsing every selector operator
+func TestParseTagSelector(t *testing.T) {
+	selector, err := ParseTagSelector("env=prod, role!=db,team,!deprecated,Name=web-*")
+	require.NoError(t, err)
+	assert.Equal(t, TagSelector{
+		{Key: "env", Operator: TagEquals, Value: "prod"},
+		{Key: "role", Operator: TagNotEquals, Value: "db"},
+		{Key: "team", Operator: TagExists},
+		{Key: "deprecated", Operator: TagNotExists},
+		{Key: "Name", Operator: TagEquals, Value: "web-*"},
+	}, selector)
+	assert.Equal(t, "env=prod,role!=db,team,!deprecated,Name=web-*", selector.String())
+
+	for _, invalid := range []string{"", "env=prod,", "=prod", "!"} {
+		_, err := ParseTagSelector(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+// TestInstanceRepository_FindBySelector tests selector queries against the tags table
+func TestInstanceRepository_FindBySelector(t *testing.T) {
+	setupTestDB(t)
+	repo := &InstanceRepository{}
+
+	instances := []*Instance{
+		{InstanceID: "i-1111111111111111a", Name: "web-1", Profile: "prod", Region: "us-east-1", State: "running",
+			Tags: []Tag{{Key: "env", Value: "prod"}, {Key: "role", Value: "web"}, {Key: "team", Value: "payments"}}},
+		{InstanceID: "i-2222222222222222b", Name: "web-2", Profile: "prod", Region: "us-east-1", State: "running",
+			Tags: []Tag{{Key: "env", Value: "prod"}, {Key: "role", Value: "web"}, {Key: "deprecated", Value: "true"}}},
+		{InstanceID: "i-3333333333333333c", Name: "db-1", Profile: "prod", Region: "us-east-1", State: "running",
+			Tags: []Tag{{Key: "env", Value: "prod"}, {Key: "role", Value: "db"}}},
+		{InstanceID: "i-4444444444444444d", Name: "web-dev", Profile: "dev", Region: "us-east-1", State: "running",
+			Tags: []Tag{{Key: "env", Value: "dev"}, {Key: "role", Value: "web"}}},
+	}
+	require.NoError(t, repo.SaveOrUpdateBatch(instances))
+
+	names := func(selector string) []string {
+		parsed, err := ParseTagSelector(selector)
+		require.NoError(t, err)
+		found, err := repo.FindBySelector(parsed)
+		require.NoError(t, err)
+		var result []string
+		for _, instance := range found {
+			result = append(result, instance.Name)
+		}
+		return result
+	}
+
+	assert.ElementsMatch(t, []string{"web-1", "web-2"}, names("env=prod,role=web"))
+	assert.ElementsMatch(t, []string{"web-1", "web-2", "web-dev"}, names("role!=db"))
+	assert.ElementsMatch(t, []string{"web-1"}, names("team"))
+	assert.ElementsMatch(t, []string{"web-1", "db-1", "web-dev"}, names("!deprecated"))
+	assert.ElementsMatch(t, []string{"web-1", "web-2", "db-1"}, names("env=p*"))
+	assert.Empty(t, names("env=staging"))
+
+	selector, err := ParseTagSelector("team=payments")
+	require.NoError(t, err)
+	found, err := repo.FindOneBySelector(selector)
+	require.NoError(t, err)
+	assert.Equal(t, "web-1", found.Name)
+
+	selector, err = ParseTagSelector("role=web")
+	require.NoError(t, err)
+	_, err = repo.FindOneBySelector(selector)
+	var ambiguous *AmbiguousNameError
+	assert.ErrorAs(t, err, &ambiguous)
+}
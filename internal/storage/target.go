@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// InstanceQuery narrows an instance name to a profile, region or account
+type InstanceQuery struct {
+	Name      string
+	Profile   string
+	Region    string
+	AccountID string
+}
+
+// ParseInstanceQuery parses the qualified target forms profile/region/name
+// and account:name. It reports false for a plain name.
+func ParseInstanceQuery(target string) (InstanceQuery, bool) {
+	if parts := strings.Split(target, "/"); len(parts) == 3 && parts[0] != "" && parts[1] != "" && parts[2] != "" {
+		return InstanceQuery{Profile: parts[0], Region: parts[1], Name: parts[2]}, true
+	}
+	if account, name, ok := strings.Cut(target, ":"); ok && isAccountID(account) && name != "" {
+		return InstanceQuery{AccountID: account, Name: name}, true
+	}
+	return InstanceQuery{}, false
+}
+
+// DisplayName returns the instance name, falling back to its ID
+func (i *Instance) DisplayName() string {
+	if i.Name != "" {
+		return i.Name
+	}
+	return i.InstanceID
+}
+
+// QualifiedName returns the profile/region/name target that selects the
+// instance, using its instance ID when it has no name
+func (i *Instance) QualifiedName() string {
+	name := i.Name
+	if name == "" {
+		name = i.InstanceID
+	}
+	return i.Profile + "/" + i.Region + "/" + name
+}
+
+// isAccountID reports whether s looks like a 12 digit AWS account ID
+func isAccountID(s string) bool {
+	if len(s) != 12 {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// AmbiguousNameError is returned when a name matches several reachable
+// instances and none can be preferred over the others
+type AmbiguousNameError struct {
+	Name       string
+	Candidates []Instance
+}
+
+// Error lists the candidates with the qualified names that select them
+func (e *AmbiguousNameError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "'%s' matches %d instances; target one with profile/region/name or account:name:", e.Name, len(e.Candidates))
+	for i, target := range qualifiedTargets(e.Candidates) {
+		c := e.Candidates[i]
+		fmt.Fprintf(&b, "\n  %s  (account %s, %s, %s)", target, c.AccountID, c.State, c.InstanceID)
+	}
+	return b.String()
+}
+
+// qualifiedTargets returns a target selecting each of instances: its
+// QualifiedName, or profile/region/instance-id when another of the instances
+// has the same one, as members of an Auto Scaling group do
+func qualifiedTargets(instances []Instance) []string {
+	counts := make(map[string]int)
+	for i := range instances {
+		counts[instances[i].QualifiedName()]++
+	}
+
+	targets := make([]string, len(instances))
+	for i := range instances {
+		c := &instances[i]
+		targets[i] = c.QualifiedName()
+		if counts[targets[i]] > 1 {
+			targets[i] = c.Profile + "/" + c.Region + "/" + c.InstanceID
+		}
+	}
+	return targets
+}
+
+// ambiguousCandidates returns the distinct instances sharing the best
+// reachability tier of a ranked candidate list. Records of the same instance
+// seen through several profiles count once.
+func ambiguousCandidates(candidates []Instance) []Instance {
+	if len(candidates) < 2 {
+		return nil
+	}
+
+	tier := reachabilityTier(&candidates[0])
+	seen := make(map[string]bool)
+	var distinct []Instance
+	for _, c := range candidates {
+		if reachabilityTier(&c) != tier || seen[c.InstanceID] {
+			continue
+		}
+		seen[c.InstanceID] = true
+		distinct = append(distinct, c)
+	}
+
+	if len(distinct) < 2 {
+		return nil
+	}
+	return distinct
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// TunnelRepository handles database operations for tunnels
+type TunnelRepository struct{}
+
+// NewTunnelRepository creates a new tunnel repository
+func NewTunnelRepository() *TunnelRepository {
+	return &TunnelRepository{}
+}
+
+// Create records a running tunnel
+func (r *TunnelRepository) Create(tunnel *Tunnel) error {
+	if err := DB.Create(tunnel).Error; err != nil {
+		return fmt.Errorf("failed to record tunnel: %w", err)
+	}
+	return nil
+}
+
+// List returns all recorded tunnels, oldest first
+func (r *TunnelRepository) List() ([]Tunnel, error) {
+	var tunnels []Tunnel
+	if err := DB.Order("id").Find(&tunnels).Error; err != nil {
+		return nil, fmt.Errorf("failed to list tunnels: %w", err)
+	}
+	return tunnels, nil
+}
+
+// FindByID finds a tunnel by its ID
+func (r *TunnelRepository) FindByID(id uint) (*Tunnel, error) {
+	var tunnel Tunnel
+	if err := DB.First(&tunnel, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find tunnel: %w", err)
+	}
+	return &tunnel, nil
+}
+
+// FindByPID finds the tunnel recorded by a process. It is polled while a
+// detached tunnel starts, so it avoids First to keep not-found out of the logs.
+func (r *TunnelRepository) FindByPID(pid int) (*Tunnel, error) {
+	var tunnels []Tunnel
+	if err := DB.Where("pid = ?", pid).Limit(1).Find(&tunnels).Error; err != nil {
+		return nil, fmt.Errorf("failed to find tunnel: %w", err)
+	}
+	if len(tunnels) == 0 {
+		return nil, nil
+	}
+	return &tunnels[0], nil
+}
+
+// Delete removes a tunnel record
+func (r *TunnelRepository) Delete(id uint) error {
+	if err := DB.Delete(&Tunnel{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete tunnel: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTunnelRepository tests recording, finding and deleting tunnels
+func TestTunnelRepository(t *testing.T) {
+	setupTestDB(t)
+	repo := NewTunnelRepository()
+
+	tunnel := &Tunnel{
+		PID:          4242,
+		InstanceID:   "i-1234567890abcdef0",
+		InstanceName: "bastion",
+		Mappings:     "5432:db.internal:5432,6379:6379",
+		StartedAt:    time.Now(),
+	}
+	require.NoError(t, repo.Create(tunnel))
+	require.NotZero(t, tunnel.ID)
+
+	found, err := repo.FindByPID(4242)
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, tunnel.ID, found.ID)
+	assert.Equal(t, "bastion", found.InstanceName)
+
+	tunnels, err := repo.List()
+	require.NoError(t, err)
+	assert.Len(t, tunnels, 1)
+
+	require.NoError(t, repo.Delete(tunnel.ID))
+	found, err = repo.FindByID(tunnel.ID)
+	require.NoError(t, err)
+	assert.Nil(t, found)
+}